@@ -0,0 +1,185 @@
+package dag
+
+// adjacency is an interned, integer-indexed copy of the edges of a graph,
+// for the algorithms which follow the edges of a large graph many times over,
+// such as TransitiveReduction, Reachable and the depth-first walks. Following
+// an edge through it is a slice index, rather than hashing an interface{}
+// key into the maps the graph is built from, which is where most of the time
+// goes on graphs with millions of edges.
+//
+// Every vertex is numbered by its position in vertices. The vertices of the
+// graph come first, followed by the endpoints of any edges to vertices that
+// aren't in the graph, so that a number below n is a vertex of the graph.
+// The targets of the edges from vertex i are down[downStart[i]:downStart[i+1]],
+// and the sources of the edges to it are likewise in up.
+//
+// It is built on demand, and discarded whenever the graph changes. Once
+// built, it is never modified, so it may be shared by concurrent readers and
+// by snapshots of the graph.
+type adjacency struct {
+	vertices []Vertex
+	ids      map[interface{}]int
+	n        int
+
+	downStart, down []int
+	upStart, up     []int
+}
+
+// newAdjacency builds the adjacency of g.
+//
+// Complexity: O(V+E)
+func newAdjacency(g *Graph) *adjacency {
+	a := &adjacency{ids: make(map[interface{}]int, len(g.vertices))}
+	for code, v := range g.vertices {
+		a.ids[code] = len(a.vertices)
+		a.vertices = append(a.vertices, v)
+	}
+	a.n = len(a.vertices)
+
+	// Every source is in the up-edges of its target, and every target in the
+	// down-edges of its source, so this numbers every endpoint.
+	for _, edges := range []map[interface{}]Set{g.downEdges, g.upEdges} {
+		for _, s := range edges {
+			for code, v := range s {
+				if _, ok := a.ids[code]; !ok {
+					a.ids[code] = len(a.vertices)
+					a.vertices = append(a.vertices, v)
+				}
+			}
+		}
+	}
+
+	a.downStart, a.down = a.index(g.downEdges)
+	a.upStart, a.up = a.index(g.upEdges)
+	return a
+}
+
+// index packs edges, keyed by the hashcode of each vertex, into the list of
+// vertex numbers at the end of each vertex's own range of list.
+func (a *adjacency) index(edges map[interface{}]Set) (start, list []int) {
+	start = make([]int, len(a.vertices)+1)
+	for code, s := range edges {
+		if len(s) > 0 {
+			start[a.ids[code]+1] = len(s)
+		}
+	}
+	for i := 1; i < len(start); i++ {
+		start[i] += start[i-1]
+	}
+
+	list = make([]int, start[len(a.vertices)])
+	for code, s := range edges {
+		if len(s) == 0 {
+			continue
+		}
+		next := start[a.ids[code]]
+		for other := range s {
+			list[next] = a.ids[other]
+			next++
+		}
+	}
+	return start, list
+}
+
+// id returns the number of v, or -1 if it isn't the endpoint of any edge
+// and isn't in the graph. a may be nil, in which case -1 is returned.
+func (a *adjacency) id(v Vertex) int {
+	if a == nil {
+		return -1
+	}
+	if i, ok := a.ids[hashcode(v)]; ok {
+		return i
+	}
+	return -1
+}
+
+// targets returns the numbers of the targets of the edges from vertex i.
+func (a *adjacency) targets(i int) []int {
+	return a.down[a.downStart[i]:a.downStart[i+1]]
+}
+
+// sources returns the numbers of the sources of the edges to vertex i.
+func (a *adjacency) sources(i int) []int {
+	return a.up[a.upStart[i]:a.upStart[i+1]]
+}
+
+// topologicalOrder returns the numbers of the vertices of the graph with the
+// targets of every edge before its source, like TopologicalSort without
+// sorting by name. Edges to vertices that aren't in the graph are ignored.
+// It returns false if the graph has a cycle.
+//
+// Complexity: O(V+E)
+func (a *adjacency) topologicalOrder() ([]int, bool) {
+	// the number of unordered targets of each vertex
+	pending := make([]int, a.n)
+	order := make([]int, 0, a.n)
+	for i := 0; i < a.n; i++ {
+		for _, j := range a.targets(i) {
+			if j < a.n {
+				pending[i]++
+			}
+		}
+		if pending[i] == 0 {
+			order = append(order, i)
+		}
+	}
+
+	for k := 0; k < len(order); k++ {
+		for _, s := range a.sources(order[k]) {
+			if s >= a.n {
+				continue
+			}
+			pending[s]--
+			if pending[s] == 0 {
+				order = append(order, s)
+			}
+		}
+	}
+
+	return order, len(order) == a.n
+}
+
+// adjacency returns the adjacency of the graph, building it if the graph has
+// changed since it was last built. It is safe to call from concurrent
+// readers of the graph.
+func (g *Graph) adjacency() *adjacency {
+	g.init()
+	g.indexLock.Lock()
+	defer g.indexLock.Unlock()
+	return g.adjacencyLocked()
+}
+
+// adjacencyLocked is adjacency, for a caller already holding indexLock.
+func (g *Graph) adjacencyLocked() *adjacency {
+	if g.adj == nil {
+		g.adj = newAdjacency(g)
+	}
+	return g.adj
+}
+
+// walkAdjacency returns the adjacency of the graph for a walk, or nil if the
+// walk should follow the maps of the graph instead. The adjacency is only
+// built once the walks since the graph last changed have followed as many
+// edges through the maps as it would take to build, so that a graph which
+// changes between every walk doesn't pay for building it each time.
+func (g *Graph) walkAdjacency() *adjacency {
+	g.init()
+	g.indexLock.Lock()
+	defer g.indexLock.Unlock()
+	if g.adj == nil && g.walked < len(g.vertices)+len(g.edges) {
+		return nil
+	}
+	return g.adjacencyLocked()
+}
+
+// addWalked records that a walk has followed n edges through the maps of
+// the graph, towards building the adjacency for later walks.
+func (g *Graph) addWalked(n int) {
+	if n == 0 {
+		return
+	}
+	g.init()
+	g.indexLock.Lock()
+	g.walked += n
+	g.indexLock.Unlock()
+}
@@ -0,0 +1,209 @@
+package dag
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestAdjacency(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(1, 3))
+	g.Connect(BasicEdge(2, 3))
+	// an edge to a vertex that isn't in the graph
+	g.Connect(BasicEdge(3, 4))
+
+	a := g.adjacency()
+	if a.n != 3 || len(a.vertices) != 4 {
+		t.Fatalf("bad vertices: n = %d, %#v", a.n, a.vertices)
+	}
+	if a.id(4) != 3 {
+		t.Fatalf("dangling endpoint should be numbered last, got %d", a.id(4))
+	}
+	if a.id(5) != -1 {
+		t.Fatalf("unknown vertex should be -1, got %d", a.id(5))
+	}
+
+	names := func(ids []int) []Vertex {
+		var vs []Vertex
+		for _, i := range ids {
+			vs = append(vs, a.vertices[i])
+		}
+		sort.Slice(vs, func(i, j int) bool { return vs[i].(int) < vs[j].(int) })
+		return vs
+	}
+	if got := names(a.targets(a.id(1))); !reflect.DeepEqual(got, []Vertex{2, 3}) {
+		t.Fatalf("bad targets of 1: %#v", got)
+	}
+	if got := names(a.sources(a.id(3))); !reflect.DeepEqual(got, []Vertex{1, 2}) {
+		t.Fatalf("bad sources of 3: %#v", got)
+	}
+	if got := names(a.sources(a.id(4))); !reflect.DeepEqual(got, []Vertex{3}) {
+		t.Fatalf("bad sources of 4: %#v", got)
+	}
+
+	order, ok := a.topologicalOrder()
+	if !ok {
+		t.Fatal("graph should have no cycle")
+	}
+	var got []Vertex
+	for _, i := range order {
+		got = append(got, a.vertices[i])
+	}
+	if !reflect.DeepEqual(got, []Vertex{3, 2, 1}) {
+		t.Fatalf("bad order: %#v", got)
+	}
+
+	if g.adjacency() != a {
+		t.Fatal("adjacency should be reused until the graph changes")
+	}
+	g.Connect(BasicEdge(3, 1))
+	if g.adjacency() == a {
+		t.Fatal("adjacency should be rebuilt after Connect")
+	}
+	if _, ok := g.adjacency().topologicalOrder(); ok {
+		t.Fatal("graph should have a cycle")
+	}
+}
+
+// The walks switch to the adjacency once they have followed enough edges
+// through the maps of the graph, and must visit the same vertices at the
+// same depths either way.
+func TestAcyclicGraphDepthFirstWalk_adjacency(t *testing.T) {
+	var g AcyclicGraph
+	for i := 1; i <= 6; i++ {
+		g.Add(i)
+	}
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(1, 3))
+	g.Connect(BasicEdge(2, 4))
+	g.Connect(BasicEdge(3, 4))
+	g.Connect(BasicEdge(4, 5))
+	g.Connect(BasicEdge(4, 7))
+
+	walk := func(up bool, start Vertex) map[Vertex]int {
+		depths := make(map[Vertex]int)
+		cb := func(v Vertex, d int) error {
+			if _, ok := depths[v]; ok {
+				t.Fatalf("%v visited twice", v)
+			}
+			depths[v] = d
+			return nil
+		}
+		var err error
+		if up {
+			err = g.ReverseDepthFirstWalk(Set{hashcode(start): start}, cb)
+		} else {
+			err = g.DepthFirstWalk(Set{hashcode(start): start}, cb)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return depths
+	}
+
+	down, up := walk(false, 1), walk(true, 5)
+	if len(down) != 6 || len(up) != 5 {
+		t.Fatalf("bad walks: %#v, %#v", down, up)
+	}
+	for i := 0; g.adj == nil; i++ {
+		if i == 10 {
+			t.Fatal("adjacency was never built")
+		}
+		walk(false, 1)
+	}
+
+	// depths may differ with the order of the edges, so only compare the
+	// vertices, and the depths that can't differ
+	sameVertices := func(a, b map[Vertex]int) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for v := range a {
+			if _, ok := b[v]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	if got := walk(false, 1); !sameVertices(got, down) || got[1] != 0 || got[2] != 1 || got[3] != 1 {
+		t.Fatalf("bad walk through the adjacency: %#v", got)
+	}
+	if got := walk(true, 5); !sameVertices(got, up) || got[5] != 0 || got[4] != 1 || got[1] < 3 {
+		t.Fatalf("bad reverse walk through the adjacency: %#v", got)
+	}
+	if got := walk(false, 6); !reflect.DeepEqual(got, map[Vertex]int{6: 0}) {
+		t.Fatalf("bad walk from a vertex without edges: %#v", got)
+	}
+	if got := walk(false, 8); !reflect.DeepEqual(got, map[Vertex]int{8: 0}) {
+		t.Fatalf("bad walk from a vertex not in the graph: %#v", got)
+	}
+}
+
+// A callback which changes the graph sees the change in the rest of the
+// walk, even when the walk started out following the adjacency.
+func TestAcyclicGraphDepthFirstWalk_adjacencyChange(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.adjacency()
+
+	var visits []Vertex
+	err := g.DepthFirstWalk(Set{1: 1}, func(v Vertex, d int) error {
+		visits = append(visits, v)
+		if v == 1 {
+			g.Connect(BasicEdge(2, 3))
+			g.Connect(BasicEdge(1, 3))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visits) != 3 || visits[0] != 1 {
+		t.Fatalf("bad visits: %#v", visits)
+	}
+}
+
+// Reachable and TransitiveReduction share their index, and must agree with
+// a walk of the graph when it has edges to vertices that aren't in it.
+func TestAcyclicGraphTransReduction_danglingReachable(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(1, 3))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(2, 4))
+	g.Connect(BasicEdge(1, 4))
+
+	g.TransitiveReduction()
+	if g.HasEdge(BasicEdge(1, 3)) {
+		t.Fatal("redundant edge 1 -> 3 should be removed")
+	}
+	if !g.HasEdge(BasicEdge(1, 4)) || !g.HasEdge(BasicEdge(2, 4)) {
+		t.Fatal("edges to vertices outside the graph should be kept")
+	}
+
+	for _, from := range []Vertex{1, 2, 3, 4} {
+		for _, to := range []Vertex{1, 2, 3, 4} {
+			walked := false
+			g.DepthFirstWalk(g.downEdgesNoCopy(from), func(v Vertex, d int) error {
+				if v == to && g.HasVertex(to) {
+					walked = true
+				}
+				return nil
+			})
+			if got := g.Reachable(from, to); got != walked {
+				t.Fatalf("Reachable(%v, %v): expected %t, got %t", from, to, walked, got)
+			}
+		}
+	}
+}
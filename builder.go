@@ -0,0 +1,162 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FromDependencyMap builds a graph from a map of vertex names to the names
+// of their dependencies. Each key becomes a string vertex, connected by a
+// BasicEdge to each of the vertices it lists. Every dependency which isn't
+// itself a key, and every cycle, is reported in a single error.
+func FromDependencyMap(deps map[string][]string) (*AcyclicGraph, error) {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	g := &AcyclicGraph{}
+	for _, name := range names {
+		g.Add(name)
+	}
+
+	var diags Diagnostics
+	for _, name := range names {
+		for _, dep := range deps[name] {
+			if _, ok := deps[dep]; !ok {
+				diags = diags.Append(fmt.Errorf("%s depends on unknown vertex %s", name, dep))
+				continue
+			}
+			g.Connect(BasicEdge(name, dep))
+		}
+	}
+
+	diags = diags.Append(g.cycleDiagnostics())
+	if err := diags.Err(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Builder constructs an AcyclicGraph through chained calls, for graphs
+// written out in code such as in tests and examples:
+//
+//	g, diags := dag.NewBuilder().
+//		Vertex("a").
+//		Vertex("b").
+//		Edge("a", "b").
+//		Subgraph("s", func(b *dag.Builder) {
+//			b.Vertex("c")
+//		}).
+//		Edge("b", "s").
+//		Build()
+//
+// Edges may be added before the vertices they connect. Mistakes are
+// collected and returned by Build rather than interrupting the chain.
+type Builder struct {
+	vertices  []Vertex
+	edges     []Edge
+	subgraphs map[string]*BuilderSubgraph
+	diags     Diagnostics
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{subgraphs: make(map[string]*BuilderSubgraph)}
+}
+
+// Vertex adds v to the graph.
+func (b *Builder) Vertex(v Vertex) *Builder {
+	b.vertices = append(b.vertices, v)
+	return b
+}
+
+// Edge adds a BasicEdge from source to target. The name of a subgraph added
+// with Subgraph may be used in place of its vertex.
+func (b *Builder) Edge(source, target Vertex) *Builder {
+	b.edges = append(b.edges, BasicEdge(source, target))
+	return b
+}
+
+// Subgraph adds a BuilderSubgraph vertex called name, whose graph is built by
+// calling f with a new Builder. Any errors in the subgraph are returned by
+// Build, prefixed with its name.
+func (b *Builder) Subgraph(name string, f func(*Builder)) *Builder {
+	if _, ok := b.subgraphs[name]; ok {
+		b.diags = b.diags.Append(fmt.Errorf("duplicate subgraph %s", name))
+		return b
+	}
+
+	sb := NewBuilder()
+	f(sb)
+	g, diags := sb.Build()
+	for _, d := range diags {
+		b.diags = b.diags.Append(fmt.Errorf("subgraph %s: %s", name, d.Description().Summary))
+	}
+
+	s := &BuilderSubgraph{name: name, Graph: g}
+	b.subgraphs[name] = s
+	b.vertices = append(b.vertices, s)
+	return b
+}
+
+// Build returns the graph, along with every error found while building it:
+// edges to vertices which were never added, duplicate subgraphs, errors
+// within subgraphs, and cycles. The graph is returned even if there are
+// errors, without the edges which couldn't be added.
+func (b *Builder) Build() (*AcyclicGraph, Diagnostics) {
+	diags := append(Diagnostics(nil), b.diags...)
+
+	g := &AcyclicGraph{}
+	for _, v := range b.vertices {
+		g.Add(v)
+	}
+
+	for _, e := range b.edges {
+		source, target := b.resolve(e.Source()), b.resolve(e.Target())
+		missing := false
+		for _, v := range []Vertex{source, target} {
+			if !g.HasVertex(v) {
+				diags = diags.Append(fmt.Errorf("edge %s -> %s references unknown vertex %s",
+					VertexName(source), VertexName(target), VertexName(v)))
+				missing = true
+			}
+		}
+		if !missing {
+			g.Connect(BasicEdge(source, target))
+		}
+	}
+
+	return g, diags.Append(g.cycleDiagnostics())
+}
+
+// resolve returns the subgraph vertex named by v, or v itself.
+func (b *Builder) resolve(v Vertex) Vertex {
+	if name, ok := v.(string); ok {
+		if s, ok := b.subgraphs[name]; ok {
+			return s
+		}
+	}
+	return v
+}
+
+// BuilderSubgraph is the vertex added by Builder.Subgraph.
+type BuilderSubgraph struct {
+	name  string
+	Graph *AcyclicGraph
+}
+
+func (s *BuilderSubgraph) Name() string {
+	return s.name
+}
+
+func (s *BuilderSubgraph) Subgraph() Grapher {
+	return s.Graph
+}
+
+// CloneVertex implements VertexCloner, so that cloning a built graph also
+// clones its subgraphs.
+func (s *BuilderSubgraph) CloneVertex() Vertex {
+	return &BuilderSubgraph{name: s.name, Graph: s.Graph.Clone()}
+}
@@ -0,0 +1,128 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromDependencyMap(t *testing.T) {
+	g, err := FromDependencyMap(map[string][]string{
+		"app":    {"lib", "config"},
+		"lib":    {"config"},
+		"config": nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := strings.TrimSpace(g.String())
+	expected := strings.TrimSpace(testFromDependencyMapStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+}
+
+func TestFromDependencyMap_errors(t *testing.T) {
+	_, err := FromDependencyMap(map[string][]string{
+		"a": {"b", "missing"},
+		"b": {"a"},
+		"c": {"gone"},
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	for _, want := range []string{
+		"a depends on unknown vertex missing",
+		"c depends on unknown vertex gone",
+		"Cycle: ",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q:\n%s", want, err)
+		}
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	g, diags := NewBuilder().
+		Edge("a", "s").
+		Vertex("a").
+		Vertex("b").
+		Edge("a", "b").
+		Subgraph("s", func(b *Builder) {
+			b.Vertex("c").Vertex("d").Edge("c", "d")
+		}).
+		Edge("s", "b").
+		Build()
+	if diags.HasErrors() {
+		t.Fatal(diags.Err())
+	}
+
+	actual := strings.TrimSpace(g.String())
+	expected := strings.TrimSpace(testBuilderStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+
+	var sub *BuilderSubgraph
+	for _, v := range g.Vertices() {
+		if s, ok := v.(*BuilderSubgraph); ok {
+			sub = s
+		}
+	}
+	if sub == nil || !sub.Graph.HasEdge(BasicEdge("c", "d")) {
+		t.Fatal("bad subgraph")
+	}
+}
+
+func TestBuilder_errors(t *testing.T) {
+	g, diags := NewBuilder().
+		Vertex("a").
+		Vertex("b").
+		Edge("a", "missing").
+		Edge("a", "b").
+		Edge("b", "a").
+		Subgraph("s", func(b *Builder) {
+			b.Edge("c", "d")
+		}).
+		Subgraph("s", func(b *Builder) {}).
+		Build()
+
+	err := diags.Err()
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{
+		"edge a -> missing references unknown vertex missing",
+		"subgraph s: edge c -> d references unknown vertex c",
+		"duplicate subgraph s",
+		"Cycle: ",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q:\n%s", want, err)
+		}
+	}
+
+	// the valid parts are still built
+	if !g.HasEdge(BasicEdge("a", "b")) {
+		t.Fatal("missing edge a -> b")
+	}
+}
+
+const testFromDependencyMapStr = `
+app
+  config
+  lib
+config
+lib
+  config
+`
+
+const testBuilderStr = `
+a
+  b
+  s
+b
+s
+  b
+`
@@ -0,0 +1,90 @@
+package dag
+
+import "sync"
+
+// relativesCache holds the results of Descendants and Ancestors, keyed by
+// the hashcode of the vertex they were called for.
+type relativesCache struct {
+	lock        sync.Mutex
+	descendants map[interface{}]Set
+	ancestors   map[interface{}]Set
+}
+
+// CacheRelatives turns caching of the results of Descendants and Ancestors
+// on or off. While it is on, repeated calls for the same vertex reuse the
+// earlier result until the graph is next changed, which is much faster for
+// a graph that is queried many times between changes. Each call still
+// returns its own copy of the result, which the caller may modify.
+//
+// A graph returned by Snapshot starts with caching off.
+func (g *AcyclicGraph) CacheRelatives(enabled bool) {
+	if !enabled {
+		g.relatives = nil
+		return
+	}
+	if g.relatives == nil {
+		g.relatives = &relativesCache{
+			descendants: make(map[interface{}]Set),
+			ancestors:   make(map[interface{}]Set),
+		}
+	}
+}
+
+// invalidate discards everything derived from the contents of the graph. It
+// must be called whenever the graph is changed, and only then, so that
+// repeating a change which has already been made keeps what is cached.
+func (g *Graph) invalidate() {
+	g.reach = nil
+	g.adj = nil
+	g.walked = 0
+	if g.relatives != nil {
+		g.relatives.lock.Lock()
+		g.relatives.clear()
+		g.relatives.lock.Unlock()
+	}
+}
+
+// clear empties the cache, keeping its maps to be filled again.
+func (c *relativesCache) clear() {
+	for k := range c.descendants {
+		delete(c.descendants, k)
+	}
+	for k := range c.ancestors {
+		delete(c.ancestors, k)
+	}
+}
+
+// get returns a copy of the cached Descendants of v, or Ancestors if down is
+// false. c is nil if caching is off.
+func (c *relativesCache) get(v Vertex, down bool) (Set, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	s, ok := c.results(down)[hashcode(v)]
+	if !ok {
+		return nil, false
+	}
+	return s.Copy(), true
+}
+
+// put caches a copy of s as the Descendants of v, or Ancestors if down is
+// false.
+func (c *relativesCache) put(v Vertex, down bool, s Set) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.results(down)[hashcode(v)] = s.Copy()
+}
+
+func (c *relativesCache) results(down bool) map[interface{}]Set {
+	if down {
+		return c.descendants
+	}
+	return c.ancestors
+}
@@ -0,0 +1,71 @@
+package dag
+
+import (
+	"testing"
+)
+
+func TestAcyclicGraphCacheRelatives(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.CacheRelatives(true)
+
+	check := func(f func(Vertex) (Set, error), v Vertex, expected ...Vertex) {
+		t.Helper()
+		actual, err := f(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual.Len() != len(expected) {
+			t.Fatalf("expected %#v, got %#v", expected, actual.List())
+		}
+		for _, e := range expected {
+			if !actual.Include(e) {
+				t.Fatalf("expected %#v, got %#v", expected, actual.List())
+			}
+		}
+	}
+
+	check(g.Descendants, 1, 2)
+	check(g.Ancestors, 2, 1)
+	if len(g.relatives.descendants) != 1 || len(g.relatives.ancestors) != 1 {
+		t.Fatal("results were not cached")
+	}
+
+	// repeating changes which were already made keeps the cache
+	g.Add(1)
+	g.Connect(BasicEdge(1, 2))
+	g.RemoveEdge(BasicEdge(2, 1))
+	g.Remove(42)
+	if len(g.relatives.descendants) != 1 || len(g.relatives.ancestors) != 1 {
+		t.Fatal("cache was cleared without a change")
+	}
+
+	// results are copies
+	s, _ := g.Descendants(1)
+	s.Add(42)
+	check(g.Descendants, 1, 2)
+
+	g.Connect(BasicEdge(2, 3))
+	check(g.Descendants, 1, 2, 3)
+	check(g.Ancestors, 3, 1, 2)
+
+	g.RemoveEdge(BasicEdge(1, 2))
+	check(g.Descendants, 1)
+	check(g.Ancestors, 3, 2)
+
+	g.Remove(2)
+	check(g.Ancestors, 3)
+
+	// snapshots don't share the cache
+	snap := g.Snapshot()
+	if snap.relatives != nil {
+		t.Fatal("snapshot should not cache")
+	}
+
+	g.CacheRelatives(false)
+	g.Connect(BasicEdge(1, 3))
+	check(g.Descendants, 1, 3)
+}
@@ -0,0 +1,305 @@
+// Command dag inspects graphs serialized by the dag package, as JSON from
+// MarshalJSON, dot from Dot, or the YAML read by ParseYAML.
+//
+// Usage:
+//
+//	dag [-format json|dot|yaml] <command> [arguments]
+//
+// The commands are:
+//
+//	validate [file]         check the graph for cycles and self references
+//	topo [file]             print the vertices in topological order
+//	dot [file]              print the graph in the dot format
+//	mermaid [file]          print the graph as a Mermaid flowchart
+//	why <a> <b> [file]      print the shortest chain of dependencies from a to b
+//	diff <old> <new>        print the vertices and edges added and removed
+//	stats [file]            print statistics about the shape of the graph
+//
+// Graphs are read from standard input when no file is given. The format is
+// taken from the file extension, or guessed from the content when reading
+// standard input, unless -format is set.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sgoings/dag"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// errUsage is returned by commands given the wrong number of arguments.
+var errUsage = errors.New("wrong number of arguments")
+
+// errDiffer is returned by diff when the graphs differ, so that the command
+// exits with status 1 like diff(1).
+var errDiffer = errors.New("graphs differ")
+
+// command runs a subcommand with its arguments, writing the result to stdout.
+type command func(c *cli, args []string) error
+
+var commands = map[string]command{
+	"validate": cmdValidate,
+	"topo":     cmdTopo,
+	"dot":      cmdDot,
+	"mermaid":  cmdMermaid,
+	"why":      cmdWhy,
+	"diff":     cmdDiff,
+	"stats":    cmdStats,
+}
+
+// cli holds the state shared by the commands.
+type cli struct {
+	format string
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// run runs the command line given by args, returning the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dag", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: dag [-format json|dot|yaml] <command> [arguments]")
+		fmt.Fprintln(stderr, "commands: validate, topo, dot, mermaid, why, diff, stats")
+		flags.PrintDefaults()
+	}
+	c := &cli{stdin: stdin, stdout: stdout}
+	flags.StringVar(&c.format, "format", "", "format of the input graphs, instead of guessing")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	name := flags.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "dag: unknown command %q\n", name)
+		flags.Usage()
+		return 2
+	}
+
+	switch err := cmd(c, flags.Args()[1:]); err {
+	case nil:
+		return 0
+	case errDiffer:
+		return 1
+	case errUsage:
+		fmt.Fprintf(stderr, "dag %s: %s\n", name, err)
+		flags.Usage()
+		return 2
+	default:
+		fmt.Fprintf(stderr, "dag %s: %s\n", name, err)
+		return 1
+	}
+}
+
+// load reads the graph in the file at path, or standard input if path is
+// empty or "-".
+func (c *cli) load(path string) (*dag.AcyclicGraph, error) {
+	var src []byte
+	var err error
+	if path == "" || path == "-" {
+		src, err = ioutil.ReadAll(c.stdin)
+	} else {
+		src, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	format := c.format
+	if format == "" {
+		format = guessFormat(path, src)
+	}
+
+	var g *dag.Graph
+	switch format {
+	case "json":
+		g, err = dag.ParseJSON(bytes.NewReader(src))
+	case "dot":
+		g, err = dag.ParseDot(bytes.NewReader(src))
+	case "yaml":
+		// ParseYAML already rejects cycles, so the graph is returned as is.
+		return dag.ParseYAML(bytes.NewReader(src))
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dag.AcyclicGraph{Graph: *g}, nil
+}
+
+// loadArgs loads the graph named by the only argument in args, or standard
+// input if there are none.
+func (c *cli) loadArgs(args []string) (*dag.AcyclicGraph, error) {
+	switch len(args) {
+	case 0:
+		return c.load("")
+	case 1:
+		return c.load(args[0])
+	default:
+		return nil, errUsage
+	}
+}
+
+// guessFormat returns the format of a graph from the extension of path, or
+// from the start of src if the extension isn't known.
+func guessFormat(path string, src []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".dot", ".gv":
+		return "dot"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+
+	trimmed := bytes.TrimSpace(src)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return "json"
+	case bytes.HasPrefix(trimmed, []byte("digraph")), bytes.HasPrefix(trimmed, []byte("strict digraph")):
+		return "dot"
+	default:
+		return "yaml"
+	}
+}
+
+// vertex returns the vertex named name in g.
+func vertex(g *dag.AcyclicGraph, name string) (dag.Vertex, error) {
+	v, ok := g.VertexByName(name)
+	if !ok {
+		return nil, fmt.Errorf("no vertex named %q", name)
+	}
+	return v, nil
+}
+
+func cmdValidate(c *cli, args []string) error {
+	g, err := c.loadArgs(args)
+	if err != nil {
+		return err
+	}
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "ok")
+	return nil
+}
+
+func cmdTopo(c *cli, args []string) error {
+	g, err := c.loadArgs(args)
+	if err != nil {
+		return err
+	}
+	order, err := g.TopologicalSort()
+	if err != nil {
+		return err
+	}
+	for _, v := range order {
+		fmt.Fprintln(c.stdout, dag.VertexName(v))
+	}
+	return nil
+}
+
+func cmdDot(c *cli, args []string) error {
+	g, err := c.loadArgs(args)
+	if err != nil {
+		return err
+	}
+	_, err = c.stdout.Write(g.Dot(nil))
+	return err
+}
+
+func cmdMermaid(c *cli, args []string) error {
+	g, err := c.loadArgs(args)
+	if err != nil {
+		return err
+	}
+	_, err = c.stdout.Write(g.Mermaid(nil))
+	return err
+}
+
+func cmdWhy(c *cli, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	g, err := c.loadArgs(args[2:])
+	if err != nil {
+		return err
+	}
+	src, err := vertex(g, args[0])
+	if err != nil {
+		return err
+	}
+	dst, err := vertex(g, args[1])
+	if err != nil {
+		return err
+	}
+
+	path, err := g.Explain(src, dst)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(path))
+	for i, v := range path {
+		names[i] = dag.VertexName(v)
+	}
+	fmt.Fprintln(c.stdout, strings.Join(names, " -> "))
+	return nil
+}
+
+func cmdDiff(c *cli, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	old, err := c.load(args[0])
+	if err != nil {
+		return err
+	}
+	new, err := c.load(args[1])
+	if err != nil {
+		return err
+	}
+
+	// The vertices of each graph are parsed separately, so they are compared
+	// by name.
+	byName := func(v dag.Vertex) dag.Vertex { return dag.VertexName(v) }
+	diff := old.Graph.Map(byName).Diff(new.Graph.Map(byName))
+	if diff.Empty() {
+		return nil
+	}
+	fmt.Fprint(c.stdout, diff.String())
+	return errDiffer
+}
+
+func cmdStats(c *cli, args []string) error {
+	g, err := c.loadArgs(args)
+	if err != nil {
+		return err
+	}
+	s := g.Stats()
+	fmt.Fprintf(c.stdout, "vertices:       %d\n", s.Vertices)
+	fmt.Fprintf(c.stdout, "edges:          %d\n", s.Edges)
+	fmt.Fprintf(c.stdout, "roots:          %d\n", s.Roots)
+	fmt.Fprintf(c.stdout, "leaves:         %d\n", s.Leaves)
+	fmt.Fprintf(c.stdout, "max in-degree:  %d\n", s.MaxInDegree)
+	fmt.Fprintf(c.stdout, "max out-degree: %d\n", s.MaxOutDegree)
+	fmt.Fprintf(c.stdout, "avg degree:     %.2f\n", s.AvgOutDegree)
+	fmt.Fprintf(c.stdout, "depth:          %d\n", s.Depth)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testGraphYAML = `
+nodes:
+  app:
+    depends_on: [lib, config]
+  lib:
+    depends_on: [config]
+  config:
+`
+
+const testGraphDot = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] app" -> "[root] lib"
+		"[root] lib" -> "[root] log"
+	}
+}
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "graph.yaml")
+	dotPath := filepath.Join(dir, "graph.dot")
+	if err := ioutil.WriteFile(yamlPath, []byte(testGraphYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dotPath, []byte(testGraphDot), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		Args   []string
+		Stdin  string
+		Status int
+		Stdout string
+	}{
+		"validate": {
+			Args:   []string{"validate", yamlPath},
+			Stdout: "ok\n",
+		},
+		"topo stdin": {
+			Args:   []string{"topo"},
+			Stdin:  testGraphYAML,
+			Stdout: "config\nlib\napp\n",
+		},
+		"topo dot": {
+			Args:   []string{"topo", dotPath},
+			Stdout: "log\nlib\napp\n",
+		},
+		"why": {
+			Args:   []string{"why", "app", "config", yamlPath},
+			Stdout: "app -> config\n",
+		},
+		"why not": {
+			Args:   []string{"why", "config", "app", yamlPath},
+			Status: 1,
+		},
+		"diff": {
+			Args:   []string{"diff", yamlPath, dotPath},
+			Status: 1,
+			Stdout: "- app -> config\n- config\n- lib -> config\n+ lib -> log\n+ log\n",
+		},
+		"diff same": {
+			Args: []string{"diff", yamlPath, yamlPath},
+		},
+		"stats": {
+			Args:   []string{"-format", "yaml", "stats"},
+			Stdin:  testGraphYAML,
+			Stdout: "vertices:       3\n",
+		},
+		"unknown command": {
+			Args:   []string{"frobnicate"},
+			Status: 2,
+		},
+		"missing arguments": {
+			Args:   []string{"why", "app"},
+			Status: 2,
+		},
+		"unknown format": {
+			Args:   []string{"-format", "xml", "topo"},
+			Status: 1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			status := run(tc.Args, strings.NewReader(tc.Stdin), &stdout, &stderr)
+			if status != tc.Status {
+				t.Fatalf("expected status %d, got %d: %s", tc.Status, status, stderr.String())
+			}
+			if !strings.HasPrefix(stdout.String(), tc.Stdout) {
+				t.Fatalf("bad output:\n%s", stdout.String())
+			}
+		})
+	}
+}
+
+func TestRun_formats(t *testing.T) {
+	for _, cmd := range []string{"dot", "mermaid"} {
+		var stdout, stderr bytes.Buffer
+		if status := run([]string{cmd}, strings.NewReader(testGraphYAML), &stdout, &stderr); status != 0 {
+			t.Fatalf("%s: status %d: %s", cmd, status, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "app") {
+			t.Fatalf("%s: bad output:\n%s", cmd, stdout.String())
+		}
+	}
+}
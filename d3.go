@@ -0,0 +1,94 @@
+package dag
+
+import "encoding/json"
+
+// D3Graph is the form of a graph used by d3-force layouts, with the nodes
+// and links referring to each other by index.
+type D3Graph struct {
+	Nodes []*D3Node `json:"nodes"`
+	Links []*D3Link `json:"links"`
+}
+
+// D3Node is a vertex in a D3Graph.
+type D3Node struct {
+	// ID is the index of the node in D3Graph.Nodes.
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+
+	// Group is 0 for the vertices of the graph itself, and numbered from 1
+	// for each subgraph, at any depth, for coloring nodes by subgraph.
+	// GroupName is the name of the subgraph.
+	Group     int    `json:"group"`
+	GroupName string `json:"groupName,omitempty"`
+
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// D3Link is an edge in a D3Graph, between the nodes at the Source and
+// Target indexes.
+type D3Link struct {
+	Source int               `json:"source"`
+	Target int               `json:"target"`
+	Attrs  map[string]string `json:"attrs,omitempty"`
+}
+
+// MarshalD3 encodes the graph as JSON for d3-force, in the form
+// {"nodes": [...], "links": [...]}. The vertices of subgraphs are included
+// as nodes of their own group, alongside the vertex for the subgraph itself.
+func (g *Graph) MarshalD3() ([]byte, error) {
+	d, err := g.D3()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(d)
+}
+
+// D3 returns the graph in the form used by MarshalD3. Nodes are ordered as
+// in Marshal, with the vertices of each subgraph after those of its parent.
+func (g *Graph) D3() (*D3Graph, error) {
+	mg, err := g.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &D3Graph{
+		Nodes: []*D3Node{},
+		Links: []*D3Link{},
+	}
+	groups := 0
+	d.add(mg, 0, "", make(map[*MarshalVertex]int), &groups)
+	return d, nil
+}
+
+// add adds the vertices and edges of mg to d in the given group, followed by
+// those of its subgraphs in groups of their own.
+func (d *D3Graph) add(mg *MarshalGraph, group int, groupName string, index map[*MarshalVertex]int, groups *int) {
+	for _, v := range mg.Vertices {
+		index[v] = len(d.Nodes)
+		d.Nodes = append(d.Nodes, &D3Node{
+			ID:        len(d.Nodes),
+			Name:      marshaledName(v.Name),
+			Group:     group,
+			GroupName: groupName,
+			Attrs:     v.Attrs,
+		})
+	}
+
+	for _, e := range mg.Edges {
+		source := mg.VertexByID(e.Source)
+		target := mg.VertexByID(e.Target)
+		if source == nil || target == nil {
+			continue
+		}
+		d.Links = append(d.Links, &D3Link{
+			Source: index[source],
+			Target: index[target],
+			Attrs:  e.Attrs,
+		})
+	}
+
+	for _, sg := range mg.Subgraphs {
+		*groups++
+		d.add(sg, *groups, marshaledName(sg.Name), index, groups)
+	}
+}
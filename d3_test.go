@@ -0,0 +1,93 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGraphMarshalD3(t *testing.T) {
+	var sub Graph
+	sub.Add("x")
+	sub.Add(`y "quoted"`)
+	sub.Connect(BasicEdge("x", `y "quoted"`))
+
+	var g Graph
+	s := &testSubgrapher{name: "sub", graph: &sub}
+	g.Add("a")
+	g.Add(s)
+	g.Connect(BasicAttrEdge("a", s, map[string]string{"kind": "build"}))
+	g.SetVertexAttr("a", "color", "red")
+
+	js, err := g.MarshalD3()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, js, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	actual := strings.TrimSpace(buf.String())
+	expected := strings.TrimSpace(testGraphD3Str)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+func TestGraphMarshalD3_empty(t *testing.T) {
+	var g Graph
+	js, err := g.MarshalD3()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(js) != `{"nodes":[],"links":[]}` {
+		t.Fatalf("bad: %s", js)
+	}
+}
+
+const testGraphD3Str = `
+{
+  "nodes": [
+    {
+      "id": 0,
+      "name": "a",
+      "group": 0,
+      "attrs": {
+        "color": "red"
+      }
+    },
+    {
+      "id": 1,
+      "name": "sub",
+      "group": 0
+    },
+    {
+      "id": 2,
+      "name": "x",
+      "group": 1,
+      "groupName": "sub"
+    },
+    {
+      "id": 3,
+      "name": "y \"quoted\"",
+      "group": 1,
+      "groupName": "sub"
+    }
+  ],
+  "links": [
+    {
+      "source": 0,
+      "target": 1,
+      "attrs": {
+        "kind": "build"
+      }
+    },
+    {
+      "source": 2,
+      "target": 3
+    }
+  ]
+}
+`
@@ -1,6 +1,10 @@
 package dag
 
 import (
+	"bytes"
+	"container/heap"
+	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -14,6 +18,10 @@ type AcyclicGraph struct {
 // WalkFunc is the callback used for walking the graph.
 type WalkFunc func(Vertex) Diagnostics
 
+// WalkCtxFunc is a walk callback that also receives a context, which is
+// cancelled if the vertex times out.
+type WalkCtxFunc func(context.Context, Vertex) Diagnostics
+
 // DepthWalkFunc is a walk function that also receives the current depth of the
 // walk as an argument
 type DepthWalkFunc func(Vertex, int) error
@@ -22,6 +30,10 @@ type DepthWalkFunc func(Vertex, int) error
 // walk as an argument
 type BreadthWalkFunc func(Vertex, int) error
 
+// errStopWalk is returned from a walk function to end a walk early, when the
+// result has already been found.
+var errStopWalk = errors.New("stop walk")
+
 func (g *AcyclicGraph) DirectedGraph() Grapher {
 	return g
 }
@@ -29,6 +41,10 @@ func (g *AcyclicGraph) DirectedGraph() Grapher {
 // Returns a Set that includes every Vertex yielded by walking down from the
 // provided starting Vertex v.
 func (g *AcyclicGraph) Descendants(v Vertex) (Set, error) {
+	if s, ok := g.relatives.get(v, true); ok {
+		return s, nil
+	}
+
 	s := make(Set)
 	memoFunc := func(v Vertex, d int) error {
 		s.Add(v)
@@ -39,12 +55,17 @@ func (g *AcyclicGraph) Descendants(v Vertex) (Set, error) {
 		return nil, err
 	}
 
+	g.relatives.put(v, true, s)
 	return s, nil
 }
 
 // Returns a Set that includes every Vertex yielded by walking up from the
 // provided starting Vertex v.
 func (g *AcyclicGraph) Ancestors(v Vertex) (Set, error) {
+	if s, ok := g.relatives.get(v, false); ok {
+		return s, nil
+	}
+
 	s := make(Set)
 	memoFunc := func(v Vertex, d int) error {
 		s.Add(v)
@@ -55,19 +76,132 @@ func (g *AcyclicGraph) Ancestors(v Vertex) (Set, error) {
 		return nil, err
 	}
 
+	g.relatives.put(v, false, s)
+	return s, nil
+}
+
+// DescendantsAtDepth is like Descendants, but only includes the vertices at
+// most maxDepth edges below v, so that 1 gives its direct dependencies. A
+// negative maxDepth has no limit.
+//
+// Complexity: O(V+E) at most, visiting only the vertices within maxDepth
+func (g *AcyclicGraph) DescendantsAtDepth(v Vertex, maxDepth int) (Set, error) {
+	if !g.HasVertex(v) {
+		return nil, fmt.Errorf("vertex %s is not in the graph", VertexName(v))
+	}
+
+	s := make(Set)
+	withinDepth(v, maxDepth, g.downEdgesNoCopy, s)
+	return s, nil
+}
+
+// AncestorsAtDepth is like Ancestors, but only includes the vertices at most
+// maxDepth edges above v, so that 1 gives its direct dependents. A negative
+// maxDepth has no limit.
+//
+// Complexity: O(V+E) at most, visiting only the vertices within maxDepth
+func (g *AcyclicGraph) AncestorsAtDepth(v Vertex, maxDepth int) (Set, error) {
+	if !g.HasVertex(v) {
+		return nil, fmt.Errorf("vertex %s is not in the graph", VertexName(v))
+	}
+
+	s := make(Set)
+	withinDepth(v, maxDepth, g.upEdgesNoCopy, s)
 	return s, nil
 }
 
+// FindDown returns the nearest descendant of v for which pred returns true,
+// searching breadth-first so that a direct dependency is found before an
+// indirect one. Among matches at the same depth, the first by VertexName is
+// returned. v itself is not considered. The second result is false if there
+// is no match.
+//
+// Complexity: O(V+E) at most, stopping at the first match
+func (g *AcyclicGraph) FindDown(v Vertex, pred func(Vertex) bool) (Vertex, bool) {
+	return g.find(v, g.downEdgesNoCopy, pred)
+}
+
+// FindUp is like FindDown, searching the ancestors of v instead, such as to
+// find the closest dependent of a particular type.
+//
+// Complexity: O(V+E) at most, stopping at the first match
+func (g *AcyclicGraph) FindUp(v Vertex, pred func(Vertex) bool) (Vertex, bool) {
+	return g.find(v, g.upEdgesNoCopy, pred)
+}
+
+func (g *AcyclicGraph) find(v Vertex, next func(Vertex) Set, pred func(Vertex) bool) (Vertex, bool) {
+	start := AsVertexList(next(v))
+	sort.Sort(byVertexName(start))
+
+	var found Vertex
+	err := g.breadthFirstWalk(context.Background(), start, next, true, func(v Vertex, d int) error {
+		if pred(v) {
+			found = v
+			return errStopWalk
+		}
+		return nil
+	})
+	return found, err == errStopWalk
+}
+
+// ImpactOpts are the options for ImpactWithOpts.
+type ImpactOpts struct {
+	// Reverse follows edges from source to target, so that the impact of a
+	// change is the vertices it depends on. This suits graphs whose edges
+	// point from inputs to the outputs built from them.
+	Reverse bool
+}
+
+// Impact returns the vertices affected by a change to any of the changed
+// vertices: the changed vertices themselves, and everything which depends on
+// them directly or indirectly. This is the set of vertices to rebuild when
+// the changed ones are modified. Every changed vertex missing from the graph
+// is reported in the error.
+//
+// Complexity: O(V+E)
+func (g *AcyclicGraph) Impact(changed []Vertex) (Set, error) {
+	return g.ImpactWithOpts(changed, nil)
+}
+
+// ImpactWithOpts is like Impact, with options for the direction of the
+// edges.
+//
+// Complexity: O(V+E)
+func (g *AcyclicGraph) ImpactWithOpts(changed []Vertex, opts *ImpactOpts) (Set, error) {
+	if opts == nil {
+		opts = &ImpactOpts{}
+	}
+
+	next := g.upEdgesNoCopy
+	if opts.Reverse {
+		next = g.downEdgesNoCopy
+	}
+
+	var diags Diagnostics
+	result := make(Set)
+	for _, v := range changed {
+		if !g.HasVertex(v) {
+			diags = diags.Append(fmt.Errorf("vertex %s is not in the graph", VertexName(v)))
+			continue
+		}
+		if result.Include(v) {
+			continue
+		}
+		result.Add(v)
+		withinDepth(v, -1, next, result)
+	}
+
+	if err := diags.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // Root returns the root of the DAG, or an error.
 //
 // Complexity: O(V)
 func (g *AcyclicGraph) Root() (Vertex, error) {
-	roots := make([]Vertex, 0, 1)
-	for _, v := range g.Vertices() {
-		if g.upEdgesNoCopy(v).Len() == 0 {
-			roots = append(roots, v)
-		}
-	}
+	roots := g.Roots()
 
 	if len(roots) > 1 {
 		// TODO(mitchellh): make this error message a lot better
@@ -81,6 +215,139 @@ func (g *AcyclicGraph) Root() (Vertex, error) {
 	return roots[0], nil
 }
 
+// Roots returns every vertex that has no edges to it, sorted by VertexName.
+// These are the vertices which nothing else depends on.
+//
+// Complexity: O(V log V)
+func (g *AcyclicGraph) Roots() []Vertex {
+	var roots []Vertex
+	for _, v := range g.Vertices() {
+		if g.upEdgesNoCopy(v).Len() == 0 {
+			roots = append(roots, v)
+		}
+	}
+
+	sort.Sort(byVertexName(roots))
+	return roots
+}
+
+// VirtualAttr is the vertex attribute set on vertices which only exist to
+// simplify the structure of a graph, such as the root added by
+// EnsureSingleRoot. Vertices with this attribute, and their edges, are left
+// out when the graph is marshaled, and so from Dot and the other formats.
+const VirtualAttr = "dag.virtual"
+
+// EnsureSingleRoot gives the graph a single root, so that it can be used
+// with Root. If there is more than one root, a virtual vertex called name is
+// added with an edge to each of them, and returned. Otherwise the existing
+// root is returned, or nil if the graph has no root.
+//
+// Complexity: O(V log V)
+func (g *AcyclicGraph) EnsureSingleRoot(name string) Vertex {
+	roots := g.Roots()
+	switch len(roots) {
+	case 0:
+		return nil
+	case 1:
+		return roots[0]
+	}
+
+	root := g.Add(&virtualRoot{name: name})
+	g.SetVertexAttr(root, VirtualAttr, "true")
+	for _, r := range roots {
+		g.Connect(BasicEdge(root, r))
+	}
+	return root
+}
+
+// virtualRoot is the vertex added by EnsureSingleRoot.
+type virtualRoot struct {
+	name string
+}
+
+func (v *virtualRoot) Name() string {
+	return v.name
+}
+
+// Leaves returns every vertex that has no edges from it, sorted by
+// VertexName. These are the vertices which have no dependencies.
+//
+// Complexity: O(V log V)
+func (g *AcyclicGraph) Leaves() []Vertex {
+	var leaves []Vertex
+	for _, v := range g.Vertices() {
+		if g.downEdgesNoCopy(v).Len() == 0 {
+			leaves = append(leaves, v)
+		}
+	}
+
+	sort.Sort(byVertexName(leaves))
+	return leaves
+}
+
+// ConnectChecked adds an edge to the graph like Connect, unless doing so would
+// introduce a cycle, in which case the graph is left unchanged and an error
+// describing the cycle is returned.
+//
+// Only the vertices reachable from the target of the edge are searched, so
+// this is much cheaper than validating the whole graph after each change.
+//
+// Complexity: O(V+E) of the subgraph reachable from the edge target
+func (g *AcyclicGraph) ConnectChecked(edge Edge) error {
+	source := edge.Source()
+	target := edge.Target()
+	sourceCode := hashcode(source)
+
+	if sourceCode == hashcode(target) {
+		return fmt.Errorf("Self reference: %s", VertexName(source))
+	}
+	if g.strict {
+		if err := g.checkStrict(edge); err != nil {
+			return err
+		}
+	}
+
+	// The new edge creates a cycle if the source can already be reached by
+	// walking down from the target. Search breadth-first so the reported
+	// cycle is as short as possible.
+	prev := map[interface{}]Vertex{hashcode(target): nil}
+	frontier := []Vertex{target}
+	for len(frontier) > 0 {
+		current := frontier[0]
+		frontier = frontier[1:]
+
+		for _, raw := range g.downEdgesNoCopy(current) {
+			code := hashcode(raw)
+			if _, ok := prev[code]; ok {
+				continue
+			}
+			prev[code] = current
+
+			if code != sourceCode {
+				frontier = append(frontier, raw.(Vertex))
+				continue
+			}
+
+			// Found the source, so describe the cycle it would close.
+			var cycle []string
+			for v := Vertex(raw); v != nil; v = prev[hashcode(v)] {
+				cycle = append(cycle, VertexName(v))
+			}
+			for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+				cycle[i], cycle[j] = cycle[j], cycle[i]
+			}
+
+			cycle = append(cycle, VertexName(target))
+
+			return fmt.Errorf("Cycle: adding %s -> %s would create %s",
+				VertexName(source), VertexName(target), strings.Join(cycle, " -> "))
+		}
+	}
+
+	g.Connect(edge)
+	return nil
+}
+
 // TransitiveReduction performs the transitive reduction of graph g in place.
 // The transitive reduction of a graph is a graph with as few edges as
 // possible with the same reachability as the original graph. This means
@@ -91,8 +358,63 @@ func (g *AcyclicGraph) Root() (Vertex, error) {
 //
 // The graph must be free of cycles for this operation to behave properly.
 //
-// Complexity: O(V(V+E)), or asymptotically O(VE)
+// Each vertex is visited in topological order, building a bitset of its
+// descendants from those of its targets, so an edge is redundant if its
+// target is already among the descendants of the targets visited before it.
+// The bitsets are kept as the index used by Reachable. A graph with a cycle
+// falls back to a search from each edge.
+//
+// Complexity: O(V + E log E + E*V/64), or O(V(V+E)) with a cycle
 func (g *AcyclicGraph) TransitiveReduction() {
+	a := g.adjacency()
+	order, ok := a.topologicalOrder()
+	if !ok {
+		g.transitiveReductionDFS()
+		return
+	}
+
+	position := make([]int, a.n)
+	for k, i := range order {
+		position[i] = k
+	}
+
+	r := &reachIndex{adj: a, descendants: make([]bitset, a.n)}
+	var targets []int
+	for _, i := range order {
+		targets = targets[:0]
+		for _, j := range a.targets(i) {
+			// skip edges to vertices that aren't in the graph
+			if j < a.n {
+				targets = append(targets, j)
+			}
+		}
+
+		// A target which reaches another comes later in the order, so
+		// visiting the targets latest first finds every redundant edge.
+		sort.Slice(targets, func(x, y int) bool {
+			return position[targets[x]] > position[targets[y]]
+		})
+
+		bits := newBitset(a.n)
+		for _, j := range targets {
+			if bits.has(j) {
+				g.removeEdgesBetween(a.vertices[i], a.vertices[j])
+				continue
+			}
+			bits.set(j)
+			bits.union(r.descendants[j])
+		}
+		r.descendants[i] = bits
+	}
+
+	// removing redundant edges doesn't change what is reachable, nor the
+	// numbering of the vertices
+	g.reach = r
+}
+
+// transitiveReductionDFS is TransitiveReduction for a graph which may
+// contain a cycle.
+func (g *AcyclicGraph) transitiveReductionDFS() {
 	// For each vertex u in graph g, do a DFS starting from each vertex
 	// v such that the edge (u,v) exists (v is a direct descendant of u).
 	//
@@ -103,7 +425,7 @@ func (g *AcyclicGraph) TransitiveReduction() {
 		g.DepthFirstWalk(g.downEdgesNoCopy(u), func(v Vertex, d int) error {
 			shared := uTargets.Intersection(g.downEdgesNoCopy(v))
 			for _, vPrime := range shared {
-				g.RemoveEdge(BasicEdge(u, vPrime))
+				g.removeEdgesBetween(u, vPrime)
 			}
 
 			return nil
@@ -112,39 +434,42 @@ func (g *AcyclicGraph) TransitiveReduction() {
 }
 
 // Validate validates the DAG. A DAG is valid if it has a single root
-// with no cycles.
+// with no cycles, and every edge refers to vertices within the graph. For a
+// graph created with GraphOpts.Strict, the edges rejected by Connect are
+// reported too.
+//
+// Every problem found is reported, rather than stopping at the first one.
+// The returned error wraps Diagnostics, which can be recovered with
+// Diagnostics.Append.
 func (g *AcyclicGraph) Validate() error {
+	var diags Diagnostics
 	if _, err := g.Root(); err != nil {
-		return err
+		diags = diags.Append(err)
 	}
 
-	// Look for cycles of more than 1 component
-	var diags Diagnostics
-	cycles := g.Cycles()
-	if len(cycles) > 0 {
-		for _, cycle := range cycles {
-			cycleStr := make([]string, len(cycle))
-			for j, vertex := range cycle {
-				cycleStr[j] = VertexName(vertex)
-			}
+	// Look for cycles, including cycles to self
+	diags = diags.Append(g.cycleDiagnostics())
 
-			diags = diags.Append(fmt.Errorf(
-				"Cycle: %s", strings.Join(cycleStr, ", ")))
-		}
-	}
+	// Include the edges rejected in strict mode
+	diags = diags.Append(g.rejected)
 
-	// Look for cycles to self
+	// Look for edges to vertices that were never added, or have since been
+	// removed.
 	for _, e := range g.Edges() {
-		if hashcode(e.Source()) == hashcode(e.Target()) {
-			diags = diags.Append(fmt.Errorf(
-				"Self reference: %s", VertexName(e.Source())))
+		for _, v := range []Vertex{e.Source(), e.Target()} {
+			if !g.HasVertex(v) {
+				diags = diags.Append(fmt.Errorf(
+					"Dangling edge: %s -> %s references missing vertex %s",
+					VertexName(e.Source()), VertexName(e.Target()), VertexName(v)))
+			}
 		}
 	}
 
 	return diags.Err()
 }
 
-// Cycles reports any cycles between graph nodes.
+// Cycles reports any cycles between graph nodes. Each cycle is a strongly
+// connected component of more than one vertex, as found by StronglyConnected.
 // Self-referencing nodes are not reported, and must be detected separately.
 func (g *AcyclicGraph) Cycles() [][]Vertex {
 	var cycles [][]Vertex
@@ -156,16 +481,329 @@ func (g *AcyclicGraph) Cycles() [][]Vertex {
 	return cycles
 }
 
+// TopologicalSort returns every vertex in the graph in dependency order:
+// the targets of an edge are always ordered before its source, which is the
+// same order in which Walk would visit them. Whenever several vertices have
+// all of their dependencies ordered, the first of them by VertexName comes
+// next, so the result is stable.
+//
+// An error describing the offending vertices is returned if the graph
+// contains a cycle.
+//
+// Complexity: O(V log V + E)
+func (g *AcyclicGraph) TopologicalSort() ([]Vertex, error) {
+	vertices := g.Vertices()
+
+	// Count the outstanding dependencies for each vertex, seeding the ready
+	// list with everything that has none. Edges to vertices that were never
+	// added to the graph can't be satisfied, so they aren't counted.
+	pending := make(map[interface{}]int, len(vertices))
+	var ready namedVertexHeap
+	for _, v := range vertices {
+		n := 0
+		for _, dep := range g.downEdgesNoCopy(v) {
+			if g.vertices.Include(dep) {
+				n++
+			}
+		}
+		pending[hashcode(v)] = n
+		if n == 0 {
+			ready = append(ready, namedVertex{name: VertexName(v), v: v})
+		}
+	}
+	heap.Init(&ready)
+
+	result := make([]Vertex, 0, len(vertices))
+	for len(ready) > 0 {
+		current := heap.Pop(&ready).(namedVertex).v
+		result = append(result, current)
+
+		// Release any dependents that were only waiting on this vertex.
+		for _, raw := range g.upEdgesNoCopy(current) {
+			code := hashcode(raw)
+			if _, ok := pending[code]; !ok {
+				continue
+			}
+			pending[code]--
+			if pending[code] == 0 {
+				heap.Push(&ready, namedVertex{name: VertexName(raw), v: raw})
+			}
+		}
+	}
+
+	if len(result) != len(vertices) {
+		return nil, g.cycleDiagnostics().Err()
+	}
+
+	return result, nil
+}
+
+// TopologicalLevels groups every vertex in the graph into layers, where the
+// dependencies of each vertex (the targets of its edges) are all in earlier
+// layers. The first layer holds the vertices with no dependencies, and each
+// vertex is placed in the earliest layer possible, so every vertex within a
+// layer can be visited in parallel. Each layer is sorted by VertexName.
+//
+// An error describing the offending vertices is returned if the graph
+// contains a cycle.
+//
+// Complexity: O(V log V + E)
+func (g *AcyclicGraph) TopologicalLevels() ([][]Vertex, error) {
+	order, err := g.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	// Dependencies always precede their dependents in order, so the level
+	// of each dependency is known by the time it's needed.
+	level := make(map[interface{}]int, len(order))
+	var levels [][]Vertex
+	for _, v := range order {
+		l := 0
+		for _, dep := range g.downEdgesNoCopy(v) {
+			if depLevel, ok := level[hashcode(dep)]; ok && depLevel+1 > l {
+				l = depLevel + 1
+			}
+		}
+		level[hashcode(v)] = l
+
+		if l == len(levels) {
+			levels = append(levels, nil)
+		}
+		levels[l] = append(levels[l], v)
+	}
+
+	for _, l := range levels {
+		sort.Sort(byVertexName(l))
+	}
+
+	return levels, nil
+}
+
+// cycleDiagnostics describes every cycle in the graph, including vertices that
+// reference themselves.
+func (g *AcyclicGraph) cycleDiagnostics() Diagnostics {
+	var diags Diagnostics
+	for _, cycle := range g.Cycles() {
+		cycleStr := make([]string, len(cycle))
+		for j, vertex := range cycle {
+			cycleStr[j] = VertexName(vertex)
+		}
+
+		diags = diags.Append(fmt.Errorf(
+			"Cycle: %s", strings.Join(cycleStr, ", ")))
+	}
+
+	for _, e := range g.Edges() {
+		if hashcode(e.Source()) == hashcode(e.Target()) {
+			diags = diags.Append(fmt.Errorf(
+				"Self reference: %s", VertexName(e.Source())))
+		}
+	}
+
+	return diags
+}
+
 // Walk walks the graph, calling your callback as each node is visited.
-// This will walk nodes in parallel if it can. The resulting diagnostics
-// contains problems from all graphs visited, in no particular order.
+// This will walk nodes in parallel if it can. A vertex is only visited once
+// all of its dependencies (the targets of its edges) have been visited
+// successfully; if any dependency returns errors, the vertex and everything
+// that depends on it is skipped. The resulting diagnostics contains problems
+// from all graphs visited, in no particular order.
 func (g *AcyclicGraph) Walk(cb WalkFunc) Diagnostics {
-	w := &Walker{Callback: cb, Reverse: true}
+	return g.WalkWithOpts(cb, nil)
+}
+
+// WalkWithOpts walks the graph in parallel like Walk, using the given options
+// to control the walk. If opts is nil, this is the same as Walk.
+func (g *AcyclicGraph) WalkWithOpts(cb WalkFunc, opts *WalkOpts) Diagnostics {
+	if opts == nil {
+		opts = &WalkOpts{}
+	}
+
+	w := &Walker{
+		Callback:          cb,
+		Reverse:           true,
+		OnError:           opts.OnError,
+		ConcurrencyLimits: opts.ConcurrencyLimits,
+		VertexTimeout:     opts.VertexTimeout,
+		Parallelism:       opts.Parallelism,
+		Limiter:           opts.Limiter,
+		Completed:         opts.Completed,
+		Metrics:           opts.Metrics,
+	}
+	if opts.EdgeLabels != nil {
+		labels := make(map[string]bool, len(opts.EdgeLabels))
+		for _, l := range opts.EdgeLabels {
+			labels[l] = true
+		}
+		g = g.filterEdges(func(e Edge) bool { return labels[edgeLabel(e)] })
+	}
+	if opts.EdgeFilter != nil {
+		g = g.filterEdges(opts.EdgeFilter)
+	}
+	if opts.Subgraphs {
+		// The subgraphs share the limiter, so that Parallelism limits the
+		// whole walk.
+		if w.Limiter == nil && w.Parallelism > 0 {
+			w.Limiter = NewLimiter(w.Parallelism)
+		}
+		subOpts := *opts
+		subOpts.Limiter = w.Limiter
+		w.afterVisit = func(v Vertex) Diagnostics {
+			sg, ok := marshalSubgrapher(v)
+			if !ok {
+				return nil
+			}
+			// The subgraph is only read, like the graph itself, so it is
+			// walked as it is rather than through a snapshot, which would
+			// mark the caller's graph as shared from within the walk.
+			return (&AcyclicGraph{Graph: *sg}).WalkWithOpts(cb, &subOpts)
+		}
+	}
 	w.Update(g)
 	return w.Wait()
 }
 
-// simple convenience helper for converting a dag.Set to a []Vertex
+// WalkTowards walks the targets and everything they depend on, in parallel
+// like Walk, skipping every other vertex. Dependencies shared by several
+// targets are only walked once. Nothing is walked if any target is missing
+// from the graph.
+func (g *AcyclicGraph) WalkTowards(targets []Vertex, cb WalkFunc) Diagnostics {
+	var diags Diagnostics
+	keep := make(Set)
+	for _, t := range targets {
+		if !g.HasVertex(t) {
+			diags = diags.Append(fmt.Errorf("target %s is not in the graph", VertexName(t)))
+			continue
+		}
+
+		deps, err := g.Descendants(t)
+		if err != nil {
+			diags = diags.Append(err)
+			continue
+		}
+
+		keep.Add(t)
+		for _, dep := range deps {
+			keep.Add(dep)
+		}
+	}
+	if diags.HasErrors() {
+		return diags
+	}
+
+	return g.Filter(func(v Vertex) bool { return keep.Include(v) }, nil).Walk(cb)
+}
+
+// WalkExcluding walks the graph in parallel like Walk, skipping the excluded
+// vertices and everything that depends on them. A warning is included in the
+// result for each skipped vertex, so callers can report what wasn't walked.
+// Excluded vertices which aren't in the graph are ignored.
+func (g *AcyclicGraph) WalkExcluding(excluded []Vertex, cb WalkFunc) Diagnostics {
+	skip := make(Set)
+	for _, v := range excluded {
+		if !g.HasVertex(v) {
+			continue
+		}
+
+		dependents, err := g.Ancestors(v)
+		if err != nil {
+			return Diagnostics{}.Append(err)
+		}
+
+		skip.Add(v)
+		for _, d := range dependents {
+			skip.Add(d)
+		}
+	}
+
+	skipped := AsVertexList(skip)
+	sort.Sort(byVertexName(skipped))
+
+	var diags Diagnostics
+	for _, v := range skipped {
+		reason := "It depends on an excluded vertex."
+		for _, e := range excluded {
+			if hashcode(e) == hashcode(v) {
+				reason = "It was excluded."
+			}
+		}
+		diags = diags.Append(skippedVertex{v: v, reason: reason})
+	}
+
+	walked := g.Filter(func(v Vertex) bool { return !skip.Include(v) }, nil)
+	return diags.Append(walked.Walk(cb))
+}
+
+// WalkPlan describes how Walk would visit the vertices of a graph, without
+// visiting them.
+type WalkPlan struct {
+	// Order is every vertex in an order they could be visited one at a
+	// time, with each level in turn.
+	Order []Vertex
+
+	// Levels groups the vertices into the steps of a walk, where every
+	// vertex in a level can be visited in parallel once the levels before it
+	// are complete. See TopologicalLevels.
+	Levels [][]Vertex
+
+	// MaxParallelism is the size of the largest level, which is the most
+	// vertices that can be visited at once.
+	MaxParallelism int
+
+	level map[interface{}]int
+}
+
+// Plan returns the plan for walking the graph, for previewing a walk or
+// checking one in tests. An error is returned if the graph has a cycle.
+func (g *AcyclicGraph) Plan() (*WalkPlan, error) {
+	levels, err := g.TopologicalLevels()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &WalkPlan{
+		Levels: levels,
+		level:  make(map[interface{}]int),
+	}
+	for i, l := range levels {
+		p.Order = append(p.Order, l...)
+		for _, v := range l {
+			p.level[hashcode(v)] = i
+		}
+		if len(l) > p.MaxParallelism {
+			p.MaxParallelism = len(l)
+		}
+	}
+
+	return p, nil
+}
+
+// Level returns the index of the level containing v, or -1 if v isn't part
+// of the plan.
+func (p *WalkPlan) Level(v Vertex) int {
+	if l, ok := p.level[hashcode(v)]; ok {
+		return l
+	}
+	return -1
+}
+
+// String outputs each level of the plan on a line, numbered from 1.
+func (p *WalkPlan) String() string {
+	var buf bytes.Buffer
+	for i, l := range p.Levels {
+		names := make([]string, len(l))
+		for j, v := range l {
+			names[j] = VertexName(v)
+		}
+		buf.WriteString(fmt.Sprintf("%d: %s\n", i+1, strings.Join(names, ", ")))
+	}
+	return buf.String()
+}
+
+// simple convenience helper for converting a dag.Set to a []Vertex. See
+// TypedList for other element types.
 func AsVertexList(s Set) []Vertex {
 	vertexList := make([]Vertex, 0, len(s))
 	for _, raw := range s {
@@ -184,14 +822,65 @@ type vertexAtDepth struct {
 // The algorithm used here does not do a complete topological sort. To ensure
 // correct overall ordering run TransitiveReduction first.
 func (g *AcyclicGraph) DepthFirstWalk(start Set, f DepthWalkFunc) error {
-	seen := make(map[Vertex]struct{})
-	frontier := make([]*vertexAtDepth, 0, len(start))
+	return g.DepthFirstWalkCtx(context.Background(), start, f)
+}
+
+// DepthFirstWalkCtx is like DepthFirstWalk, but stops the walk and returns
+// ctx.Err() once ctx is cancelled or its deadline is exceeded.
+func (g *AcyclicGraph) DepthFirstWalkCtx(ctx context.Context, start Set, f DepthWalkFunc) error {
+	return g.depthFirstWalk(ctx, start, false, f)
+}
+
+// depthFirstWalk is DepthFirstWalkCtx, or ReverseDepthFirstWalkCtx if up is
+// set. Edges are followed through the adjacency of the graph when it is worth
+// having, and otherwise through its maps, which the walk switches to if f
+// changes the graph so that the rest of the walk sees the change.
+func (g *AcyclicGraph) depthFirstWalk(ctx context.Context, start Set, up bool, f DepthWalkFunc) error {
+	// a is nil while following the maps of the graph. The number of each
+	// vertex in a is kept alongside it, or -1 for a vertex which a doesn't
+	// have, as that is only known to have no edges.
+	a := g.walkAdjacency()
+	type item struct {
+		v        Vertex
+		i, depth int
+	}
+	var seenIDs bitset
+	if a != nil {
+		seenIDs = newBitset(len(a.vertices))
+	}
+	seen := make(map[interface{}]struct{})
+	walked := 0
+	defer func() { g.addWalked(walked) }()
+
+	frontier := make([]item, 0, len(start))
 	for _, v := range start {
-		frontier = append(frontier, &vertexAtDepth{
-			Vertex: v,
-			Depth:  0,
-		})
+		frontier = append(frontier, item{v: v, i: a.id(v)})
 	}
+
+	// push adds the vertices at the other end of the edges of current.
+	push := func(current item) {
+		switch {
+		case a == nil && up:
+			for _, v := range g.upEdgesNoCopy(current.v) {
+				frontier = append(frontier, item{v: v, i: -1, depth: current.depth + 1})
+				walked++
+			}
+		case a == nil:
+			for _, v := range g.downEdgesNoCopy(current.v) {
+				frontier = append(frontier, item{v: v, i: -1, depth: current.depth + 1})
+				walked++
+			}
+		case current.i >= 0:
+			next := a.targets(current.i)
+			if up {
+				next = a.sources(current.i)
+			}
+			for _, j := range next {
+				frontier = append(frontier, item{v: a.vertices[j], i: j, depth: current.depth + 1})
+			}
+		}
+	}
+
 	for len(frontier) > 0 {
 		// Pop the current vertex
 		n := len(frontier)
@@ -199,31 +888,121 @@ func (g *AcyclicGraph) DepthFirstWalk(start Set, f DepthWalkFunc) error {
 		frontier = frontier[:n-1]
 
 		// Check if we've seen this already and return...
-		if _, ok := seen[hashcode(current.Vertex)]; ok {
-			continue
+		if a != nil && current.i >= 0 {
+			if seenIDs.has(current.i) {
+				continue
+			}
+			seenIDs.set(current.i)
+		} else {
+			code := hashcode(current.v)
+			if _, ok := seen[code]; ok {
+				continue
+			}
+			seen[code] = struct{}{}
+		}
+
+		// Stop early if the walk has been cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Walking up, the edges are found before visiting the vertex
+		if up {
+			push(current)
 		}
-		seen[hashcode(current.Vertex)] = struct{}{}
 
 		// Visit the current node
-		if err := f(current.Vertex, current.Depth); err != nil {
+		if err := f(current.v, current.depth); err != nil {
 			return err
 		}
 
-		for _, v := range g.downEdgesNoCopy(current.Vertex) {
-			frontier = append(frontier, &vertexAtDepth{
-				Vertex: v,
-				Depth:  current.Depth + 1,
-			})
+		// If f changed the graph, carry on through its maps
+		if a != nil && g.adj != a {
+			for i, v := range a.vertices {
+				if seenIDs.has(i) {
+					seen[hashcode(v)] = struct{}{}
+				}
+			}
+			a = nil
+		}
+
+		if !up {
+			push(current)
 		}
 	}
 
 	return nil
 }
 
+// DepthFirstWalkFiltered is like DepthFirstWalk, but only follows the edges
+// for which edgeFilter returns true.
+func (g *AcyclicGraph) DepthFirstWalkFiltered(start Set, edgeFilter func(Edge) bool, f DepthWalkFunc) error {
+	return g.filterEdges(edgeFilter).DepthFirstWalk(start, f)
+}
+
+// BreadthFirstWalkFiltered is like BreadthFirstWalk, but only follows the
+// edges for which edgeFilter returns true.
+func (g *AcyclicGraph) BreadthFirstWalkFiltered(start Set, edgeFilter func(Edge) bool, f BreadthWalkFunc) error {
+	return g.filterEdges(edgeFilter).BreadthFirstWalk(start, f)
+}
+
 // BreadthFirstWalk does a breadth-first walk of the graph starting from
 // the vertices in start.
 func (g *AcyclicGraph) BreadthFirstWalk(start Set, f BreadthWalkFunc) error {
-	seen := make(map[Vertex]struct{})
+	return g.BreadthFirstWalkCtx(context.Background(), start, f)
+}
+
+// BreadthFirstWalkCtx is like BreadthFirstWalk, but stops the walk and returns
+// ctx.Err() once ctx is cancelled or its deadline is exceeded.
+func (g *AcyclicGraph) BreadthFirstWalkCtx(ctx context.Context, start Set, f BreadthWalkFunc) error {
+	return g.breadthFirstWalk(ctx, AsVertexList(start), g.downEdgesNoCopy, false, f)
+}
+
+// SortedBreadthFirstWalk does a breadth-first walk of the graph starting from
+// the vertices in start, always iterating the nodes in a consistent order.
+func (g *AcyclicGraph) SortedBreadthFirstWalk(start []Vertex, f BreadthWalkFunc) error {
+	return g.SortedBreadthFirstWalkCtx(context.Background(), start, f)
+}
+
+// SortedBreadthFirstWalkCtx is like SortedBreadthFirstWalk, but stops the walk
+// and returns ctx.Err() once ctx is cancelled or its deadline is exceeded.
+func (g *AcyclicGraph) SortedBreadthFirstWalkCtx(ctx context.Context, start []Vertex, f BreadthWalkFunc) error {
+	return g.breadthFirstWalk(ctx, start, g.downEdgesNoCopy, true, f)
+}
+
+// ReverseBreadthFirstWalk does a breadth-first walk _up_ the graph starting
+// from the vertices in start, visiting the ancestors of the start vertices
+// level by level.
+func (g *AcyclicGraph) ReverseBreadthFirstWalk(start Set, f BreadthWalkFunc) error {
+	return g.ReverseBreadthFirstWalkCtx(context.Background(), start, f)
+}
+
+// ReverseBreadthFirstWalkCtx is like ReverseBreadthFirstWalk, but stops the
+// walk and returns ctx.Err() once ctx is cancelled or its deadline is
+// exceeded.
+func (g *AcyclicGraph) ReverseBreadthFirstWalkCtx(ctx context.Context, start Set, f BreadthWalkFunc) error {
+	return g.breadthFirstWalk(ctx, AsVertexList(start), g.upEdgesNoCopy, false, f)
+}
+
+// SortedReverseBreadthFirstWalk does a breadth-first walk _up_ the graph
+// starting from the vertices in start, always iterating the nodes in a
+// consistent order.
+func (g *AcyclicGraph) SortedReverseBreadthFirstWalk(start []Vertex, f BreadthWalkFunc) error {
+	return g.SortedReverseBreadthFirstWalkCtx(context.Background(), start, f)
+}
+
+// SortedReverseBreadthFirstWalkCtx is like SortedReverseBreadthFirstWalk, but
+// stops the walk and returns ctx.Err() once ctx is cancelled or its deadline
+// is exceeded.
+func (g *AcyclicGraph) SortedReverseBreadthFirstWalkCtx(ctx context.Context, start []Vertex, f BreadthWalkFunc) error {
+	return g.breadthFirstWalk(ctx, start, g.upEdgesNoCopy, true, f)
+}
+
+// breadthFirstWalk does a breadth-first walk starting from the vertices in
+// start, following the edges returned by next. If sorted is true, the
+// vertices reached from each vertex are visited in order of VertexName.
+func (g *AcyclicGraph) breadthFirstWalk(ctx context.Context, start []Vertex, next func(Vertex) Set, sorted bool, f BreadthWalkFunc) error {
+	seen := make(map[interface{}]struct{})
 	frontier := make([]*vertexAtDepth, 0, len(start))
 	for _, v := range start {
 		frontier = append(frontier, &vertexAtDepth{
@@ -244,12 +1023,21 @@ func (g *AcyclicGraph) BreadthFirstWalk(start Set, f BreadthWalkFunc) error {
 			}
 			seen[hashcode(current.Vertex)] = struct{}{}
 
+			// Stop early if the walk has been cancelled
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
 			// Visit the nodes in frontier
 			if err := f(current.Vertex, current.Depth); err != nil {
 				return err
 			}
 
-			for _, v := range g.downEdgesNoCopy(current.Vertex) {
+			targets := AsVertexList(next(current.Vertex))
+			if sorted {
+				sort.Sort(byVertexName(targets))
+			}
+			for _, v := range targets {
 				frontier = append(frontier, &vertexAtDepth{
 					Vertex: v,
 					Depth:  current.Depth + 1,
@@ -266,7 +1054,13 @@ func (g *AcyclicGraph) BreadthFirstWalk(start Set, f BreadthWalkFunc) error {
 // SortedDepthFirstWalk does a depth-first walk of the graph starting from
 // the vertices in start, always iterating the nodes in a consistent order.
 func (g *AcyclicGraph) SortedDepthFirstWalk(start []Vertex, f DepthWalkFunc) error {
-	seen := make(map[Vertex]struct{})
+	return g.SortedDepthFirstWalkCtx(context.Background(), start, f)
+}
+
+// SortedDepthFirstWalkCtx is like SortedDepthFirstWalk, but stops the walk and
+// returns ctx.Err() once ctx is cancelled or its deadline is exceeded.
+func (g *AcyclicGraph) SortedDepthFirstWalkCtx(ctx context.Context, start []Vertex, f DepthWalkFunc) error {
+	seen := make(map[interface{}]struct{})
 	frontier := make([]*vertexAtDepth, len(start))
 	for i, v := range start {
 		frontier[i] = &vertexAtDepth{
@@ -281,10 +1075,15 @@ func (g *AcyclicGraph) SortedDepthFirstWalk(start []Vertex, f DepthWalkFunc) err
 		frontier = frontier[:n-1]
 
 		// Check if we've seen this already and return...
-		if _, ok := seen[current.Vertex]; ok {
+		if _, ok := seen[hashcode(current.Vertex)]; ok {
 			continue
 		}
-		seen[current.Vertex] = struct{}{}
+		seen[hashcode(current.Vertex)] = struct{}{}
+
+		// Stop early if the walk has been cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		// Visit the current node
 		if err := f(current.Vertex, current.Depth); err != nil {
@@ -311,46 +1110,26 @@ func (g *AcyclicGraph) SortedDepthFirstWalk(start []Vertex, f DepthWalkFunc) err
 // The algorithm used here does not do a complete topological sort. To ensure
 // correct overall ordering run TransitiveReduction first.
 func (g *AcyclicGraph) ReverseDepthFirstWalk(start Set, f DepthWalkFunc) error {
-	seen := make(map[Vertex]struct{})
-	frontier := make([]*vertexAtDepth, 0, len(start))
-	for _, v := range start {
-		frontier = append(frontier, &vertexAtDepth{
-			Vertex: v,
-			Depth:  0,
-		})
-	}
-	for len(frontier) > 0 {
-		// Pop the current vertex
-		n := len(frontier)
-		current := frontier[n-1]
-		frontier = frontier[:n-1]
-
-		// Check if we've seen this already and return...
-		if _, ok := seen[current.Vertex]; ok {
-			continue
-		}
-		seen[current.Vertex] = struct{}{}
-
-		for _, t := range g.upEdgesNoCopy(current.Vertex) {
-			frontier = append(frontier, &vertexAtDepth{
-				Vertex: t,
-				Depth:  current.Depth + 1,
-			})
-		}
-
-		// Visit the current node
-		if err := f(current.Vertex, current.Depth); err != nil {
-			return err
-		}
-	}
+	return g.ReverseDepthFirstWalkCtx(context.Background(), start, f)
+}
 
-	return nil
+// ReverseDepthFirstWalkCtx is like ReverseDepthFirstWalk, but stops the walk
+// and returns ctx.Err() once ctx is cancelled or its deadline is exceeded.
+func (g *AcyclicGraph) ReverseDepthFirstWalkCtx(ctx context.Context, start Set, f DepthWalkFunc) error {
+	return g.depthFirstWalk(ctx, start, true, f)
 }
 
 // SortedReverseDepthFirstWalk does a depth-first walk _up_ the graph starting from
 // the vertices in start, always iterating the nodes in a consistent order.
 func (g *AcyclicGraph) SortedReverseDepthFirstWalk(start []Vertex, f DepthWalkFunc) error {
-	seen := make(map[Vertex]struct{})
+	return g.SortedReverseDepthFirstWalkCtx(context.Background(), start, f)
+}
+
+// SortedReverseDepthFirstWalkCtx is like SortedReverseDepthFirstWalk, but stops
+// the walk and returns ctx.Err() once ctx is cancelled or its deadline is
+// exceeded.
+func (g *AcyclicGraph) SortedReverseDepthFirstWalkCtx(ctx context.Context, start []Vertex, f DepthWalkFunc) error {
+	seen := make(map[interface{}]struct{})
 	frontier := make([]*vertexAtDepth, len(start))
 	for i, v := range start {
 		frontier[i] = &vertexAtDepth{
@@ -365,10 +1144,15 @@ func (g *AcyclicGraph) SortedReverseDepthFirstWalk(start []Vertex, f DepthWalkFu
 		frontier = frontier[:n-1]
 
 		// Check if we've seen this already and return...
-		if _, ok := seen[current.Vertex]; ok {
+		if _, ok := seen[hashcode(current.Vertex)]; ok {
 			continue
 		}
-		seen[current.Vertex] = struct{}{}
+		seen[hashcode(current.Vertex)] = struct{}{}
+
+		// Stop early if the walk has been cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		// Add next set of targets in a consistent order.
 		targets := AsVertexList(g.upEdgesNoCopy(current.Vertex))
@@ -398,3 +1182,49 @@ func (b byVertexName) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
 func (b byVertexName) Less(i, j int) bool {
 	return VertexName(b[i]) < VertexName(b[j])
 }
+
+// namedVertex is a vertex along with its VertexName, for ordering by name
+// without finding the name for every comparison.
+type namedVertex struct {
+	name string
+	v    Vertex
+}
+
+// namedVertexHeap implements heap.Interface, with the vertex first by
+// VertexName on top.
+type namedVertexHeap []namedVertex
+
+func (h namedVertexHeap) Len() int           { return len(h) }
+func (h namedVertexHeap) Less(i, j int) bool { return h[i].name < h[j].name }
+func (h namedVertexHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *namedVertexHeap) Push(x interface{}) {
+	*h = append(*h, x.(namedVertex))
+}
+
+func (h *namedVertexHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	v := old[n-1]
+	*h = old[:n-1]
+	return v
+}
+
+// byEdgeName implements sort.Interface so a list of Edges can be sorted
+// consistently by the VertexName of their source and target, and then by
+// their label.
+type byEdgeName []Edge
+
+func (b byEdgeName) Len() int      { return len(b) }
+func (b byEdgeName) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byEdgeName) Less(i, j int) bool {
+	si, sj := VertexName(b[i].Source()), VertexName(b[j].Source())
+	if si != sj {
+		return si < sj
+	}
+	ti, tj := VertexName(b[i].Target()), VertexName(b[j].Target())
+	if ti != tj {
+		return ti < tj
+	}
+	return edgeLabel(b[i]) < edgeLabel(b[j])
+}
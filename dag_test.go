@@ -1,14 +1,18 @@
 package dag
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"math/rand"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestMain(m *testing.M) {
@@ -31,6 +35,39 @@ func TestAcyclicGraphRoot(t *testing.T) {
 	}
 }
 
+func TestAcyclicGraphEnsureSingleRoot(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 3))
+	g.Connect(BasicEdge(2, 3))
+
+	root := g.EnsureSingleRoot("root")
+	if VertexName(root) != "root" {
+		t.Fatalf("bad root: %#v", root)
+	}
+	if actual, err := g.Root(); err != nil || actual != root {
+		t.Fatalf("bad: %#v, %v", actual, err)
+	}
+	if again := g.EnsureSingleRoot("other"); again != root {
+		t.Fatalf("should reuse the existing root, got %#v", again)
+	}
+
+	// the virtual root isn't drawn
+	dot := string(g.Dot(nil))
+	if strings.Contains(dot, `"[root] root"`) {
+		t.Fatalf("virtual root drawn:\n%s", dot)
+	}
+	if !strings.Contains(dot, `"[root] 1" -> "[root] 3"`) {
+		t.Fatalf("missing edge:\n%s", dot)
+	}
+
+	if (&AcyclicGraph{}).EnsureSingleRoot("root") != nil {
+		t.Fatal("an empty graph has no root")
+	}
+}
+
 func TestAcyclicGraphRoot_cycle(t *testing.T) {
 	var g AcyclicGraph
 	g.Add(1)
@@ -57,6 +94,78 @@ func TestAcyclicGraphRoot_multiple(t *testing.T) {
 	}
 }
 
+func TestAcyclicGraphRootsLeaves(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Add(5)
+	g.Connect(BasicEdge(1, 3))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 4))
+	g.Connect(BasicEdge(3, 5))
+
+	roots := g.Roots()
+	if expected := []Vertex{1, 2}; !reflect.DeepEqual(roots, expected) {
+		t.Fatalf("expected roots: %#v, got: %#v", expected, roots)
+	}
+
+	leaves := g.Leaves()
+	if expected := []Vertex{4, 5}; !reflect.DeepEqual(leaves, expected) {
+		t.Fatalf("expected leaves: %#v, got: %#v", expected, leaves)
+	}
+}
+
+func TestAcyclicGraphRootsLeaves_empty(t *testing.T) {
+	var g AcyclicGraph
+	if roots := g.Roots(); len(roots) != 0 {
+		t.Fatalf("bad: %#v", roots)
+	}
+	if leaves := g.Leaves(); len(leaves) != 0 {
+		t.Fatalf("bad: %#v", leaves)
+	}
+}
+
+func TestAcyclicGraphConnectChecked(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+
+	if err := g.ConnectChecked(BasicEdge(1, 2)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := g.ConnectChecked(BasicEdge(2, 3)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := g.ConnectChecked(BasicEdge(1, 3)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := g.ConnectChecked(BasicEdge(3, 1))
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "1 -> 3 -> 1") {
+		t.Fatalf("expected the shortest cycle to be reported, got: %s", err)
+	}
+	if g.HasEdge(BasicEdge(3, 1)) {
+		t.Fatal("edge should not have been added")
+	}
+
+	if err := g.ConnectChecked(BasicEdge(2, 2)); err == nil {
+		t.Fatal("should error")
+	}
+	if g.HasEdge(BasicEdge(2, 2)) {
+		t.Fatal("edge should not have been added")
+	}
+
+	if err := g.Validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestAyclicGraphTransReduction(t *testing.T) {
 	var g AcyclicGraph
 	g.Add(1)
@@ -127,6 +236,35 @@ func TestAyclicGraphTransReduction_multipleRoots(t *testing.T) {
 	}
 }
 
+func TestAyclicGraphTransReduction_labeled(t *testing.T) {
+	build := func() *AcyclicGraph {
+		var g AcyclicGraph
+		g.Add(1)
+		g.Add(2)
+		g.Add(3)
+		g.Connect(BasicEdge(1, 2))
+		g.Connect(BasicEdge(2, 3))
+		g.Connect(BasicLabeledEdge(1, 3, "build"))
+		g.Connect(BasicLabeledEdge(1, 3, "runtime"))
+		return &g
+	}
+
+	g := build()
+	g.TransitiveReduction()
+	if es := g.EdgesBetween(1, 3); len(es) != 0 {
+		t.Fatalf("redundant edges left: %#v", es)
+	}
+
+	g = build()
+	g.transitiveReductionDFS()
+	if es := g.EdgesBetween(1, 3); len(es) != 0 {
+		t.Fatalf("redundant edges left by DFS: %#v", es)
+	}
+	if err := CheckInvariants(&g.Graph); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // use this to simulate slow sort operations
 type counter struct {
 	Name  string
@@ -138,6 +276,37 @@ func (s *counter) String() string {
 	return s.Name
 }
 
+// The reduction by topological order must match the search from each edge.
+func TestAyclicGraphTransReduction_random(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		var g AcyclicGraph
+		for v := 0; v < 40; v++ {
+			g.Add(v)
+			for u := 0; u < v; u++ {
+				if r.Intn(4) == 0 {
+					g.Connect(BasicEdge(v, u))
+				}
+			}
+		}
+
+		expected := g.Clone()
+		expected.transitiveReductionDFS()
+		g.TransitiveReduction()
+
+		if actual, want := g.String(), expected.String(); actual != want {
+			t.Fatalf("bad reduction:\n%s\n\nexpected:\n%s", actual, want)
+		}
+		for _, u := range g.Vertices() {
+			for _, v := range g.Vertices() {
+				if g.Reachable(u, v) != expected.Reachable(u, v) {
+					t.Fatalf("bad reachability from %v to %v", u, v)
+				}
+			}
+		}
+	}
+}
+
 // Make sure we can reduce a sizable, fully-connected graph.
 func TestAyclicGraphTransReduction_fullyConnected(t *testing.T) {
 	var g AcyclicGraph
@@ -221,144 +390,923 @@ func TestAcyclicGraphValidate_cycleSelf(t *testing.T) {
 	}
 }
 
-func TestAcyclicGraphAncestors(t *testing.T) {
+func TestAcyclicGraphCycles(t *testing.T) {
 	var g AcyclicGraph
 	g.Add(1)
 	g.Add(2)
 	g.Add(3)
 	g.Add(4)
 	g.Add(5)
-	g.Connect(BasicEdge(0, 1))
 	g.Connect(BasicEdge(1, 2))
-	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(2, 1))
 	g.Connect(BasicEdge(3, 4))
 	g.Connect(BasicEdge(4, 5))
+	g.Connect(BasicEdge(5, 3))
 
-	actual, err := g.Ancestors(2)
+	cycles := g.Cycles()
+	if len(cycles) != 2 {
+		t.Fatalf("expected 2 cycles, got: %#v", cycles)
+	}
+
+	var actual []string
+	for _, cycle := range cycles {
+		names := make([]string, len(cycle))
+		for i, v := range cycle {
+			names[i] = VertexName(v)
+		}
+		sort.Strings(names)
+		actual = append(actual, strings.Join(names, ","))
+	}
+	sort.Strings(actual)
+
+	expected := []string{"1,2", "3,4,5"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, actual)
+	}
+}
+
+func TestAcyclicGraphCycles_none(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 3))
+
+	if cycles := g.Cycles(); len(cycles) != 0 {
+		t.Fatalf("expected no cycles, got: %#v", cycles)
+	}
+}
+
+func TestAcyclicGraphTopologicalSort(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Connect(BasicEdge(4, 3))
+	g.Connect(BasicEdge(4, 1))
+	g.Connect(BasicEdge(3, 2))
+	g.Connect(BasicEdge(2, 1))
+
+	actual, err := g.TopologicalSort()
 	if err != nil {
-		t.Fatalf("err: %#v", err)
+		t.Fatalf("err: %s", err)
 	}
 
-	expected := []Vertex{0, 1}
+	expected := []Vertex{1, 2, 3, 4}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, actual)
+	}
+}
 
-	if actual.Len() != len(expected) {
-		t.Fatalf("bad length! expected %#v to have len %d", actual, len(expected))
+func TestAcyclicGraphTopologicalSort_independent(t *testing.T) {
+	var g AcyclicGraph
+	g.Add("c")
+	g.Add("b")
+	g.Add("a")
+	g.Add("root")
+	g.Connect(BasicEdge("root", "c"))
+	g.Connect(BasicEdge("root", "b"))
+	g.Connect(BasicEdge("root", "a"))
+
+	actual, err := g.TopologicalSort()
+	if err != nil {
+		t.Fatalf("err: %s", err)
 	}
 
-	for _, e := range expected {
-		if !actual.Include(e) {
-			t.Fatalf("expected: %#v to include: %#v", expected, actual)
-		}
+	expected := []Vertex{"a", "b", "c", "root"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, actual)
 	}
 }
 
-func TestAcyclicGraphDescendents(t *testing.T) {
+// A vertex which becomes ready later still comes before the vertices
+// already waiting which sort after it.
+func TestAcyclicGraphTopologicalSort_byName(t *testing.T) {
+	var g AcyclicGraph
+	g.Add("a")
+	g.Add("b")
+	g.Add("z")
+	g.Connect(BasicEdge("a", "b"))
+
+	actual, err := g.TopologicalSort()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Vertex{"b", "a", "z"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, actual)
+	}
+}
+
+func TestAcyclicGraphTopologicalSort_cycle(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 2))
+
+	_, err := g.TopologicalSort()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "Cycle") {
+		t.Fatalf("expected cycle error, got: %s", err)
+	}
+}
+
+func TestAcyclicGraphTopologicalSort_cycleSelf(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Connect(BasicEdge(2, 1))
+	g.Connect(BasicEdge(1, 1))
+
+	_, err := g.TopologicalSort()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "Self reference") {
+		t.Fatalf("expected self reference error, got: %s", err)
+	}
+}
+
+func TestAcyclicGraphValidate_dangling(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+
+	err := g.Validate()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "missing vertex 3") {
+		t.Fatalf("expected dangling edge error, got: %s", err)
+	}
+}
+
+func TestAcyclicGraphValidate_multiple(t *testing.T) {
 	var g AcyclicGraph
 	g.Add(1)
 	g.Add(2)
 	g.Add(3)
 	g.Add(4)
 	g.Add(5)
-	g.Connect(BasicEdge(0, 1))
 	g.Connect(BasicEdge(1, 2))
 	g.Connect(BasicEdge(2, 3))
-	g.Connect(BasicEdge(3, 4))
-	g.Connect(BasicEdge(4, 5))
+	g.Connect(BasicEdge(3, 2))
+	g.Connect(BasicEdge(4, 4))
+	g.Connect(BasicEdge(4, 6))
 
-	actual, err := g.Descendants(2)
-	if err != nil {
-		t.Fatalf("err: %#v", err)
+	err := g.Validate()
+	if err == nil {
+		t.Fatal("should error")
 	}
 
-	expected := []Vertex{3, 4, 5}
-
-	if actual.Len() != len(expected) {
-		t.Fatalf("bad length! expected %#v to have len %d", actual, len(expected))
+	var diags Diagnostics
+	diags = diags.Append(err)
+	if len(diags) != 4 {
+		t.Fatalf("expected 4 problems, got: %s", err)
 	}
 
-	for _, e := range expected {
-		if !actual.Include(e) {
-			t.Fatalf("expected: %#v to include: %#v", expected, actual)
+	for _, want := range []string{"multiple roots", "Cycle", "Self reference", "Dangling edge"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in: %s", want, err)
 		}
 	}
 }
 
-func TestAcyclicGraphWalk(t *testing.T) {
+func TestAcyclicGraphTopologicalLevels(t *testing.T) {
 	var g AcyclicGraph
 	g.Add(1)
 	g.Add(2)
 	g.Add(3)
-	g.Connect(BasicEdge(3, 2))
+	g.Add(4)
+	g.Add(5)
+	g.Connect(BasicEdge(5, 4))
+	g.Connect(BasicEdge(5, 1))
+	g.Connect(BasicEdge(4, 3))
+	g.Connect(BasicEdge(4, 2))
 	g.Connect(BasicEdge(3, 1))
 
-	var visits []Vertex
-	var lock sync.Mutex
-	err := g.Walk(func(v Vertex) Diagnostics {
-		lock.Lock()
-		defer lock.Unlock()
-		visits = append(visits, v)
-		return nil
-	})
+	actual, err := g.TopologicalLevels()
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
 
 	expected := [][]Vertex{
-		{1, 2, 3},
-		{2, 1, 3},
+		{1, 2},
+		{3},
+		{4},
+		{5},
 	}
-	for _, e := range expected {
-		if reflect.DeepEqual(visits, e) {
-			return
-		}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, actual)
 	}
-
-	t.Fatalf("bad: %#v", visits)
 }
 
-func TestAcyclicGraphWalk_error(t *testing.T) {
+func TestAcyclicGraphTopologicalLevels_cycle(t *testing.T) {
 	var g AcyclicGraph
 	g.Add(1)
 	g.Add(2)
-	g.Add(3)
-	g.Add(4)
-	g.Connect(BasicEdge(4, 3))
-	g.Connect(BasicEdge(3, 2))
+	g.Connect(BasicEdge(1, 2))
 	g.Connect(BasicEdge(2, 1))
 
-	var visits []Vertex
+	if _, err := g.TopologicalLevels(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestAcyclicGraphWalkTowards(t *testing.T) {
+	var g AcyclicGraph
+	for _, v := range []string{"a", "b", "c", "shared", "base", "other"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("a", "shared"))
+	g.Connect(BasicEdge("b", "shared"))
+	g.Connect(BasicEdge("c", "b"))
+	g.Connect(BasicEdge("shared", "base"))
+	g.Connect(BasicEdge("other", "base"))
+
 	var lock sync.Mutex
-	err := g.Walk(func(v Vertex) Diagnostics {
+	var visits []string
+	diags := g.WalkTowards([]Vertex{"a", "b"}, func(v Vertex) Diagnostics {
 		lock.Lock()
 		defer lock.Unlock()
-
-		var diags Diagnostics
-
-		if v == 2 {
-			diags = diags.Append(fmt.Errorf("error"))
-			return diags
-		}
-
-		visits = append(visits, v)
-		return diags
+		visits = append(visits, v.(string))
+		return nil
 	})
-	if err == nil {
-		t.Fatal("should error")
+	if diags.HasErrors() {
+		t.Fatal(diags.Err())
 	}
 
-	expected := []Vertex{1}
-	if !reflect.DeepEqual(visits, expected) {
-		t.Errorf("wrong visits\ngot:  %#v\nwant: %#v", visits, expected)
+	// dependencies are walked first
+	if len(visits) != 4 || visits[0] != "base" || visits[1] != "shared" {
+		t.Fatalf("bad visits: %#v", visits)
+	}
+	sort.Strings(visits)
+	if !reflect.DeepEqual(visits, []string{"a", "b", "base", "shared"}) {
+		t.Fatalf("bad visits: %#v", visits)
 	}
 
+	diags = g.WalkTowards([]Vertex{"missing"}, func(v Vertex) Diagnostics {
+		t.Fatalf("should not walk %s", v)
+		return nil
+	})
+	if !diags.HasErrors() {
+		t.Fatal("should error for a missing target")
+	}
 }
 
-func BenchmarkDAG(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		count := 150
-		b.StopTimer()
-		g := &AcyclicGraph{}
-
+func TestAcyclicGraphWalkExcluding(t *testing.T) {
+	var g AcyclicGraph
+	for _, v := range []string{"app", "db", "cache", "net", "web", "docs"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("web", "app"))
+	g.Connect(BasicEdge("app", "db"))
+	g.Connect(BasicEdge("app", "cache"))
+	g.Connect(BasicEdge("db", "net"))
+	g.Connect(BasicEdge("cache", "net"))
+
+	var lock sync.Mutex
+	var visits []string
+	diags := g.WalkExcluding([]Vertex{"db", "missing"}, func(v Vertex) Diagnostics {
+		lock.Lock()
+		defer lock.Unlock()
+		visits = append(visits, v.(string))
+		return nil
+	})
+	if diags.HasErrors() {
+		t.Fatal(diags.Err())
+	}
+
+	sort.Strings(visits)
+	if !reflect.DeepEqual(visits, []string{"cache", "docs", "net"}) {
+		t.Fatalf("bad visits: %#v", visits)
+	}
+
+	var skipped []string
+	for _, d := range diags {
+		if d.Severity() != Warning {
+			t.Fatalf("expected a warning, got %#v", d)
+		}
+		skipped = append(skipped, d.Description().Summary)
+	}
+	expected := []string{"Skipped app", "Skipped db", "Skipped web"}
+	if !reflect.DeepEqual(skipped, expected) {
+		t.Fatalf("bad skipped: %#v", skipped)
+	}
+	if diags[1].Description().Detail != "It was excluded." {
+		t.Fatalf("bad detail: %q", diags[1].Description().Detail)
+	}
+}
+
+func TestAcyclicGraphPlan(t *testing.T) {
+	var g AcyclicGraph
+	for _, v := range []string{"app", "db", "cache", "net", "web"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("web", "app"))
+	g.Connect(BasicEdge("app", "db"))
+	g.Connect(BasicEdge("app", "cache"))
+	g.Connect(BasicEdge("db", "net"))
+	g.Connect(BasicEdge("cache", "net"))
+
+	p, err := g.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := strings.TrimSpace(p.String())
+	expected := "1: net\n2: cache, db\n3: app\n4: web"
+	if actual != expected {
+		t.Fatalf("bad plan:\n%s", actual)
+	}
+
+	if p.MaxParallelism != 2 {
+		t.Fatalf("bad parallelism: %d", p.MaxParallelism)
+	}
+	if len(p.Order) != 5 || p.Order[0] != "net" || p.Order[4] != "web" {
+		t.Fatalf("bad order: %#v", p.Order)
+	}
+	if p.Level("db") != 1 || p.Level("missing") != -1 {
+		t.Fatalf("bad levels: %d, %d", p.Level("db"), p.Level("missing"))
+	}
+
+	g.Connect(BasicEdge("net", "web"))
+	if _, err := g.Plan(); err == nil {
+		t.Fatal("should error for a cycle")
+	}
+}
+
+func TestAcyclicGraphAtDepth(t *testing.T) {
+	var g AcyclicGraph
+	for i := 1; i <= 5; i++ {
+		g.Add(i)
+	}
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 4))
+	g.Connect(BasicEdge(1, 4))
+	g.Connect(BasicEdge(4, 5))
+
+	cases := []struct {
+		Name     string
+		F        func(Vertex, int) (Set, error)
+		V        Vertex
+		Depth    int
+		Expected []Vertex
+	}{
+		{"descendants 1", g.DescendantsAtDepth, 1, 1, []Vertex{2, 4}},
+		{"descendants 2", g.DescendantsAtDepth, 1, 2, []Vertex{2, 3, 4, 5}},
+		{"descendants 0", g.DescendantsAtDepth, 1, 0, nil},
+		{"descendants all", g.DescendantsAtDepth, 2, -1, []Vertex{3, 4, 5}},
+		{"ancestors 1", g.AncestorsAtDepth, 4, 1, []Vertex{1, 3}},
+		{"ancestors 2", g.AncestorsAtDepth, 5, 2, []Vertex{1, 3, 4}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := tc.F(tc.V, tc.Depth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual.Len() != len(tc.Expected) {
+				t.Fatalf("expected %#v, got %#v", tc.Expected, actual.List())
+			}
+			for _, v := range tc.Expected {
+				if !actual.Include(v) {
+					t.Fatalf("expected %#v, got %#v", tc.Expected, actual.List())
+				}
+			}
+		})
+	}
+
+	if _, err := g.AncestorsAtDepth(42, 1); err == nil {
+		t.Fatal("should error for a missing vertex")
+	}
+}
+
+func TestAcyclicGraphFind(t *testing.T) {
+	var g AcyclicGraph
+	for _, v := range []string{"app", "lib-a", "lib-b", "mid", "lib-c", "base"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("app", "mid"))
+	g.Connect(BasicEdge("app", "lib-b"))
+	g.Connect(BasicEdge("mid", "lib-a"))
+	g.Connect(BasicEdge("lib-b", "lib-c"))
+	g.Connect(BasicEdge("lib-a", "base"))
+	g.Connect(BasicEdge("lib-c", "base"))
+
+	isLib := func(v Vertex) bool { return strings.HasPrefix(v.(string), "lib-") }
+
+	if v, ok := g.FindDown("app", isLib); !ok || v != "lib-b" {
+		t.Fatalf("FindDown: got %v, %t", v, ok)
+	}
+	if v, ok := g.FindDown("mid", isLib); !ok || v != "lib-a" {
+		t.Fatalf("FindDown: got %v, %t", v, ok)
+	}
+	if v, ok := g.FindUp("base", isLib); !ok || v != "lib-a" {
+		t.Fatalf("FindUp: got %v, %t", v, ok)
+	}
+	if _, ok := g.FindUp("lib-b", isLib); ok {
+		t.Fatal("FindUp should not consider the vertex itself")
+	}
+	if _, ok := g.FindDown("base", isLib); ok {
+		t.Fatal("FindDown should find nothing below a leaf")
+	}
+}
+
+func TestAcyclicGraphImpact(t *testing.T) {
+	var g AcyclicGraph
+	for i := 1; i <= 6; i++ {
+		g.Add(i)
+	}
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(4, 3))
+	g.Connect(BasicEdge(5, 6))
+
+	cases := []struct {
+		Name     string
+		Changed  []Vertex
+		Opts     *ImpactOpts
+		Expected []Vertex
+	}{
+		{"leaf", []Vertex{3}, nil, []Vertex{1, 2, 3, 4}},
+		{"several", []Vertex{2, 6}, nil, []Vertex{1, 2, 5, 6}},
+		{"root", []Vertex{1}, nil, []Vertex{1}},
+		{"reverse", []Vertex{1, 4}, &ImpactOpts{Reverse: true}, []Vertex{1, 2, 3, 4}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := g.ImpactWithOpts(tc.Changed, tc.Opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual.Len() != len(tc.Expected) {
+				t.Fatalf("expected %#v, got %#v", tc.Expected, actual.List())
+			}
+			for _, v := range tc.Expected {
+				if !actual.Include(v) {
+					t.Fatalf("expected %#v, got %#v", tc.Expected, actual.List())
+				}
+			}
+		})
+	}
+
+	_, err := g.Impact([]Vertex{1, 7, 8})
+	if err == nil || !strings.Contains(err.Error(), "vertex 7") || !strings.Contains(err.Error(), "vertex 8") {
+		t.Fatalf("expected an error for each missing vertex, got %v", err)
+	}
+}
+
+func TestAcyclicGraphAncestors(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Add(5)
+	g.Connect(BasicEdge(0, 1))
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 4))
+	g.Connect(BasicEdge(4, 5))
+
+	actual, err := g.Ancestors(2)
+	if err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+
+	expected := []Vertex{0, 1}
+
+	if actual.Len() != len(expected) {
+		t.Fatalf("bad length! expected %#v to have len %d", actual, len(expected))
+	}
+
+	for _, e := range expected {
+		if !actual.Include(e) {
+			t.Fatalf("expected: %#v to include: %#v", expected, actual)
+		}
+	}
+}
+
+func TestAcyclicGraphDescendents(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Add(5)
+	g.Connect(BasicEdge(0, 1))
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 4))
+	g.Connect(BasicEdge(4, 5))
+
+	actual, err := g.Descendants(2)
+	if err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+
+	expected := []Vertex{3, 4, 5}
+
+	if actual.Len() != len(expected) {
+		t.Fatalf("bad length! expected %#v to have len %d", actual, len(expected))
+	}
+
+	for _, e := range expected {
+		if !actual.Include(e) {
+			t.Fatalf("expected: %#v to include: %#v", expected, actual)
+		}
+	}
+}
+
+func TestAcyclicGraphWalk(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(3, 2))
+	g.Connect(BasicEdge(3, 1))
+
+	var visits []Vertex
+	var lock sync.Mutex
+	err := g.Walk(func(v Vertex) Diagnostics {
+		lock.Lock()
+		defer lock.Unlock()
+		visits = append(visits, v)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := [][]Vertex{
+		{1, 2, 3},
+		{2, 1, 3},
+	}
+	for _, e := range expected {
+		if reflect.DeepEqual(visits, e) {
+			return
+		}
+	}
+
+	t.Fatalf("bad: %#v", visits)
+}
+
+func TestAcyclicGraphWalk_error(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Connect(BasicEdge(4, 3))
+	g.Connect(BasicEdge(3, 2))
+	g.Connect(BasicEdge(2, 1))
+
+	var visits []Vertex
+	var lock sync.Mutex
+	err := g.Walk(func(v Vertex) Diagnostics {
+		lock.Lock()
+		defer lock.Unlock()
+
+		var diags Diagnostics
+
+		if v == 2 {
+			diags = diags.Append(fmt.Errorf("error"))
+			return diags
+		}
+
+		visits = append(visits, v)
+		return diags
+	})
+	if err == nil {
+		t.Fatal("should error")
+	}
+
+	expected := []Vertex{1}
+	if !reflect.DeepEqual(visits, expected) {
+		t.Errorf("wrong visits\ngot:  %#v\nwant: %#v", visits, expected)
+	}
+
+}
+
+func TestAcyclicGraphWalkWithOpts_continue(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(3, 2))
+	g.Connect(BasicEdge(2, 1))
+
+	var visits []Vertex
+	var lock sync.Mutex
+	diags := g.WalkWithOpts(func(v Vertex) Diagnostics {
+		lock.Lock()
+		defer lock.Unlock()
+		visits = append(visits, v)
+
+		var diags Diagnostics
+		if v == 1 {
+			diags = diags.Append(fmt.Errorf("error"))
+		}
+		return diags
+	}, &WalkOpts{OnError: OnErrorContinue})
+	if !diags.HasErrors() {
+		t.Fatal("should error")
+	}
+
+	expected := []Vertex{1, 2, 3}
+	if !reflect.DeepEqual(visits, expected) {
+		t.Errorf("wrong visits\ngot:  %#v\nwant: %#v", visits, expected)
+	}
+}
+
+func TestAcyclicGraphWalkWithOpts_edgeLabels(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicLabeledEdge(2, 1, "build"))
+	g.Connect(BasicLabeledEdge(3, 1, "runtime"))
+
+	var visits []Vertex
+	var lock sync.Mutex
+	diags := g.WalkWithOpts(func(v Vertex) Diagnostics {
+		lock.Lock()
+		defer lock.Unlock()
+
+		var diags Diagnostics
+		if v == 1 {
+			return diags.Append(fmt.Errorf("error"))
+		}
+		visits = append(visits, v)
+		return diags
+	}, &WalkOpts{EdgeLabels: []string{"build"}})
+	if !diags.HasErrors() {
+		t.Fatal("should error")
+	}
+
+	// only 2 waits for 1, through its build dependency
+	expected := []Vertex{3}
+	if !reflect.DeepEqual(visits, expected) {
+		t.Errorf("wrong visits\ngot:  %#v\nwant: %#v", visits, expected)
+	}
+}
+
+func TestAcyclicGraphWalkWithOpts_edgeFilter(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicWeightedEdge(2, 1, 2))
+	g.Connect(BasicEdge(3, 1))
+
+	var visits []Vertex
+	var lock sync.Mutex
+	diags := g.WalkWithOpts(func(v Vertex) Diagnostics {
+		lock.Lock()
+		defer lock.Unlock()
+
+		var diags Diagnostics
+		if v == 1 {
+			return diags.Append(fmt.Errorf("error"))
+		}
+		visits = append(visits, v)
+		return diags
+	}, &WalkOpts{EdgeFilter: func(e Edge) bool { return edgeWeight(e) > 1 }})
+	if !diags.HasErrors() {
+		t.Fatal("should error")
+	}
+
+	// only 2 waits for 1, through its heavier edge
+	expected := []Vertex{3}
+	if !reflect.DeepEqual(visits, expected) {
+		t.Errorf("wrong visits\ngot:  %#v\nwant: %#v", visits, expected)
+	}
+}
+
+func TestAcyclicGraphWalkWithOpts_subgraphs(t *testing.T) {
+	var sub AcyclicGraph
+	sub.Add(10)
+	sub.Add(11)
+	sub.Connect(BasicEdge(10, 11))
+
+	var g AcyclicGraph
+	s := &testSubgrapher{name: "sub", graph: &sub}
+	g.Add(1)
+	g.Add(s)
+	g.Add(3)
+	g.Connect(BasicEdge(s, 1))
+	g.Connect(BasicEdge(3, s))
+
+	var visits []interface{}
+	diags := g.WalkWithOpts(walkCbRecord(&visits), &WalkOpts{Subgraphs: true})
+	if err := diags.Err(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// the subgraph is walked after its vertex, and before its dependents
+	expected := []interface{}{1, s, 11, 10, 3}
+	if !reflect.DeepEqual(visits, expected) {
+		t.Errorf("wrong visits\ngot:  %#v\nwant: %#v", visits, expected)
+	}
+
+	// without the option, the subgraph is left alone
+	visits = nil
+	if err := g.Walk(walkCbRecord(&visits)).Err(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected = []interface{}{1, s, 3}
+	if !reflect.DeepEqual(visits, expected) {
+		t.Errorf("wrong visits\ngot:  %#v\nwant: %#v", visits, expected)
+	}
+}
+
+func TestAcyclicGraphWalkWithOpts_subgraphError(t *testing.T) {
+	var sub AcyclicGraph
+	sub.Add(10)
+	sub.Add(11)
+	sub.Connect(BasicEdge(10, 11))
+
+	var g AcyclicGraph
+	s := &testSubgrapher{name: "sub", graph: &sub}
+	g.Add(s)
+	g.Add(3)
+	g.Connect(BasicEdge(3, s))
+
+	var visits []Vertex
+	var lock sync.Mutex
+	diags := g.WalkWithOpts(func(v Vertex) Diagnostics {
+		lock.Lock()
+		defer lock.Unlock()
+
+		var diags Diagnostics
+		if v == 11 {
+			return diags.Append(fmt.Errorf("error"))
+		}
+		visits = append(visits, v)
+		return diags
+	}, &WalkOpts{Subgraphs: true, Parallelism: 1, Limiter: NewLimiter(1)})
+	if !diags.HasErrors() {
+		t.Fatal("should error")
+	}
+
+	// the failure inside the subgraph skips what depends on its vertex
+	expected := []Vertex{s}
+	if !reflect.DeepEqual(visits, expected) {
+		t.Errorf("wrong visits\ngot:  %#v\nwant: %#v", visits, expected)
+	}
+	if got := diags.ByVertex(); len(got["11"]) != 1 {
+		t.Errorf("error should be attributed to 11, got %#v", got)
+	}
+}
+
+// The timeout of a vertex doesn't include the walk of its subgraph.
+func TestAcyclicGraphWalkWithOpts_subgraphTimeout(t *testing.T) {
+	var sub AcyclicGraph
+	sub.Add(10)
+	sub.Add(11)
+	sub.Add(12)
+	sub.Connect(BasicEdge(10, 11))
+	sub.Connect(BasicEdge(11, 12))
+
+	var g AcyclicGraph
+	s := &testSubgrapher{name: "sub", graph: &sub}
+	g.Add(s)
+	g.Add(3)
+	g.Connect(BasicEdge(3, s))
+
+	diags := g.WalkWithOpts(func(v Vertex) Diagnostics {
+		if v != s {
+			time.Sleep(40 * time.Millisecond)
+		}
+		return nil
+	}, &WalkOpts{Subgraphs: true, VertexTimeout: 100 * time.Millisecond, Parallelism: 1})
+	if err := diags.Err(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// Vertices may share a subgraph, which is walked for each of them without
+// being modified.
+func TestAcyclicGraphWalkWithOpts_subgraphShared(t *testing.T) {
+	var sub AcyclicGraph
+	sub.Add(10)
+	sub.Add(11)
+	sub.Connect(BasicEdge(10, 11))
+
+	var g AcyclicGraph
+	a := &testSubgrapher{name: "a", graph: &sub}
+	b := &testSubgrapher{name: "b", graph: &sub}
+	g.Add(a)
+	g.Add(b)
+
+	var lock sync.Mutex
+	counts := make(map[Vertex]int)
+	diags := g.WalkWithOpts(func(v Vertex) Diagnostics {
+		lock.Lock()
+		defer lock.Unlock()
+		counts[v]++
+		return nil
+	}, &WalkOpts{Subgraphs: true})
+	if err := diags.Err(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[Vertex]int{a: 1, b: 1, 10: 2, 11: 2}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("wrong visits\ngot:  %#v\nwant: %#v", counts, expected)
+	}
+	if sub.shared {
+		t.Error("walking the subgraph should leave it unshared")
+	}
+
+	// a vertex which is already complete has its subgraph skipped with it
+	counts = make(map[Vertex]int)
+	diags = g.WalkWithOpts(func(v Vertex) Diagnostics {
+		lock.Lock()
+		defer lock.Unlock()
+		counts[v]++
+		return nil
+	}, &WalkOpts{Subgraphs: true, Completed: func(v Vertex) bool { return v == a }})
+	if err := diags.Err(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected = map[Vertex]int{b: 1, 10: 1, 11: 1}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("wrong visits\ngot:  %#v\nwant: %#v", counts, expected)
+	}
+}
+
+func TestAcyclicGraphWalk_parallel(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(3, 2))
+	g.Connect(BasicEdge(3, 1))
+
+	// 1 and 2 each wait for the other to start, which can only succeed if
+	// they are visited concurrently.
+	started := map[Vertex]chan struct{}{
+		1: make(chan struct{}),
+		2: make(chan struct{}),
+	}
+	other := map[Vertex]Vertex{1: 2, 2: 1}
+
+	var lock sync.Mutex
+	done := make(map[Vertex]bool)
+	diags := g.Walk(func(v Vertex) Diagnostics {
+		var diags Diagnostics
+
+		switch v {
+		case 1, 2:
+			close(started[v])
+			select {
+			case <-started[other[v]]:
+			case <-time.After(5 * time.Second):
+				return diags.Append(fmt.Errorf("%v was not walked in parallel", v))
+			}
+
+		case 3:
+			lock.Lock()
+			ready := done[1] && done[2]
+			lock.Unlock()
+			if !ready {
+				return diags.Append(fmt.Errorf("3 visited before its dependencies"))
+			}
+		}
+
+		lock.Lock()
+		done[v] = true
+		lock.Unlock()
+		return nil
+	})
+	if err := diags.Err(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !done[3] {
+		t.Fatal("3 was not visited")
+	}
+}
+
+func BenchmarkDAG(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		count := 150
+		b.StopTimer()
+		g := &AcyclicGraph{}
+
 		// create 4 layers of fully connected nodes
 		// layer A
 		for i := 0; i < count; i++ {
@@ -410,6 +1358,184 @@ func BenchmarkDAG(b *testing.B) {
 	}
 }
 
+// benchmarkLayeredGraph returns a graph of layers of width vertices, each
+// with an edge to fanout vertices in every earlier layer, so that most edges
+// are redundant.
+func benchmarkLayeredGraph(layers, width, fanout int) *AcyclicGraph {
+	g := &AcyclicGraph{}
+	for l := 0; l < layers; l++ {
+		for i := 0; i < width; i++ {
+			v := fmt.Sprintf("%d-%d", l, i)
+			g.Add(v)
+			for prev := 0; prev < l; prev++ {
+				for j := 0; j < fanout; j++ {
+					g.Connect(BasicEdge(v, fmt.Sprintf("%d-%d", prev, (i*7+j)%width)))
+				}
+			}
+		}
+	}
+	return g
+}
+
+func BenchmarkAcyclicGraphConnect_large(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchmarkLayeredGraph(10, 1000, 10)
+	}
+}
+
+func BenchmarkAcyclicGraphDepthFirstWalk_large(b *testing.B) {
+	g := benchmarkLayeredGraph(10, 1000, 10)
+	start := make(Set)
+	for _, v := range g.Roots() {
+		start.Add(v)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.DepthFirstWalk(start, func(Vertex, int) error { return nil })
+	}
+}
+
+func BenchmarkAcyclicGraphTransitiveReduction_large(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		g := benchmarkLayeredGraph(6, 200, 5)
+		b.StartTimer()
+
+		g.TransitiveReduction()
+	}
+}
+
+func TestAcyclicGraphWalkCtx_cancel(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 4))
+
+	walks := map[string]func(context.Context, DepthWalkFunc) error{
+		"DepthFirstWalkCtx": func(ctx context.Context, f DepthWalkFunc) error {
+			return g.DepthFirstWalkCtx(ctx, g.DownEdges(1), f)
+		},
+		"BreadthFirstWalkCtx": func(ctx context.Context, f DepthWalkFunc) error {
+			return g.BreadthFirstWalkCtx(ctx, g.DownEdges(1), BreadthWalkFunc(f))
+		},
+		"SortedDepthFirstWalkCtx": func(ctx context.Context, f DepthWalkFunc) error {
+			return g.SortedDepthFirstWalkCtx(ctx, []Vertex{1}, f)
+		},
+		"ReverseDepthFirstWalkCtx": func(ctx context.Context, f DepthWalkFunc) error {
+			return g.ReverseDepthFirstWalkCtx(ctx, g.UpEdges(4), f)
+		},
+		"SortedReverseDepthFirstWalkCtx": func(ctx context.Context, f DepthWalkFunc) error {
+			return g.SortedReverseDepthFirstWalkCtx(ctx, []Vertex{4}, f)
+		},
+		"SortedBreadthFirstWalkCtx": func(ctx context.Context, f DepthWalkFunc) error {
+			return g.SortedBreadthFirstWalkCtx(ctx, []Vertex{1}, BreadthWalkFunc(f))
+		},
+		"ReverseBreadthFirstWalkCtx": func(ctx context.Context, f DepthWalkFunc) error {
+			return g.ReverseBreadthFirstWalkCtx(ctx, g.UpEdges(4), BreadthWalkFunc(f))
+		},
+		"SortedReverseBreadthFirstWalkCtx": func(ctx context.Context, f DepthWalkFunc) error {
+			return g.SortedReverseBreadthFirstWalkCtx(ctx, []Vertex{4}, BreadthWalkFunc(f))
+		},
+	}
+
+	for name, walk := range walks {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var visits []Vertex
+			err := walk(ctx, func(v Vertex, d int) error {
+				visits = append(visits, v)
+				cancel()
+				return nil
+			})
+			if err != context.Canceled {
+				t.Fatalf("expected context.Canceled, got: %v", err)
+			}
+			if len(visits) != 1 {
+				t.Fatalf("expected a single visit, got: %#v", visits)
+			}
+		})
+	}
+}
+
+func TestAcyclicGraphSortedBreadthFirstWalks(t *testing.T) {
+	var g AcyclicGraph
+	for _, v := range []string{"a", "b", "c", "d", "e"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("a", "c"))
+	g.Connect(BasicEdge("a", "b"))
+	g.Connect(BasicEdge("b", "d"))
+	g.Connect(BasicEdge("c", "e"))
+	g.Connect(BasicEdge("e", "d"))
+
+	walks := map[string]struct {
+		walk     func([]Vertex, BreadthWalkFunc) error
+		start    Vertex
+		expected []string
+	}{
+		"SortedBreadthFirstWalk": {
+			g.SortedBreadthFirstWalk, "a",
+			[]string{"a:0", "b:1", "c:1", "d:2", "e:2"},
+		},
+		"SortedReverseBreadthFirstWalk": {
+			g.SortedReverseBreadthFirstWalk, "d",
+			[]string{"d:0", "b:1", "e:1", "a:2", "c:2"},
+		},
+	}
+	for name, tc := range walks {
+		t.Run(name, func(t *testing.T) {
+			var visits []string
+			err := tc.walk([]Vertex{tc.start}, func(v Vertex, d int) error {
+				visits = append(visits, fmt.Sprintf("%s:%d", VertexName(v), d))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(visits, tc.expected) {
+				t.Fatalf("bad: %#v", visits)
+			}
+		})
+	}
+}
+
+func TestAcyclicGraphSortedWalks_hashcode(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(&hashVertex{code: 1})
+	g.Add(&hashVertex{code: 2})
+	g.Connect(BasicEdge(&hashVertex{code: 2}, &hashVertex{code: 1}))
+
+	walks := map[string]func([]Vertex, DepthWalkFunc) error{
+		"SortedDepthFirstWalk":        g.SortedDepthFirstWalk,
+		"SortedReverseDepthFirstWalk": g.SortedReverseDepthFirstWalk,
+	}
+	for name, walk := range walks {
+		t.Run(name, func(t *testing.T) {
+			var visits int
+			// distinct pointers with the same hashcodes as the vertices in
+			// the graph, so each should only be visited once
+			start := []Vertex{&hashVertex{code: 1}, &hashVertex{code: 2}}
+			err := walk(start, func(v Vertex, d int) error {
+				visits++
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if visits != 2 {
+				t.Fatalf("expected 2 visits, got %d", visits)
+			}
+		})
+	}
+}
+
 func TestAcyclicGraph_ReverseDepthFirstWalk_WithRemoval(t *testing.T) {
 	var g AcyclicGraph
 	g.Add(1)
@@ -0,0 +1,101 @@
+// Package dagtest provides assertions for testing code that builds or
+// orders a dag.Graph, reporting failures as readable lists of vertices and
+// edges instead of comparing rendered strings.
+package dagtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sgoings/dag"
+)
+
+// AssertEqual fails the test if got and want don't have the same vertices
+// and edges, as compared by dag.Equal, listing what got is missing with "-"
+// and what it has in addition with "+".
+func AssertEqual(t testing.TB, got, want *dag.Graph) {
+	t.Helper()
+	if diff := want.DiffWithOpts(got, &dag.DiffOpts{EdgeData: true}); !diff.Empty() {
+		t.Errorf("graphs differ (-want +got):\n%s", diff)
+	}
+}
+
+// AssertAcyclic fails the test if g has any cycles, including edges from a
+// vertex to itself, listing the vertices in each one.
+func AssertAcyclic(t testing.TB, g *dag.AcyclicGraph) {
+	t.Helper()
+
+	var problems []string
+	for _, cycle := range g.Cycles() {
+		problems = append(problems, "cycle: "+names(cycle))
+	}
+	for _, e := range g.SortedEdges() {
+		if dag.VertexName(e.Source()) == dag.VertexName(e.Target()) {
+			problems = append(problems, "self reference: "+dag.VertexName(e.Source()))
+		}
+	}
+
+	if len(problems) > 0 {
+		t.Errorf("graph is not acyclic:\n%s", strings.Join(problems, "\n"))
+	}
+}
+
+// AssertTopoOrder fails the test if order does not list each vertex of g
+// exactly once, with the target of every edge before its source, which is
+// the order in which Walk visits them. Each edge ordered the wrong way is
+// listed.
+func AssertTopoOrder(t testing.TB, g *dag.AcyclicGraph, order []dag.Vertex) {
+	t.Helper()
+
+	var problems []string
+	// Vertices are identified by name, so that order can be built from
+	// copies of the vertices in g.
+	inGraph := make(map[string]bool)
+	for _, v := range g.Vertices() {
+		inGraph[dag.VertexName(v)] = true
+	}
+	position := make(map[string]int, len(order))
+	for i, v := range order {
+		if !inGraph[dag.VertexName(v)] {
+			problems = append(problems, fmt.Sprintf("%s is not in the graph", dag.VertexName(v)))
+			continue
+		}
+		if _, ok := position[dag.VertexName(v)]; ok {
+			problems = append(problems, fmt.Sprintf("%s is listed more than once", dag.VertexName(v)))
+			continue
+		}
+		position[dag.VertexName(v)] = i
+	}
+
+	for _, v := range g.SortedVertices() {
+		if _, ok := position[dag.VertexName(v)]; !ok {
+			problems = append(problems, fmt.Sprintf("%s is missing", dag.VertexName(v)))
+		}
+	}
+
+	for _, e := range g.SortedEdges() {
+		source, ok := position[dag.VertexName(e.Source())]
+		if !ok {
+			continue
+		}
+		target, ok := position[dag.VertexName(e.Target())]
+		if ok && source < target {
+			problems = append(problems, fmt.Sprintf("%s is before its dependency %s",
+				dag.VertexName(e.Source()), dag.VertexName(e.Target())))
+		}
+	}
+
+	if len(problems) > 0 {
+		t.Errorf("bad topological order %s:\n%s", names(order), strings.Join(problems, "\n"))
+	}
+}
+
+// names joins the names of vs, in order.
+func names(vs []dag.Vertex) string {
+	s := make([]string, len(vs))
+	for i, v := range vs {
+		s[i] = dag.VertexName(v)
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
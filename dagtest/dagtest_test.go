@@ -0,0 +1,108 @@
+package dagtest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sgoings/dag"
+)
+
+// recorder is a testing.TB that records failures instead of failing. Fatalf
+// panics with errFatal to stop the assertion, like FailNow would.
+type recorder struct {
+	testing.TB
+	failures []string
+	fatals   []string
+}
+
+var errFatal = errors.New("fatal")
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.fatals = append(r.fatals, fmt.Sprintf(format, args...))
+	panic(errFatal)
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) check(t *testing.T, want ...string) {
+	t.Helper()
+	if len(want) == 0 {
+		if len(r.failures) > 0 {
+			t.Fatalf("unexpected failure:\n%s", strings.Join(r.failures, "\n"))
+		}
+		return
+	}
+	if len(r.failures) != 1 {
+		t.Fatalf("expected one failure, got %q", r.failures)
+	}
+	for _, w := range want {
+		if !strings.Contains(r.failures[0], w) {
+			t.Fatalf("failure should contain %q:\n%s", w, r.failures[0])
+		}
+	}
+}
+
+func testGraph() *dag.AcyclicGraph {
+	var g dag.AcyclicGraph
+	g.Add("a")
+	g.Add("b")
+	g.Add("c")
+	g.Connect(dag.BasicEdge("a", "b"))
+	g.Connect(dag.BasicEdge("b", "c"))
+	return &g
+}
+
+func TestAssertEqual(t *testing.T) {
+	r := &recorder{TB: t}
+	AssertEqual(r, &testGraph().Graph, &testGraph().Graph)
+	r.check(t)
+
+	other := testGraph()
+	other.Add("d")
+	other.Connect(dag.BasicEdge("a", "d"))
+	r = &recorder{TB: t}
+	AssertEqual(r, &testGraph().Graph, &other.Graph)
+	r.check(t, "- a -> d\n- d\n")
+
+	weighted := testGraph()
+	weighted.RemoveEdge(dag.BasicEdge("a", "b"))
+	weighted.Connect(dag.BasicWeightedEdge("a", "b", 2))
+	r = &recorder{TB: t}
+	AssertEqual(r, &testGraph().Graph, &weighted.Graph)
+	r.check(t, "+ a -> b\n- a -> b [weight = 2]\n")
+}
+
+func TestAssertAcyclic(t *testing.T) {
+	r := &recorder{TB: t}
+	AssertAcyclic(r, testGraph())
+	r.check(t)
+
+	g := testGraph()
+	g.Connect(dag.BasicEdge("c", "a"))
+	g.Add("d")
+	g.Connect(dag.BasicEdge("d", "d"))
+	r = &recorder{TB: t}
+	AssertAcyclic(r, g)
+	r.check(t, "cycle: [", "self reference: d")
+}
+
+func TestAssertTopoOrder(t *testing.T) {
+	r := &recorder{TB: t}
+	AssertTopoOrder(r, testGraph(), []dag.Vertex{"c", "b", "a"})
+	r.check(t)
+
+	r = &recorder{TB: t}
+	AssertTopoOrder(r, testGraph(), []dag.Vertex{"b", "c", "x", "b"})
+	r.check(t,
+		"x is not in the graph",
+		"b is listed more than once",
+		"a is missing",
+		"b is before its dependency c",
+	)
+}
@@ -0,0 +1,165 @@
+package dagtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sgoings/dag"
+)
+
+// Update is set by the -update test flag, which makes Golden rewrite its
+// files with the current output instead of comparing against them. Test
+// packages using dagtest should read this rather than defining their own
+// -update flag, which would conflict with it.
+var Update = flag.Bool("update", false, "update the golden files of dagtest.Golden")
+
+// Golden fails the test if g differs from the golden file at path, which is
+// rewritten instead when the tests are run with -update.
+//
+// The format is chosen by the extension of path: ".dot" compares Dot output,
+// ".json" compares MarshalJSON output, and anything else compares String.
+// Before comparing, dot lines are trimmed and sorted, and JSON arrays are
+// sorted and the result indented, so that changes in ordering or whitespace
+// that don't change the graph don't fail the test.
+func Golden(t testing.TB, g *dag.Graph, path string) {
+	t.Helper()
+
+	got, err := goldenOutput(g, path)
+	if err != nil {
+		t.Fatalf("rendering %s: %s", path, err)
+	}
+
+	if *Update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file, run with -update to create it: %s", err)
+	}
+
+	gotNorm, err := normalize(path, got)
+	if err != nil {
+		t.Fatalf("normalizing output: %s", err)
+	}
+	wantNorm, err := normalize(path, want)
+	if err != nil {
+		t.Fatalf("normalizing %s: %s", path, err)
+	}
+	if gotNorm != wantNorm {
+		t.Errorf("output differs from %s (-want +got), run with -update to accept it:\n%s",
+			path, lineDiff(wantNorm, gotNorm))
+	}
+}
+
+// goldenOutput renders g in the format of the golden file at path.
+func goldenOutput(g *dag.Graph, path string) ([]byte, error) {
+	switch filepath.Ext(path) {
+	case ".dot":
+		return g.Dot(nil), nil
+	case ".json":
+		js, err := g.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, js, "", "  "); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	default:
+		return []byte(g.String()), nil
+	}
+}
+
+// normalize returns the canonical form of output in the format of path.
+func normalize(path string, output []byte) (string, error) {
+	switch filepath.Ext(path) {
+	case ".dot":
+		var lines []string
+		for _, line := range strings.Split(string(output), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		sort.Strings(lines)
+		return strings.Join(lines, "\n"), nil
+	case ".json":
+		var v interface{}
+		if err := json.Unmarshal(output, &v); err != nil {
+			return "", err
+		}
+		sortArrays(v)
+		js, err := json.MarshalIndent(v, "", "  ")
+		return string(js), err
+	default:
+		return strings.TrimSpace(string(output)), nil
+	}
+}
+
+// sortArrays sorts every array within a decoded JSON value by the encoding
+// of its elements. Objects are already encoded with sorted keys.
+func sortArrays(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, elem := range v {
+			sortArrays(elem)
+		}
+	case []interface{}:
+		type keyed struct {
+			key  string
+			elem interface{}
+		}
+		elems := make([]keyed, len(v))
+		for i, elem := range v {
+			sortArrays(elem)
+			js, _ := json.Marshal(elem)
+			elems[i] = keyed{string(js), elem}
+		}
+		sort.SliceStable(elems, func(i, j int) bool { return elems[i].key < elems[j].key })
+		for i := range elems {
+			v[i] = elems[i].elem
+		}
+	}
+}
+
+// lineDiff lists the lines only in want with "-", and those only in got with
+// "+".
+func lineDiff(want, got string) string {
+	count := make(map[string]int)
+	for _, line := range strings.Split(got, "\n") {
+		count[line]++
+	}
+	for _, line := range strings.Split(want, "\n") {
+		count[line]--
+	}
+
+	var diff []string
+	for _, line := range strings.Split(want, "\n") {
+		if count[line] < 0 {
+			diff = append(diff, "- "+line)
+			count[line]++
+		}
+	}
+	for _, line := range strings.Split(got, "\n") {
+		if count[line] > 0 {
+			diff = append(diff, "+ "+line)
+			count[line]--
+		}
+	}
+	return strings.Join(diff, "\n")
+}
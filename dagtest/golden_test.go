@@ -0,0 +1,90 @@
+package dagtest
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgoings/dag"
+)
+
+func TestGolden(t *testing.T) {
+	for _, ext := range []string{".dot", ".json", ".txt"} {
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "testdata", "graph"+ext)
+			g := &testGraph().Graph
+
+			*Update = true
+			Golden(t, g, path)
+			*Update = false
+
+			r := &recorder{TB: t}
+			Golden(r, g, path)
+			r.check(t)
+
+			other := testGraph()
+			other.Connect(dag.BasicEdge("a", "c"))
+			r = &recorder{TB: t}
+			Golden(r, &other.Graph, path)
+			r.check(t, "+ ")
+		})
+	}
+}
+
+func TestGolden_normalized(t *testing.T) {
+	dir := t.TempDir()
+	g := &testGraph().Graph
+
+	// The same graph as Dot would draw it, with the edges reordered and
+	// reindented.
+	dot := filepath.Join(dir, "graph.dot")
+	err := ioutil.WriteFile(dot, []byte(`digraph {
+  compound = "true"
+  newrank = "true"
+  subgraph "root" {
+    "[root] b" -> "[root] c"
+    "[root] a" -> "[root] b"
+  }
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &recorder{TB: t}
+	Golden(r, g, dot)
+	r.check(t)
+
+	// The JSON of the graph with its whitespace removed.
+	js := filepath.Join(dir, "graph.json")
+	*Update = true
+	Golden(t, g, js)
+	*Update = false
+	src, err := ioutil.ReadFile(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(src), "\n") {
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	if err := ioutil.WriteFile(js, []byte(strings.Join(lines, "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r = &recorder{TB: t}
+	Golden(r, g, js)
+	r.check(t)
+}
+
+func TestGolden_missing(t *testing.T) {
+	r := &recorder{TB: t}
+	defer func() {
+		if p := recover(); p != errFatal {
+			panic(p)
+		}
+		if len(r.fatals) != 1 || !strings.Contains(r.fatals[0], "-update") {
+			t.Fatalf("expected a fatal error suggesting -update, got %q", r.fatals)
+		}
+	}()
+	Golden(r, &testGraph().Graph, filepath.Join(t.TempDir(), "missing.dot"))
+}
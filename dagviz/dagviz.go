@@ -0,0 +1,204 @@
+// Package dagviz serves a dag.Graph over HTTP, for inspecting a live graph in
+// a browser while debugging.
+//
+// The handler serves:
+//
+//	/               an interactive page drawing the graph
+//	/graph.json     the graph as JSON, from Graph.MarshalJSON
+//	/graph.d3.json  the graph for d3-force layouts, from Graph.MarshalD3
+//	/graph.dot      the graph in the Graphviz dot format
+//	/graph.svg      the dot output rendered by Graphviz, if it is installed
+package dagviz
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os/exec"
+
+	"github.com/sgoings/dag"
+)
+
+// Serve serves g on addr until the server fails, as with
+// http.ListenAndServe. The graph is read again for every request, so
+// reloading the page shows its latest state. Like any other reader, a request
+// must not run concurrently with changes to g; use NewHandler with a function
+// returning snapshots to serve a graph that is being changed.
+func Serve(g *dag.Graph, addr string) error {
+	return http.ListenAndServe(addr, NewHandler(func() *dag.Graph { return g }))
+}
+
+// NewHandler returns an http.Handler serving the graph returned by source,
+// which is called once for each request.
+func NewHandler(source func() *dag.Graph) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(indexHTML))
+	})
+
+	mux.HandleFunc("/graph.json", func(w http.ResponseWriter, r *http.Request) {
+		js, err := source().MarshalJSON()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	})
+
+	mux.HandleFunc("/graph.d3.json", func(w http.ResponseWriter, r *http.Request) {
+		js, err := source().MarshalD3()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	})
+
+	mux.HandleFunc("/graph.dot", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		w.Write(source().Dot(nil))
+	})
+
+	mux.HandleFunc("/graph.svg", func(w http.ResponseWriter, r *http.Request) {
+		svg, err := renderSVG(source().Dot(nil))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write(svg)
+	})
+
+	return mux
+}
+
+// renderSVG renders dot source with the Graphviz dot command.
+func renderSVG(src []byte) ([]byte, error) {
+	path, err := exec.LookPath("dot")
+	if err != nil {
+		return nil, fmt.Errorf("rendering SVG needs Graphviz, which was not found: %s", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, "-Tsvg")
+	cmd.Stdin = bytes.NewReader(src)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("dot: %s: %s", err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// indexHTML draws the graph from /graph.d3.json, with each vertex in a
+// column by its depth below the roots. Clicking a vertex highlights what it
+// depends on in blue, and what depends on it in orange.
+const indexHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>dag</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+svg { border: 1px solid #ccc; }
+line { stroke: #999; }
+circle { fill: #fff; stroke: #333; stroke-width: 1.5; cursor: pointer; }
+circle.selected { fill: #333; }
+circle.down, line.down { stroke: #1f77b4; stroke-width: 3; }
+circle.up, line.up { stroke: #ff7f0e; stroke-width: 3; }
+text { font-size: 12px; pointer-events: none; }
+</style>
+</head>
+<body>
+<p>
+<a href="graph.json">JSON</a> |
+<a href="graph.d3.json">D3 JSON</a> |
+<a href="graph.dot">dot</a> |
+<a href="graph.svg">SVG</a>
+</p>
+<svg id="graph"></svg>
+<script>
+const ns = "http://www.w3.org/2000/svg";
+
+function el(name, attrs) {
+  const e = document.createElementNS(ns, name);
+  for (const k in attrs) e.setAttribute(k, attrs[k]);
+  return e;
+}
+
+fetch("graph.d3.json").then(r => r.json()).then(g => {
+  const down = g.nodes.map(() => []), up = g.nodes.map(() => []);
+  for (const l of g.links) {
+    down[l.source].push(l.target);
+    up[l.target].push(l.source);
+  }
+
+  // depth is the longest path from a root, so every edge points right
+  const depth = g.nodes.map(() => 0);
+  for (let changed = true, n = 0; changed && n < g.nodes.length; n++) {
+    changed = false;
+    for (const l of g.links) {
+      if (depth[l.target] < depth[l.source] + 1) {
+        depth[l.target] = depth[l.source] + 1;
+        changed = true;
+      }
+    }
+  }
+
+  const rows = {};
+  const pos = g.nodes.map(n => {
+    const d = depth[n.id];
+    rows[d] = (rows[d] || 0) + 1;
+    return {x: 80 + d * 180, y: rows[d] * 40};
+  });
+
+  const svg = document.getElementById("graph");
+  svg.setAttribute("width", 160 + Math.max(0, ...depth) * 180);
+  svg.setAttribute("height", 40 + Math.max(0, ...Object.values(rows)) * 40);
+
+  const lines = g.links.map(l => svg.appendChild(el("line", {
+    x1: pos[l.source].x, y1: pos[l.source].y,
+    x2: pos[l.target].x, y2: pos[l.target].y,
+  })));
+  const circles = g.nodes.map(n => {
+    const c = svg.appendChild(el("circle", {cx: pos[n.id].x, cy: pos[n.id].y, r: 8}));
+    const t = svg.appendChild(el("text", {x: pos[n.id].x + 12, y: pos[n.id].y + 4}));
+    t.textContent = n.groupName ? n.groupName + "/" + n.name : n.name;
+    c.addEventListener("click", () => select(n.id));
+    return c;
+  });
+
+  function reach(start, next) {
+    const seen = new Set(), stack = [start];
+    while (stack.length) {
+      for (const m of next[stack.pop()]) {
+        if (!seen.has(m)) { seen.add(m); stack.push(m); }
+      }
+    }
+    return seen;
+  }
+
+  function select(id) {
+    const below = reach(id, down), above = reach(id, up);
+    circles.forEach((c, i) => c.setAttribute("class",
+      i === id ? "selected" : below.has(i) ? "down" : above.has(i) ? "up" : ""));
+    lines.forEach((line, i) => {
+      const l = g.links[i];
+      line.setAttribute("class",
+        (l.source === id || below.has(l.source)) ? "down" :
+        (l.target === id || above.has(l.target)) ? "up" : "");
+    });
+  }
+});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,63 @@
+package dagviz
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sgoings/dag"
+)
+
+func TestHandler(t *testing.T) {
+	var g dag.Graph
+	g.Add("a")
+	g.Add("b")
+	g.Connect(dag.BasicEdge("a", "b"))
+
+	server := httptest.NewServer(NewHandler(func() *dag.Graph { return &g }))
+	defer server.Close()
+
+	cases := map[string]struct {
+		ContentType string
+		Contains    string
+	}{
+		"/":              {"text/html", "graph.d3.json"},
+		"/graph.json":    {"application/json", `"Name":"a|b"`},
+		"/graph.d3.json": {"application/json", `"links":[{"source":0,"target":1}]`},
+		"/graph.dot":     {"text/vnd.graphviz", `"[root] a" -> "[root] b"`},
+	}
+	for path, tc := range cases {
+		t.Run(path, func(t *testing.T) {
+			resp, err := http.Get(server.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d: %s", resp.StatusCode, body)
+			}
+			if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, tc.ContentType) {
+				t.Fatalf("bad content type %q", ct)
+			}
+			if !strings.Contains(string(body), tc.Contains) {
+				t.Fatalf("body should contain %q:\n%s", tc.Contains, body)
+			}
+		})
+	}
+
+	resp, err := http.Get(server.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected not found, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build dagdebug
+
+package dag
+
+import (
+	"testing"
+)
+
+func TestGraph_debugCheck(t *testing.T) {
+	var g Graph
+	g.Add("a")
+	g.Add("b")
+	g.Connect(BasicEdge("a", "b"))
+	delete(g.upEdges, "b")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for the inconsistent graph")
+		}
+	}()
+	g.Connect(BasicEdge("a", "c"))
+}
@@ -18,3 +18,51 @@ type Description struct {
 	Summary string
 	Detail  string
 }
+
+// Sourceless returns a Diagnostic with the given severity and description,
+// for reporting warnings or errors that don't come from a Go error.
+func Sourceless(severity Severity, summary, detail string) Diagnostic {
+	return simpleDiagnostic{
+		severity: severity,
+		desc:     Description{Summary: summary, Detail: detail},
+	}
+}
+
+type simpleDiagnostic struct {
+	severity Severity
+	desc     Description
+}
+
+func (d simpleDiagnostic) Severity() Severity       { return d.severity }
+func (d simpleDiagnostic) Description() Description { return d.desc }
+
+// VertexDiagnostic is a Diagnostic attributed to the vertex that caused it.
+// The diagnostics returned from walking each vertex are attributed to it.
+type VertexDiagnostic interface {
+	Diagnostic
+	Vertex() Vertex
+}
+
+type vertexDiagnostic struct {
+	Diagnostic
+	v Vertex
+}
+
+func (d vertexDiagnostic) Vertex() Vertex { return d.v }
+
+// Unwrap returns the error underlying the diagnostic, if any.
+func (d vertexDiagnostic) Unwrap() error {
+	return diagnosticError(d.Diagnostic)
+}
+
+// diagnosticError returns the Go error a diagnostic was created from, or nil
+// if there is none.
+func diagnosticError(diag Diagnostic) error {
+	switch d := diag.(type) {
+	case nativeError:
+		return d.err
+	case vertexDiagnostic:
+		return diagnosticError(d.Diagnostic)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package dag
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"sort"
 )
@@ -132,6 +133,65 @@ func (diags Diagnostics) NonFatalErr() error {
 	return NonFatalError{diags}
 }
 
+// HasWarnings returns true if any of the diagnostics in the list have
+// a severity of Warning.
+func (diags Diagnostics) HasWarnings() bool {
+	for _, diag := range diags {
+		if diag.Severity() == Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns only the diagnostics with a severity of Error.
+func (diags Diagnostics) Errors() Diagnostics {
+	return diags.withSeverity(Error)
+}
+
+// Warnings returns only the diagnostics with a severity of Warning.
+func (diags Diagnostics) Warnings() Diagnostics {
+	return diags.withSeverity(Warning)
+}
+
+func (diags Diagnostics) withSeverity(severity Severity) Diagnostics {
+	var ret Diagnostics
+	for _, diag := range diags {
+		if diag.Severity() == severity {
+			ret = append(ret, diag)
+		}
+	}
+	return ret
+}
+
+// InVertex returns the diagnostics attributed to the vertex v, as
+// VertexDiagnostic. Diagnostics already attributed to a vertex are left as
+// they are.
+func (diags Diagnostics) InVertex(v Vertex) Diagnostics {
+	var ret Diagnostics
+	for _, diag := range diags {
+		if _, ok := diag.(VertexDiagnostic); !ok {
+			diag = vertexDiagnostic{Diagnostic: diag, v: v}
+		}
+		ret = append(ret, diag)
+	}
+	return ret
+}
+
+// ByVertex groups the diagnostics by the name of the vertex they are
+// attributed to. Diagnostics without a vertex are grouped under "".
+func (diags Diagnostics) ByVertex() map[string]Diagnostics {
+	ret := make(map[string]Diagnostics)
+	for _, diag := range diags {
+		var name string
+		if vd, ok := diag.(VertexDiagnostic); ok {
+			name = VertexName(vd.Vertex())
+		}
+		ret[name] = append(ret[name], diag)
+	}
+	return ret
+}
+
 // Sort applies an ordering to the diagnostics in the receiver in-place.
 //
 // The ordering is: warnings before errors, sourceless before sourced,
@@ -181,13 +241,35 @@ func (dae diagnosticsAsError) Error() string {
 func (dae diagnosticsAsError) WrappedErrors() []error {
 	var errs []error
 	for _, diag := range dae.Diagnostics {
-		if wrapper, isErr := diag.(nativeError); isErr {
-			errs = append(errs, wrapper.err)
+		if err := diagnosticError(diag); err != nil {
+			errs = append(errs, err)
 		}
 	}
 	return errs
 }
 
+// Is reports whether any of the Go errors underlying the diagnostics matches
+// target, so that they can be found with errors.Is.
+func (dae diagnosticsAsError) Is(target error) bool {
+	for _, err := range dae.WrappedErrors() {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the Go errors underlying the diagnostics that
+// matches target, so that they can be found with errors.As.
+func (dae diagnosticsAsError) As(target interface{}) bool {
+	for _, err := range dae.WrappedErrors() {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // NonFatalError is a special error type, returned by
 // Diagnostics.ErrWithWarnings and Diagnostics.NonFatalErr,
 // that indicates that the wrapped diagnostics should be treated as non-fatal.
@@ -0,0 +1,75 @@
+package dag
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiagnostics_severities(t *testing.T) {
+	var diags Diagnostics
+	diags = diags.Append(Sourceless(Warning, "careful", "something looks off"))
+	if diags.HasErrors() || !diags.HasWarnings() {
+		t.Fatalf("bad: %#v", diags)
+	}
+	if diags.Err() != nil {
+		t.Fatal("warnings alone should not be an error")
+	}
+
+	diags = diags.Append(errors.New("broken"))
+	if len(diags.Errors()) != 1 || len(diags.Warnings()) != 1 {
+		t.Fatalf("bad: %#v", diags)
+	}
+	if diags.Warnings()[0].Description().Detail != "something looks off" {
+		t.Fatalf("bad: %#v", diags.Warnings())
+	}
+}
+
+func TestDiagnostics_walkAttribution(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	var g AcyclicGraph
+	g.Add("a")
+	g.Add("b")
+	g.Add("c")
+
+	diags := g.Walk(func(v Vertex) Diagnostics {
+		var diags Diagnostics
+		switch v {
+		case "a":
+			diags = diags.Append(errBroken)
+		case "b":
+			diags = diags.Append(Sourceless(Warning, "deprecated", ""))
+		}
+		return diags
+	})
+
+	byVertex := diags.ByVertex()
+	if len(byVertex) != 2 || len(byVertex["a"]) != 1 || len(byVertex["b"]) != 1 {
+		t.Fatalf("bad: %#v", byVertex)
+	}
+
+	vd, ok := byVertex["a"][0].(VertexDiagnostic)
+	if !ok || vd.Vertex() != "a" {
+		t.Fatalf("not attributed to a: %#v", byVertex["a"][0])
+	}
+
+	// the original error can still be found
+	if !errors.Is(diags.Err(), errBroken) {
+		t.Fatalf("expected the error to wrap %v", errBroken)
+	}
+	var target *testDiagError
+	if errors.As(diags.Err(), &target) {
+		t.Fatalf("no error should be a *testDiagError, got %#v", target)
+	}
+
+	diags = diags.Append(&testDiagError{"typed"})
+	if !errors.As(diags.Err(), &target) || target.msg != "typed" {
+		t.Fatalf("expected to find the typed error, got %#v", target)
+	}
+}
+
+type testDiagError struct {
+	msg string
+}
+
+func (e *testDiagError) Error() string { return e.msg }
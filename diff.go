@@ -0,0 +1,152 @@
+package dag
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GraphDiff describes the changes required to turn one graph into another.
+// Vertices are matched by their hashcode, and edges by the hashcodes of their
+// source and target, along with their label and weight with
+// DiffOpts.EdgeData.
+type GraphDiff struct {
+	AddedVertices   Set
+	RemovedVertices Set
+	AddedEdges      Set
+	RemovedEdges    Set
+
+	// edgeData is set by DiffOpts.EdgeData, to show the label and weight of
+	// each edge in String.
+	edgeData bool
+}
+
+// DiffOpts are the options for DiffWithOpts.
+type DiffOpts struct {
+	// EdgeData also compares the label and weight of edges, so that an edge
+	// whose label or weight has changed is reported as removed and added
+	// again.
+	EdgeData bool
+}
+
+// Diff compares g to other, returning the vertices and edges that are only
+// present in other as added, and those only present in g as removed.
+func (g *Graph) Diff(other *Graph) *GraphDiff {
+	return g.DiffWithOpts(other, nil)
+}
+
+// DiffWithOpts is like Diff, with options for how edges are compared. If opts
+// is nil, this is the same as Diff.
+func (g *Graph) DiffWithOpts(other *Graph, opts *DiffOpts) *GraphDiff {
+	if opts == nil {
+		opts = &DiffOpts{}
+	}
+
+	oldVerts, newVerts := g.vertexSet(), other.vertexSet()
+	oldEdges, newEdges := g.endpointSet(opts.EdgeData), other.endpointSet(opts.EdgeData)
+
+	return &GraphDiff{
+		AddedVertices:   newVerts.Difference(oldVerts),
+		RemovedVertices: oldVerts.Difference(newVerts),
+		AddedEdges:      newEdges.Difference(oldEdges),
+		RemovedEdges:    oldEdges.Difference(newEdges),
+		edgeData:        opts.EdgeData,
+	}
+}
+
+// Equal returns true if a and b have the same vertices, and the same edges
+// between them with the same labels and weights. A nil graph is equal to an
+// empty one.
+func Equal(a, b *Graph) bool {
+	return a.DiffWithOpts(b, &DiffOpts{EdgeData: true}).Empty()
+}
+
+// Empty returns true if there are no differences.
+func (d *GraphDiff) Empty() bool {
+	return d.AddedVertices.Len() == 0 && d.RemovedVertices.Len() == 0 &&
+		d.AddedEdges.Len() == 0 && d.RemovedEdges.Len() == 0
+}
+
+// String outputs the differences in a plan-like format, with each added
+// vertex or edge prefixed with "+" and each removed one with "-".
+func (d *GraphDiff) String() string {
+	var lines []string
+	for _, v := range d.AddedVertices {
+		lines = append(lines, fmt.Sprintf("+ %s", VertexName(v)))
+	}
+	for _, v := range d.RemovedVertices {
+		lines = append(lines, fmt.Sprintf("- %s", VertexName(v)))
+	}
+	for _, e := range d.AddedEdges {
+		lines = append(lines, "+ "+d.edgeString(e.(Edge)))
+	}
+	for _, e := range d.RemovedEdges {
+		lines = append(lines, "- "+d.edgeString(e.(Edge)))
+	}
+
+	// Order by name, keeping additions and removals of the same thing
+	// together.
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i][2:] == lines[j][2:] {
+			return lines[i] < lines[j]
+		}
+		return lines[i][2:] < lines[j][2:]
+	})
+
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.WriteString(l)
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// edgeString returns the line describing e in String, along with its label
+// and weight where they are set and compared.
+func (d *GraphDiff) edgeString(e Edge) string {
+	s := fmt.Sprintf("%s -> %s", VertexName(e.Source()), VertexName(e.Target()))
+	if !d.edgeData {
+		return s
+	}
+
+	var extra []string
+	if label := edgeLabel(e); label != "" {
+		extra = append(extra, fmt.Sprintf("label = %q", label))
+	}
+	if w := edgeWeight(e); w != 1 {
+		extra = append(extra, fmt.Sprintf("weight = %g", w))
+	}
+	if len(extra) > 0 {
+		s += " [" + strings.Join(extra, ", ") + "]"
+	}
+	return s
+}
+
+// vertexSet returns the vertices of the graph as a Set, or an empty Set for
+// a nil graph.
+func (g *Graph) vertexSet() Set {
+	if g == nil || g.vertices == nil {
+		return make(Set)
+	}
+	return g.vertices
+}
+
+// endpointSet returns the edges of the graph as a Set keyed by the hashcodes
+// of their source and target, so that edges compare equal regardless of the
+// Edge implementation used to connect them. With edgeData, the label and
+// weight of each edge are part of its key too.
+func (g *Graph) endpointSet(edgeData bool) Set {
+	s := make(Set)
+	if g == nil {
+		return s
+	}
+	for _, e := range g.Edges() {
+		if edgeData {
+			s[[4]interface{}{hashcode(e.Source()), hashcode(e.Target()), edgeLabel(e), edgeWeight(e)}] = e
+		} else {
+			s[[2]interface{}{hashcode(e.Source()), hashcode(e.Target())}] = e
+		}
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphDiff(t *testing.T) {
+	var before Graph
+	before.Add("a")
+	before.Add("b")
+	before.Add("c")
+	before.Connect(BasicEdge("a", "b"))
+	before.Connect(BasicEdge("b", "c"))
+
+	var after Graph
+	after.Add("a")
+	after.Add("b")
+	after.Add("d")
+	after.Connect(BasicEdge("a", "b"))
+	after.Connect(BasicWeightedEdge("b", "d", 2))
+
+	diff := before.Diff(&after)
+	if diff.Empty() {
+		t.Fatal("diff should not be empty")
+	}
+
+	actual := strings.TrimSpace(diff.String())
+	expected := strings.TrimSpace(testGraphDiffStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+func TestGraphDiff_equal(t *testing.T) {
+	var a Graph
+	a.Add(1)
+	a.Add(2)
+	a.Connect(BasicEdge(1, 2))
+
+	var b Graph
+	b.Add(1)
+	b.Add(2)
+	b.Connect(BasicWeightedEdge(1, 2, 5))
+
+	if diff := a.Diff(&b); !diff.Empty() {
+		t.Fatalf("expected no differences, got:\n%s", diff)
+	}
+}
+
+func TestGraphDiff_nil(t *testing.T) {
+	var a Graph
+	a.Add(1)
+
+	diff := a.Diff(nil)
+	if diff.RemovedVertices.Len() != 1 || diff.AddedVertices.Len() != 0 {
+		t.Fatalf("bad:\n%s", diff)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	var a, b Graph
+	if !Equal(&a, &b) || !Equal(nil, &b) {
+		t.Fatal("empty graphs should be equal")
+	}
+
+	a.Add(1)
+	a.Add(2)
+	a.Connect(BasicEdge(1, 2))
+	b.Add(2)
+	b.Add(1)
+	if Equal(&a, &b) {
+		t.Fatal("graphs with different edges should not be equal")
+	}
+
+	b.Connect(BasicEdge(1, 2))
+	if !Equal(&a, &b) {
+		t.Fatal("graphs should be equal")
+	}
+	if Equal(&a, nil) {
+		t.Fatal("a graph with vertices should not equal nil")
+	}
+
+	// edges differing only in their label or weight are different
+	b.RemoveEdge(BasicEdge(1, 2))
+	b.Connect(BasicWeightedEdge(1, 2, 2))
+	if Equal(&a, &b) {
+		t.Fatal("graphs with different edge weights should not be equal")
+	}
+	b.RemoveEdge(BasicEdge(1, 2))
+	b.Connect(BasicLabeledEdge(1, 2, "build"))
+	if Equal(&a, &b) {
+		t.Fatal("graphs with different edge labels should not be equal")
+	}
+	if got, want := a.DiffWithOpts(&b, &DiffOpts{EdgeData: true}).String(), "- 1 -> 2\n+ 1 -> 2 [label = \"build\"]\n"; got != want {
+		t.Fatalf("bad diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+const testGraphDiffStr = `
+- b -> c
++ b -> d
+- c
++ d
+`
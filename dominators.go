@@ -0,0 +1,86 @@
+package dag
+
+import "fmt"
+
+// Dominators returns the immediate dominator of every vertex reachable from
+// root by following edges from their source to their target. A vertex d
+// dominates v if every path from root to v passes through d, and the
+// immediate dominator is the dominator closest to v. This answers which
+// single vertex gates all paths to another. The root itself has no
+// immediate dominator, so isn't included in the result.
+//
+// This uses the iterative algorithm of Cooper, Harvey and Kennedy.
+//
+// Complexity: O(V*E) in the worst case, and close to O(V+E) in practice
+func (g *AcyclicGraph) Dominators(root Vertex) (map[Vertex]Vertex, error) {
+	if !g.HasVertex(root) {
+		return nil, fmt.Errorf("vertex %s is not in the graph", VertexName(root))
+	}
+
+	// Number the reachable vertices in postorder.
+	var order []Vertex
+	index := make(map[interface{}]int)
+	visited := make(Set)
+	var visit func(v Vertex)
+	visit = func(v Vertex) {
+		visited.Add(v)
+		for _, t := range g.downEdgesNoCopy(v) {
+			if !visited.Include(t) {
+				visit(t)
+			}
+		}
+		index[hashcode(v)] = len(order)
+		order = append(order, v)
+	}
+	visit(root)
+
+	// idom holds the postorder index of the immediate dominator of each
+	// vertex, or -1 if it isn't yet known.
+	idom := make([]int, len(order))
+	for i := range idom {
+		idom[i] = -1
+	}
+	rootIdx := len(order) - 1
+	idom[rootIdx] = rootIdx
+
+	intersect := func(a, b int) int {
+		for a != b {
+			for a < b {
+				a = idom[a]
+			}
+			for b < a {
+				b = idom[b]
+			}
+		}
+		return a
+	}
+
+	for changed := true; changed; {
+		changed = false
+		// visit in reverse postorder, skipping the root
+		for i := rootIdx - 1; i >= 0; i-- {
+			newIdom := -1
+			for _, p := range g.upEdgesNoCopy(order[i]) {
+				pi, ok := index[hashcode(p)]
+				if !ok || idom[pi] == -1 {
+					continue
+				}
+				if newIdom == -1 {
+					newIdom = pi
+				} else {
+					newIdom = intersect(pi, newIdom)
+				}
+			}
+			if idom[i] != newIdom {
+				idom[i] = newIdom
+				changed = true
+			}
+		}
+	}
+
+	result := make(map[Vertex]Vertex, len(order)-1)
+	for i, v := range order[:rootIdx] {
+		result[v] = order[idom[i]]
+	}
+	return result, nil
+}
@@ -0,0 +1,42 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAcyclicGraphDominators(t *testing.T) {
+	var g AcyclicGraph
+	for _, v := range []string{"root", "a", "b", "gate", "x", "y", "other"} {
+		g.Add(v)
+	}
+	// every path from root to x and y passes through gate
+	g.Connect(BasicEdge("root", "a"))
+	g.Connect(BasicEdge("root", "b"))
+	g.Connect(BasicEdge("a", "gate"))
+	g.Connect(BasicEdge("b", "gate"))
+	g.Connect(BasicEdge("gate", "x"))
+	g.Connect(BasicEdge("gate", "y"))
+	g.Connect(BasicEdge("x", "y"))
+	g.Connect(BasicEdge("other", "x"))
+
+	actual, err := g.Dominators("root")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[Vertex]Vertex{
+		"a":    "root",
+		"b":    "root",
+		"gate": "root",
+		"x":    "gate",
+		"y":    "gate",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	if _, err := g.Dominators("missing"); err == nil {
+		t.Fatal("should error for a missing root")
+	}
+}
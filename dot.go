@@ -1,9 +1,12 @@
 package dag
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -16,13 +19,94 @@ type DotOpts struct {
 	// Highlight Cycles
 	DrawCycles bool
 
+	// CycleAttrs are the attributes of the extra edges drawn to highlight
+	// cycles with DrawCycles. The default draws thick red edges.
+	CycleAttrs map[string]string
+
 	// How many levels to expand modules as we draw
 	MaxDepth int
 
+	// VertexDepth, when positive, draws only the vertices at most this many
+	// levels below the roots of each graph, the roots themselves being level
+	// 0. The deeper vertices are collapsed into a single summary node.
+	VertexDepth int
+
+	// Clusters draws subgraphs as clusters, named with a "cluster_" prefix
+	// and labelled with the subgraph name, so that Graphviz draws a box
+	// around each one. Subgraphs are always drawn as clusters when MaxDepth
+	// is not 0.
+	Clusters bool
+
+	// Rankdir sets the direction of the layout, such as "LR" for left to
+	// right. The Graphviz default is "TB", top to bottom.
+	Rankdir string
+
+	// GraphAttrs are attributes set on the whole graph, such as "fontname"
+	// or "splines".
+	GraphAttrs map[string]string
+
+	// NodeDefaults and EdgeDefaults are attributes applied to every node and
+	// edge, unless overridden by the node or edge itself.
+	NodeDefaults map[string]string
+	EdgeDefaults map[string]string
+
+	// RankGroups draws the vertices in each group set by SetVertexGroup at
+	// the same rank, so that related vertices line up.
+	RankGroups bool
+
+	// ColorBy fills each vertex with a color from Palette, chosen by its
+	// depth, its group, or the value of one of its attributes. The default
+	// draws every vertex unfilled.
+	ColorBy DotColoring
+
+	// Palette is the list of colors used by ColorBy, which are reused in
+	// order when there are more depths or values than colors. The default is
+	// a palette of twelve light colors, which black text is readable on.
+	Palette []string
+
 	// use this to keep the cluster_ naming convention from the previous dot writer
 	cluster bool
 }
 
+// DotColoring chooses how DotOpts.ColorBy colors the vertices of a graph.
+// The zero value leaves them uncolored.
+type DotColoring struct {
+	mode dotColorMode
+	attr string
+}
+
+type dotColorMode int
+
+const (
+	dotColorNone dotColorMode = iota
+	dotColorDepth
+	dotColorAttr
+)
+
+var (
+	// ColorByDepth colors vertices by the length of the shortest path to
+	// them from a root of their graph, so each level of dependencies has its
+	// own color.
+	ColorByDepth = DotColoring{mode: dotColorDepth}
+
+	// ColorByGroup colors vertices by the group set with SetVertexGroup,
+	// leaving vertices without a group uncolored.
+	ColorByGroup = ColorByAttr(GroupAttr)
+)
+
+// ColorByAttr colors vertices by the value of the attribute key, as set with
+// SetVertexAttr, leaving vertices without it uncolored. Values are given
+// colors in sorted order.
+func ColorByAttr(key string) DotColoring {
+	return DotColoring{mode: dotColorAttr, attr: key}
+}
+
+// defaultDotPalette is the default DotOpts.Palette, ColorBrewer's Set3.
+var defaultDotPalette = []string{
+	"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3", "#fdb462",
+	"#b3de69", "#fccde5", "#d9d9d9", "#bc80bd", "#ccebc5", "#ffed6f",
+}
+
 // GraphNodeDotter can be implemented by a node to cause it to be included
 // in the dot graph. The Dot method will be called which is expected to
 // return a representation of this node.
@@ -41,8 +125,35 @@ type DotNode struct {
 	Attrs map[string]string
 }
 
+// GraphEdgeDotter can be implemented by an edge to control how it is drawn
+// in the dot graph. The DotEdge method will be called which is expected to
+// return a representation of this edge, or nil to leave the edge out.
+type GraphEdgeDotter interface {
+	// DotEdge is called to return the dot formatting for the edge.
+	// The first parameter is the title of the edge.
+	// The second parameter includes user-specified options that affect the dot
+	// graph. See DotOpts above for details.
+	DotEdge(string, *DotOpts) *DotEdge
+}
+
+// DotEdge provides a structure for Edges to return in order to specify their
+// dot format, such as a label, color or style.
+type DotEdge struct {
+	Attrs map[string]string
+}
+
 // Returns the DOT representation of this Graph.
-func (g *marshalGraph) Dot(opts *DotOpts) []byte {
+func (g *MarshalGraph) Dot(opts *DotOpts) []byte {
+	var buf bytes.Buffer
+	g.WriteDot(&buf, opts)
+	return buf.Bytes()
+}
+
+// WriteDot writes the DOT representation of this Graph to out as it is
+// generated, rather than building it in memory like Dot, returning the first
+// error from out. Only the edges of one graph or subgraph at a time are held
+// in memory, to sort them.
+func (g *MarshalGraph) WriteDot(out io.Writer, opts *DotOpts) error {
 	if opts == nil {
 		opts = &DotOpts{
 			DrawCycles: true,
@@ -51,43 +162,79 @@ func (g *marshalGraph) Dot(opts *DotOpts) []byte {
 		}
 	}
 
-	var w indentWriter
+	w := newIndentWriter(out)
 	w.WriteString("digraph {\n")
 	w.Indent()
 
 	// some dot defaults
 	w.WriteString(`compound = "true"` + "\n")
 	w.WriteString(`newrank = "true"` + "\n")
+	if opts.Rankdir != "" {
+		w.WriteString(fmt.Sprintf("rankdir = %q\n", opts.Rankdir))
+	}
+	for _, as := range attrStrings(opts.GraphAttrs) {
+		w.WriteString(as + "\n")
+	}
+	if len(opts.NodeDefaults) > 0 {
+		w.WriteString("node [" + strings.Join(attrStrings(opts.NodeDefaults), ", ") + "]\n")
+	}
+	if len(opts.EdgeDefaults) > 0 {
+		w.WriteString("edge [" + strings.Join(attrStrings(opts.EdgeDefaults), ", ") + "]\n")
+	}
 
 	// the top level graph is written as the first subgraph
 	w.WriteString(`subgraph "root" {` + "\n")
-	g.writeBody(opts, &w)
+	w.Indent()
+	g.writeBody(opts, w)
+	w.Unindent()
+	w.WriteString("}\n")
 
 	// cluster isn't really used other than for naming purposes in some graphs
-	opts.cluster = opts.MaxDepth != 0
+	opts.cluster = opts.Clusters || opts.MaxDepth != 0
 	maxDepth := opts.MaxDepth
 	if maxDepth == 0 {
 		maxDepth = -1
 	}
 
 	for _, s := range g.Subgraphs {
-		g.writeSubgraph(s, opts, maxDepth, &w)
+		g.writeSubgraph(s, opts, maxDepth, w)
 	}
 
 	w.Unindent()
 	w.WriteString("}\n")
-	return w.Bytes()
+	return w.Flush()
 }
 
-func (v *marshalVertex) dot(g *marshalGraph, opts *DotOpts) []byte {
-	var buf bytes.Buffer
-	graphName := g.Name
-	if graphName == "" {
-		graphName = "root"
+// dotAttrs returns the attributes of the vertex to draw, which leaves out
+// the group set by SetVertexGroup.
+func (v *MarshalVertex) dotAttrs() map[string]string {
+	if _, ok := v.Attrs[GroupAttr]; !ok {
+		return v.Attrs
+	}
+	attrs := make(map[string]string, len(v.Attrs)-1)
+	for k, val := range v.Attrs {
+		if k != GroupAttr {
+			attrs[k] = val
+		}
 	}
+	return attrs
+}
+
+// dot returns the node statement for the vertex, filled with fill if it is
+// not empty and the vertex has no fill of its own.
+func (v *MarshalVertex) dot(g *MarshalGraph, opts *DotOpts, fill string) []byte {
+	var buf bytes.Buffer
+	graphName := g.dotName()
 
 	name := v.Name
-	attrs := v.Attrs
+	attrs := v.dotAttrs()
+	if _, ok := attrs["fillcolor"]; !ok && fill != "" {
+		filled := map[string]string{"style": "filled", "fillcolor": fill}
+		for k, val := range attrs {
+			filled[k] = val
+		}
+		attrs = filled
+	}
 	if v.graphNodeDotter != nil {
 		node := v.graphNodeDotter.DotNode(name, opts)
 		if node == nil {
@@ -113,50 +260,139 @@ func (v *marshalVertex) dot(g *marshalGraph, opts *DotOpts) []byte {
 	return buf.Bytes()
 }
 
-func (e *marshalEdge) dot(g *marshalGraph) string {
+func (e *MarshalEdge) dot(g *MarshalGraph, opts *DotOpts) string {
 	var buf bytes.Buffer
-	graphName := g.Name
-	if graphName == "" {
-		graphName = "root"
+	graphName := g.dotName()
+
+	// The label and weight are drawn unless the attributes override them.
+	attrs := e.Attrs
+	_, hasLabel := attrs["label"]
+	_, hasWeight := attrs["weight"]
+	if (!hasLabel && e.Label != "") || (!hasWeight && e.Weight != nil) {
+		attrs = make(map[string]string, len(e.Attrs)+2)
+		if e.Label != "" {
+			attrs["label"] = e.Label
+		}
+		if e.Weight != nil {
+			attrs["weight"] = strconv.FormatFloat(*e.Weight, 'g', -1, 64)
+		}
+		for k, v := range e.Attrs {
+			attrs[k] = v
+		}
 	}
-	sourceName := g.vertexByID(e.Source).Name
-	targetName := g.vertexByID(e.Target).Name
-	s := fmt.Sprintf(`"[%s] %s" -> "[%s] %s"`, graphName, sourceName, graphName, targetName)
+	if e.graphEdgeDotter != nil {
+		edge := e.graphEdgeDotter.DotEdge(e.Name, opts)
+		if edge == nil {
+			return ""
+		}
+
+		newAttrs := make(map[string]string)
+		for k, v := range attrs {
+			newAttrs[k] = v
+		}
+		for k, v := range edge.Attrs {
+			newAttrs[k] = v
+		}
+
+		attrs = newAttrs
+	}
+
+	// edges between unknown vertices are left out, as by the other writers
+	source, target := g.VertexByID(e.Source), g.VertexByID(e.Target)
+	if source == nil || target == nil {
+		return ""
+	}
+	s := fmt.Sprintf(`"[%s] %s" -> "[%s] %s"`, graphName, source.Name, graphName, target.Name)
 	buf.WriteString(s)
-	writeAttrs(&buf, e.Attrs)
+	writeAttrs(&buf, attrs)
 
 	return buf.String()
 }
 
-func cycleDot(e *marshalEdge, g *marshalGraph) string {
-	return e.dot(g) + ` [color = "red", penwidth = "2.0"]`
+func cycleDot(e *MarshalEdge, g *MarshalGraph, opts *DotOpts) string {
+	attrs := opts.CycleAttrs
+	if attrs == nil {
+		attrs = map[string]string{"color": "red", "penwidth": "2.0"}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(e.dot(g, opts))
+	writeAttrs(&buf, attrs)
+	return buf.String()
+}
+
+// writeRankGroups writes a rank=same block for each vertex group set by
+// SetVertexGroup, in order of the group names.
+func (g *MarshalGraph) writeRankGroups(opts *DotOpts, hidden map[string]bool, w *indentWriter) {
+	graphName := g.dotName()
+	groups := make(map[string][]string)
+	for _, v := range g.Vertices {
+		group := v.Attrs[GroupAttr]
+		if group == "" || hidden[v.ID] {
+			continue
+		}
+
+		name := v.Name
+		if v.graphNodeDotter != nil {
+			node := v.graphNodeDotter.DotNode(name, opts)
+			if node == nil {
+				continue
+			}
+			name = node.Name
+		}
+		groups[group] = append(groups[group], fmt.Sprintf(`"[%s] %s"`, graphName, name))
+	}
+
+	names := make([]string, 0, len(groups))
+	for group := range groups {
+		names = append(names, group)
+	}
+	sort.Strings(names)
+
+	for _, group := range names {
+		w.WriteString(fmt.Sprintf("{rank = same; %s}\n", strings.Join(groups[group], "; ")))
+	}
 }
 
 // Write the subgraph body. The is recursive, and the depth argument is used to
-// record the current depth of iteration.
-func (g *marshalGraph) writeSubgraph(sg *marshalGraph, opts *DotOpts, depth int, w *indentWriter) {
+// record the current depth of iteration. Nested subgraphs are written inside
+// the block of the subgraph containing them, so their clusters are drawn
+// inside one another.
+func (g *MarshalGraph) writeSubgraph(sg *MarshalGraph, opts *DotOpts, depth int, w *indentWriter) {
 	if depth == 0 {
 		return
 	}
 	depth--
 
 	name := sg.Name
+	body := sg
 	if opts.cluster {
 		// we prefix with cluster_ to match the old dot output
 		name = "cluster_" + name
-		sg.Attrs["label"] = sg.Name
+
+		// label the cluster through a copy, leaving the caller's graph alone
+		attrs := make(map[string]string, len(sg.Attrs)+1)
+		for k, v := range sg.Attrs {
+			attrs[k] = v
+		}
+		attrs["label"] = sg.Name
+		labeled := *sg
+		labeled.Attrs = attrs
+		body = &labeled
 	}
 	w.WriteString(fmt.Sprintf("subgraph %q {\n", name))
-	sg.writeBody(opts, w)
+	w.Indent()
+	body.writeBody(opts, w)
 
 	for _, sg := range sg.Subgraphs {
 		g.writeSubgraph(sg, opts, depth, w)
 	}
-}
 
-func (g *marshalGraph) writeBody(opts *DotOpts, w *indentWriter) {
-	w.Indent()
+	w.Unindent()
+	w.WriteString("}\n")
+}
 
+func (g *MarshalGraph) writeBody(opts *DotOpts, w *indentWriter) {
 	for _, as := range attrStrings(g.Attrs) {
 		w.WriteString(as + "\n")
 	}
@@ -164,13 +400,28 @@ func (g *marshalGraph) writeBody(opts *DotOpts, w *indentWriter) {
 	// list of Vertices that aren't to be included in the dot output
 	skip := map[string]bool{}
 
+	// Vertices too deep to draw, which are collapsed into a summary node
+	hidden := g.hiddenVertices(opts.VertexDepth)
+	for id := range hidden {
+		skip[id] = true
+	}
+
+	colors := g.vertexColors(opts)
 	for _, v := range g.Vertices {
-		if v.graphNodeDotter == nil {
+		if hidden[v.ID] {
+			continue
+		}
+		// vertices without attributes only appear in their edges
+		if v.graphNodeDotter == nil && len(v.dotAttrs()) == 0 && colors[v.ID] == "" {
 			skip[v.ID] = true
 			continue
 		}
 
-		w.Write(v.dot(g, opts))
+		w.Write(v.dot(g, opts, colors[v.ID]))
+	}
+
+	if opts.RankGroups {
+		g.writeRankGroups(opts, hidden, w)
 	}
 
 	var dotEdges []string
@@ -192,21 +443,41 @@ func (g *marshalGraph) writeBody(opts *DotOpts, w *indentWriter) {
 					continue
 				}
 
-				e := &marshalEdge{
+				e := &MarshalEdge{
 					Name:   fmt.Sprintf("%s|%s", src.Name, tgt.Name),
 					Source: src.ID,
 					Target: tgt.ID,
 					Attrs:  make(map[string]string),
 				}
 
-				dotEdges = append(dotEdges, cycleDot(e, g))
+				dotEdges = append(dotEdges, cycleDot(e, g, opts))
 				src = tgt
 			}
 		}
 	}
 
+	summarized := map[string]bool{}
 	for _, e := range g.Edges {
-		dotEdges = append(dotEdges, e.dot(g))
+		switch {
+		case hidden[e.Source]:
+			continue
+		case hidden[e.Target]:
+			summarized[e.Source] = true
+			continue
+		}
+
+		if s := e.dot(g, opts); s != "" {
+			dotEdges = append(dotEdges, s)
+		}
+	}
+
+	if len(hidden) > 0 {
+		w.Write(g.summaryDot(len(hidden)))
+		for id := range summarized {
+			if v := g.VertexByID(id); v != nil {
+				dotEdges = append(dotEdges, g.summaryEdgeDot(v))
+			}
+		}
 	}
 
 	// srot these again to match the old output
@@ -215,9 +486,131 @@ func (g *marshalGraph) writeBody(opts *DotOpts, w *indentWriter) {
 	for _, e := range dotEdges {
 		w.WriteString(e + "\n")
 	}
+}
 
-	w.Unindent()
-	w.WriteString("}\n")
+// hiddenVertices returns the IDs of the vertices more than maxDepth levels
+// below the roots of the graph, or none if maxDepth isn't positive. Vertices
+// which can't be reached from a root, because they are in a cycle, are never
+// hidden.
+func (g *MarshalGraph) hiddenVertices(maxDepth int) map[string]bool {
+	hidden := map[string]bool{}
+	if maxDepth <= 0 {
+		return hidden
+	}
+
+	for id, d := range g.vertexDepths() {
+		if d > maxDepth {
+			hidden[id] = true
+		}
+	}
+	return hidden
+}
+
+// vertexDepths returns the length of the shortest path from a root of the
+// graph to each vertex, keyed by ID. Vertices only reachable from a cycle
+// have no depth.
+func (g *MarshalGraph) vertexDepths() map[string]int {
+	down := map[string][]string{}
+	isTarget := map[string]bool{}
+	for _, e := range g.Edges {
+		down[e.Source] = append(down[e.Source], e.Target)
+		isTarget[e.Target] = true
+	}
+
+	depth := map[string]int{}
+	var queue []string
+	for _, v := range g.Vertices {
+		if !isTarget[v.ID] {
+			depth[v.ID] = 0
+			queue = append(queue, v.ID)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, t := range down[id] {
+			if _, ok := depth[t]; !ok {
+				depth[t] = depth[id] + 1
+				queue = append(queue, t)
+			}
+		}
+	}
+
+	return depth
+}
+
+// vertexColors returns the fill color for each vertex colored by
+// DotOpts.ColorBy, keyed by ID.
+func (g *MarshalGraph) vertexColors(opts *DotOpts) map[string]string {
+	palette := opts.Palette
+	if len(palette) == 0 {
+		palette = defaultDotPalette
+	}
+
+	colors := map[string]string{}
+	switch opts.ColorBy.mode {
+	case dotColorDepth:
+		for id, d := range g.vertexDepths() {
+			colors[id] = palette[d%len(palette)]
+		}
+
+	case dotColorAttr:
+		index := map[string]int{}
+		for _, v := range g.Vertices {
+			if value, ok := v.Attrs[opts.ColorBy.attr]; ok {
+				index[value] = 0
+			}
+		}
+		values := make([]string, 0, len(index))
+		for value := range index {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		for i, value := range values {
+			index[value] = i
+		}
+
+		for _, v := range g.Vertices {
+			if value, ok := v.Attrs[opts.ColorBy.attr]; ok {
+				colors[v.ID] = palette[index[value]%len(palette)]
+			}
+		}
+	}
+	return colors
+}
+
+// summaryDot returns the node standing in for the vertices hidden by
+// DotOpts.VertexDepth.
+func (g *MarshalGraph) summaryDot(count int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(g.summaryID())
+	writeAttrs(&buf, map[string]string{
+		"label": fmt.Sprintf("%d more", count),
+		"shape": "box",
+		"style": "dashed",
+	})
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// summaryEdgeDot returns an edge from v to the summary node.
+func (g *MarshalGraph) summaryEdgeDot(v *MarshalVertex) string {
+	return fmt.Sprintf(`"[%s] %s" -> %s [style = "dashed"]`, g.dotName(), v.Name, g.summaryID())
+}
+
+// summaryID returns the quoted node ID of the summary node. The ID of every
+// vertex starts with the name of its graph in brackets, so starting with
+// anything else keeps the summary node apart from all of them.
+func (g *MarshalGraph) summaryID() string {
+	return fmt.Sprintf(`"... [%s]"`, g.dotName())
+}
+
+// dotName returns the name used for the graph in the names of its vertices.
+func (g *MarshalGraph) dotName() string {
+	if g.Name == "" {
+		return "root"
+	}
+	return g.Name
 }
 
 func writeAttrs(buf *bytes.Buffer, attrs map[string]string) {
@@ -237,20 +630,28 @@ func attrStrings(attrs map[string]string) []string {
 	return strings
 }
 
-// Provide a bytes.Buffer like structure, which will indent when starting a
-// newline.
+// Provide a bufio.Writer like structure, which will indent when starting a
+// newline. Like a bufio.Writer, the first error writing to the underlying
+// writer is returned by every later write, and by Flush.
 type indentWriter struct {
-	bytes.Buffer
+	*bufio.Writer
 	level int
+
+	// lineStart is set when the last byte written was a newline.
+	lineStart bool
+}
+
+func newIndentWriter(w io.Writer) *indentWriter {
+	return &indentWriter{Writer: bufio.NewWriter(w)}
 }
 
 func (w *indentWriter) indent() {
-	newline := []byte("\n")
-	if !bytes.HasSuffix(w.Bytes(), newline) {
+	if !w.lineStart {
 		return
 	}
+	w.lineStart = false
 	for i := 0; i < w.level; i++ {
-		w.Buffer.WriteString("\t")
+		w.Writer.WriteByte('\t')
 	}
 }
 
@@ -260,22 +661,30 @@ func (w *indentWriter) Indent() { w.level++ }
 // Unindent decreases indentation by 1
 func (w *indentWriter) Unindent() { w.level-- }
 
-// the following methods intercecpt the byte.Buffer writes and insert the
+// the following methods intercecpt the bufio.Writer writes and insert the
 // indentation when starting a new line.
 func (w *indentWriter) Write(b []byte) (int, error) {
 	w.indent()
-	return w.Buffer.Write(b)
+	if len(b) > 0 {
+		w.lineStart = b[len(b)-1] == '\n'
+	}
+	return w.Writer.Write(b)
 }
 
 func (w *indentWriter) WriteString(s string) (int, error) {
 	w.indent()
-	return w.Buffer.WriteString(s)
+	if len(s) > 0 {
+		w.lineStart = s[len(s)-1] == '\n'
+	}
+	return w.Writer.WriteString(s)
 }
 func (w *indentWriter) WriteByte(b byte) error {
 	w.indent()
-	return w.Buffer.WriteByte(b)
+	w.lineStart = b == '\n'
+	return w.Writer.WriteByte(b)
 }
 func (w *indentWriter) WriteRune(r rune) (int, error) {
 	w.indent()
-	return w.Buffer.WriteRune(r)
+	w.lineStart = r == '\n'
+	return w.Writer.WriteRune(r)
 }
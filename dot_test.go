@@ -1,7 +1,9 @@
 package dag
 
 import (
+	"bytes"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -24,6 +26,196 @@ func TestGraphDot_opts(t *testing.T) {
 	}
 }
 
+func TestGraphDot_vertexDepth(t *testing.T) {
+	var g Graph
+	for i := 1; i <= 5; i++ {
+		g.Add(i)
+	}
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 4))
+	g.Connect(BasicEdge(5, 3))
+
+	// 3 is only one level below the root 5, so only 4 is hidden
+	actual := strings.TrimSpace(string(g.Dot(&DotOpts{VertexDepth: 1})))
+	expected := strings.TrimSpace(testGraphDotVertexDepthStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+
+	// MaxDepth only limits the expansion of subgraphs
+	if actual := string(g.Dot(&DotOpts{MaxDepth: 1})); strings.Contains(actual, "more") {
+		t.Fatalf("no vertices should be hidden:\n%s", actual)
+	}
+
+	// the summary node is distinct from a vertex named "..."
+	g.Add("...")
+	g.Connect(BasicEdge(1, "..."))
+	actual = string(g.Dot(&DotOpts{VertexDepth: 1}))
+	if !strings.Contains(actual, `"[root] 1" -> "[root] ..."`) || !strings.Contains(actual, `"[root] 3" -> "... [root]"`) {
+		t.Fatalf("bad summary:\n%s", actual)
+	}
+}
+
+func TestGraphDot_clusters(t *testing.T) {
+	var sub Graph
+	sub.Add("x")
+
+	var g Graph
+	g.Add(&testSubgrapher{name: "sub", graph: &sub})
+
+	actual := string(g.Dot(&DotOpts{}))
+	if strings.Contains(actual, "cluster_sub") || strings.Contains(actual, "label") {
+		t.Fatalf("should not draw clusters:\n%s", actual)
+	}
+
+	actual = string(g.Dot(&DotOpts{Clusters: true}))
+	if !strings.Contains(actual, `subgraph "cluster_sub" {`) || !strings.Contains(actual, `label = "sub"`) {
+		t.Fatalf("should draw clusters:\n%s", actual)
+	}
+}
+
+// A MarshalGraph built by hand may have subgraphs without attributes, which
+// are labeled without being modified.
+func TestMarshalGraphDot_clusters(t *testing.T) {
+	sub := &MarshalGraph{Type: "Graph", Name: "sub", Vertices: []*MarshalVertex{{ID: "x", Name: "x"}}}
+	mg := &MarshalGraph{Type: "Graph", Name: "root", Subgraphs: []*MarshalGraph{sub}}
+
+	actual := string(mg.Dot(&DotOpts{Clusters: true}))
+	if !strings.Contains(actual, `subgraph "cluster_sub" {`) || !strings.Contains(actual, `label = "sub"`) {
+		t.Fatalf("should draw clusters:\n%s", actual)
+	}
+	if sub.Attrs != nil {
+		t.Fatalf("subgraph attributes changed: %#v", sub.Attrs)
+	}
+}
+
+// Edges referring to vertices that aren't in a MarshalGraph are left out.
+func TestMarshalGraphDot_unknownEdge(t *testing.T) {
+	mg := &MarshalGraph{
+		Type:     "Graph",
+		Name:     "root",
+		Vertices: []*MarshalVertex{{ID: "a", Name: "a"}, {ID: "b", Name: "b"}},
+		Edges: []*MarshalEdge{
+			{Name: "a|b", Source: "a", Target: "b"},
+			{Name: "a|missing", Source: "a", Target: "missing"},
+			{Name: "missing|b", Source: "missing", Target: "b"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := mg.WriteDot(&buf, &DotOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	actual := buf.String()
+	if !strings.Contains(actual, `"[root] a" -> "[root] b"`) || strings.Contains(actual, "missing") {
+		t.Fatalf("bad edges:\n%s", actual)
+	}
+}
+
+func TestGraphDot_drawCycles(t *testing.T) {
+	a := &testGraphNodeDotter{Result: &DotNode{Name: "a"}}
+	b := &testGraphNodeDotter{Result: &DotNode{Name: "b"}}
+
+	var g Graph
+	g.Add(a)
+	g.Add(b)
+	g.Connect(BasicEdge(a, b))
+	g.Connect(BasicEdge(b, a))
+
+	actual := string(g.Dot(&DotOpts{DrawCycles: true}))
+	if !strings.Contains(actual, `"[root] a" -> "[root] b" [color = "red", penwidth = "2.0"]`) {
+		t.Fatalf("cycle should be highlighted:\n%s", actual)
+	}
+
+	opts := &DotOpts{
+		DrawCycles: true,
+		CycleAttrs: map[string]string{"color": "orange", "style": "bold"},
+	}
+	actual = string(g.Dot(opts))
+	if !strings.Contains(actual, `"[root] a" -> "[root] b" [color = "orange", style = "bold"]`) {
+		t.Fatalf("cycle should be highlighted with the given attrs:\n%s", actual)
+	}
+
+	actual = string(g.Dot(&DotOpts{}))
+	if strings.Contains(actual, "red") {
+		t.Fatalf("cycle should not be highlighted:\n%s", actual)
+	}
+}
+
+func TestGraphDot_graphAttrs(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Connect(BasicEdge(1, 2))
+
+	opts := &DotOpts{
+		Rankdir:      "LR",
+		GraphAttrs:   map[string]string{"fontname": "Helvetica", "splines": "ortho"},
+		NodeDefaults: map[string]string{"shape": "box"},
+		EdgeDefaults: map[string]string{"arrowsize": "0.5", "color": "gray"},
+	}
+	actual := strings.TrimSpace(string(g.Dot(opts)))
+	expected := strings.TrimSpace(testGraphDotGraphAttrsStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+
+	if _, err := ParseDot(strings.NewReader(actual)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGraphDot_edgeDotter(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+
+	e := &testDotEdge{
+		Edge:          BasicEdge(1, 2),
+		DotEdgeReturn: &DotEdge{Attrs: map[string]string{"label": "depends_on", "color": "blue"}},
+	}
+	g.Connect(e)
+	g.Connect(&testDotEdge{Edge: BasicEdge(1, 3)})
+
+	opts := &DotOpts{Verbose: true}
+	actual := strings.TrimSpace(string(g.Dot(opts)))
+	expected := strings.TrimSpace(testGraphDotEdgeDotterStr)
+	if actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	if e.DotEdgeTitle != "1|2" {
+		t.Fatalf("bad title: %q", e.DotEdgeTitle)
+	}
+	if e.DotEdgeOpts != opts {
+		t.Fatalf("bad opts: %#v", e.DotEdgeOpts)
+	}
+}
+
+type testDotEdge struct {
+	Edge
+
+	DotEdgeTitle  string
+	DotEdgeOpts   *DotOpts
+	DotEdgeReturn *DotEdge
+}
+
+func (e *testDotEdge) DotEdge(title string, opts *DotOpts) *DotEdge {
+	e.DotEdgeTitle = title
+	e.DotEdgeOpts = opts
+	return e.DotEdgeReturn
+}
+
+const testGraphDotEdgeDotterStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] 1" -> "[root] 2" [color = "blue", label = "depends_on"]
+	}
+}`
+
 type testDotVertex struct {
 	DotNodeCalled bool
 	DotNodeTitle  string
@@ -37,3 +229,187 @@ func (v *testDotVertex) DotNode(title string, opts *DotOpts) *DotNode {
 	v.DotNodeOpts = opts
 	return v.DotNodeReturn
 }
+
+const testGraphDotVertexDepthStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"... [root]" [label = "1 more", shape = "box", style = "dashed"]
+		"[root] 1" -> "[root] 2"
+		"[root] 2" -> "[root] 3"
+		"[root] 3" -> "... [root]" [style = "dashed"]
+		"[root] 5" -> "[root] 3"
+	}
+}`
+
+func TestGraphDot_rankGroups(t *testing.T) {
+	var g Graph
+	for _, v := range []string{"api", "api-db", "web", "web-cache", "lb"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("lb", "api"))
+	g.Connect(BasicEdge("lb", "web"))
+	g.Connect(BasicEdge("api", "api-db"))
+	g.Connect(BasicEdge("web", "web-cache"))
+	g.SetVertexGroup("api", "frontends")
+	g.SetVertexGroup("web", "frontends")
+	g.SetVertexGroup("api-db", "stores")
+	g.SetVertexGroup("web-cache", "stores")
+	g.SetVertexAttr("web-cache", "shape", "box")
+
+	if g.VertexGroup("web") != "frontends" || g.VertexGroup("lb") != "" {
+		t.Fatalf("bad groups: %q, %q", g.VertexGroup("web"), g.VertexGroup("lb"))
+	}
+
+	actual := strings.TrimSpace(string(g.Dot(&DotOpts{RankGroups: true})))
+	expected := strings.TrimSpace(testGraphDotRankGroupsStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+	if _, err := ParseDot(strings.NewReader(actual)); err != nil {
+		t.Fatal(err)
+	}
+
+	// without RankGroups, the groups aren't drawn at all
+	if strings.Contains(string(g.Dot(nil)), "rank = same") || strings.Contains(string(g.Dot(nil)), GroupAttr) {
+		t.Fatalf("groups should not be drawn:\n%s", g.Dot(nil))
+	}
+
+	g.SetVertexGroup("web", "")
+	if g.VertexGroup("web") != "" {
+		t.Fatal("web should no longer be in a group")
+	}
+}
+
+func TestGraphWriteDot(t *testing.T) {
+	g := testNestedSubgraphs()
+	g.Add("b")
+	g.Connect(BasicEdge("b", "a"))
+	g.Connect(BasicEdge("a", "b"))
+	g.SetVertexAttr("b", "shape", "box")
+
+	for _, opts := range []*DotOpts{nil, {Clusters: true, ColorBy: ColorByDepth}} {
+		var buf bytes.Buffer
+		if err := g.WriteDot(&buf, opts); err != nil {
+			t.Fatal(err)
+		}
+		if actual, expected := buf.String(), string(g.Dot(opts)); actual != expected {
+			t.Fatalf("bad:\n%s\n\nexpected:\n%s", actual, expected)
+		}
+	}
+
+	if err := g.WriteDot(testFailingWriter{}, nil); err != errTestWrite {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+}
+
+func TestGraphDot_colorBy(t *testing.T) {
+	var g Graph
+	for _, v := range []string{"a", "b", "c", "d"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("a", "b"))
+	g.Connect(BasicEdge("b", "c"))
+	g.Connect(BasicEdge("a", "d"))
+	g.SetVertexGroup("b", "x")
+	g.SetVertexGroup("d", "x")
+	g.SetVertexGroup("c", "w")
+	g.SetVertexAttr("a", "owner", "team-b")
+	g.SetVertexAttr("c", "owner", "team-a")
+	g.SetVertexAttr("c", "fillcolor", "white")
+
+	cases := map[string]struct {
+		Opts     *DotOpts
+		Expected string
+	}{
+		"depth": {
+			&DotOpts{ColorBy: ColorByDepth, Palette: []string{"red", "green"}},
+			testGraphDotColorByDepthStr,
+		},
+		"group": {
+			&DotOpts{ColorBy: ColorByGroup},
+			testGraphDotColorByGroupStr,
+		},
+		"attr": {
+			&DotOpts{ColorBy: ColorByAttr("owner")},
+			testGraphDotColorByAttrStr,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual := strings.TrimSpace(string(g.Dot(tc.Opts)))
+			expected := strings.TrimSpace(tc.Expected)
+			if actual != expected {
+				t.Fatalf("bad:\n%s", actual)
+			}
+		})
+	}
+}
+
+const testGraphDotColorByDepthStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] a" [fillcolor = "red", owner = "team-b", style = "filled"]
+		"[root] b" [fillcolor = "green", style = "filled"]
+		"[root] c" [fillcolor = "white", owner = "team-a"]
+		"[root] d" [fillcolor = "green", style = "filled"]
+		"[root] a" -> "[root] b"
+		"[root] a" -> "[root] d"
+		"[root] b" -> "[root] c"
+	}
+}`
+
+const testGraphDotColorByGroupStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] a" [owner = "team-b"]
+		"[root] b" [fillcolor = "#ffffb3", style = "filled"]
+		"[root] c" [fillcolor = "white", owner = "team-a"]
+		"[root] d" [fillcolor = "#ffffb3", style = "filled"]
+		"[root] a" -> "[root] b"
+		"[root] a" -> "[root] d"
+		"[root] b" -> "[root] c"
+	}
+}`
+
+const testGraphDotColorByAttrStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] a" [fillcolor = "#ffffb3", owner = "team-b", style = "filled"]
+		"[root] c" [fillcolor = "white", owner = "team-a"]
+		"[root] a" -> "[root] b"
+		"[root] a" -> "[root] d"
+		"[root] b" -> "[root] c"
+	}
+}`
+
+const testGraphDotRankGroupsStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] web-cache" [shape = "box"]
+		{rank = same; "[root] api"; "[root] web"}
+		{rank = same; "[root] api-db"; "[root] web-cache"}
+		"[root] api" -> "[root] api-db"
+		"[root] lb" -> "[root] api"
+		"[root] lb" -> "[root] web"
+		"[root] web" -> "[root] web-cache"
+	}
+}`
+
+const testGraphDotGraphAttrsStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	rankdir = "LR"
+	fontname = "Helvetica"
+	splines = "ortho"
+	node [shape = "box"]
+	edge [arrowsize = "0.5", color = "gray"]
+	subgraph "root" {
+		"[root] 1" -> "[root] 2"
+	}
+}`
@@ -0,0 +1,473 @@
+package dag
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseDot reads a graph from the dot format produced by Dot, rebuilding the
+// vertices, edges and subgraphs it describes. Vertices are reconstructed as
+// *ParsedVertex, or as *ParsedSubgraph when they contain a subgraph, with
+// their dot attributes. Edges with attributes are reconstructed as an
+// AttrEdge, and edges with a numeric weight attribute as a WeightedEdge.
+//
+// Vertex names written by Dot are prefixed with the name of the graph they
+// belong to, such as "[root] foo", and this prefix is used to place each
+// vertex in the correct subgraph. Vertices without a prefix belong to the
+// subgraph block they are declared in. A subgraph is attached to the vertex
+// of the same name in another graph, or to a new vertex in the root graph if
+// there is none.
+//
+// Only the subset of the dot language used by this package is supported.
+// Dot output drawn with DotOpts.DrawCycles should not be parsed, since the
+// highlighted cycle edges can't be told apart from real edges.
+func ParseDot(r io.Reader) (*Graph, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	toks, err := lexDot(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &dotParser{
+		toks:   toks,
+		byName: make(map[[2]string]*dotNode),
+	}
+	if err := p.parseGraph(); err != nil {
+		return nil, err
+	}
+
+	return p.build()
+}
+
+const dotRootGraph = "root"
+
+type dotTokenKind int
+
+const (
+	dotID dotTokenKind = iota
+	dotPunct
+	dotEOF
+)
+
+type dotToken struct {
+	kind   dotTokenKind
+	value  string
+	quoted bool
+	line   int
+}
+
+// lexDot splits dot source into identifiers, quoted strings and punctuation,
+// dropping whitespace and comments.
+func lexDot(src string) ([]dotToken, error) {
+	var toks []dotToken
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+
+		case strings.HasPrefix(src[i:], "->"):
+			toks = append(toks, dotToken{kind: dotPunct, value: "->", line: line})
+			i += 2
+
+		case strings.ContainsRune("{}[]=,;:", rune(c)):
+			toks = append(toks, dotToken{kind: dotPunct, value: string(c), line: line})
+			i++
+
+		case c == '"':
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+
+			raw := src[i : j+1]
+			value, err := strconv.Unquote(raw)
+			if err != nil {
+				// Not every dot string is a valid Go string, so fall back
+				// to the only escape dot itself defines.
+				value = strings.Replace(raw[1:len(raw)-1], `\"`, `"`, -1)
+			}
+			toks = append(toks, dotToken{kind: dotID, value: value, quoted: true, line: line})
+			line += strings.Count(raw, "\n")
+			i = j + 1
+
+		case isDotIDChar(rune(c)) || (c == '-' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1]))):
+			j := i + 1
+			for j < len(src) && isDotIDChar(rune(src[j])) {
+				j++
+			}
+			toks = append(toks, dotToken{kind: dotID, value: src[i:j], line: line})
+			i = j
+
+		default:
+			return nil, fmt.Errorf("line %d: unexpected character %q", line, c)
+		}
+	}
+
+	return append(toks, dotToken{kind: dotEOF, line: line}), nil
+}
+
+func isDotIDChar(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// dotParser records the statements of a dot graph, which are turned into a
+// Graph by build once everything has been read.
+type dotParser struct {
+	toks []dotToken
+	pos  int
+
+	// nodes and edges in the order they were declared, with nodes also
+	// indexed by their graph and name.
+	nodes  []*dotNode
+	edges  []*dotEdge
+	byName map[[2]string]*dotNode
+}
+
+type dotNode struct {
+	graph string
+	name  string
+	attrs map[string]string
+}
+
+type dotEdge struct {
+	source, target *dotNode
+	attrs          map[string]string
+}
+
+func (p *dotParser) peek() dotToken {
+	return p.toks[p.pos]
+}
+
+func (p *dotParser) next() dotToken {
+	t := p.toks[p.pos]
+	if t.kind != dotEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *dotParser) accept(punct string) bool {
+	if t := p.peek(); t.kind == dotPunct && t.value == punct {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *dotParser) expect(punct string) error {
+	if !p.accept(punct) {
+		return p.errorf("expected %q", punct)
+	}
+	return nil
+}
+
+func (p *dotParser) expectID() (dotToken, error) {
+	t := p.next()
+	if t.kind != dotID {
+		return t, fmt.Errorf("line %d: expected an identifier", t.line)
+	}
+	return t, nil
+}
+
+func (p *dotParser) errorf(format string, args ...interface{}) error {
+	t := p.peek()
+	found := t.value
+	if t.kind == dotEOF {
+		found = "end of input"
+	}
+	return fmt.Errorf("line %d: %s, found %q", t.line, fmt.Sprintf(format, args...), found)
+}
+
+// isKeyword checks for an unquoted keyword, which dot treats case
+// insensitively.
+func isKeyword(t dotToken, keyword string) bool {
+	return t.kind == dotID && !t.quoted && strings.EqualFold(t.value, keyword)
+}
+
+func (p *dotParser) parseGraph() error {
+	if isKeyword(p.peek(), "strict") {
+		p.next()
+	}
+	if !isKeyword(p.next(), "digraph") {
+		return fmt.Errorf("expected a digraph")
+	}
+	if p.peek().kind == dotID {
+		p.next()
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	if err := p.parseStmts(dotRootGraph); err != nil {
+		return err
+	}
+	if t := p.peek(); t.kind != dotEOF {
+		return p.errorf("expected end of input")
+	}
+	return nil
+}
+
+// parseStmts reads statements up to and including the closing brace of the
+// current block.
+func (p *dotParser) parseStmts(block string) error {
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == dotEOF:
+			return p.errorf("expected %q", "}")
+
+		case t.kind == dotPunct && t.value == "}":
+			p.next()
+			return nil
+
+		case t.kind == dotPunct && t.value == ";":
+			p.next()
+
+		case t.kind == dotPunct && t.value == "{":
+			// anonymous subgraphs, such as rank groups, don't change
+			// which graph vertices belong to.
+			p.next()
+			if err := p.parseStmts(block); err != nil {
+				return err
+			}
+
+		case isKeyword(t, "subgraph"):
+			p.next()
+			name := block
+			if p.peek().kind == dotID {
+				name = p.next().value
+			}
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			if err := p.parseStmts(name); err != nil {
+				return err
+			}
+
+		case isKeyword(t, "graph") || isKeyword(t, "node") || isKeyword(t, "edge"):
+			// defaults for the graph, nodes or edges aren't retained
+			p.next()
+			if _, err := p.parseAttrLists(); err != nil {
+				return err
+			}
+
+		case t.kind == dotID:
+			if err := p.parseNodeOrEdge(block); err != nil {
+				return err
+			}
+
+		default:
+			return p.errorf("unexpected token")
+		}
+	}
+}
+
+func (p *dotParser) parseNodeOrEdge(block string) error {
+	first := p.next()
+
+	// graph attributes aren't retained
+	if p.accept("=") {
+		_, err := p.expectID()
+		return err
+	}
+
+	ids := []string{first.value}
+	for p.accept("->") {
+		t, err := p.expectID()
+		if err != nil {
+			return err
+		}
+		ids = append(ids, t.value)
+	}
+
+	attrs, err := p.parseAttrLists()
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 1 {
+		n := p.node(block, ids[0])
+		for k, v := range attrs {
+			n.attrs[k] = v
+		}
+		return nil
+	}
+
+	for i := 1; i < len(ids); i++ {
+		p.edges = append(p.edges, &dotEdge{
+			source: p.node(block, ids[i-1]),
+			target: p.node(block, ids[i]),
+			attrs:  attrs,
+		})
+	}
+	return nil
+}
+
+// parseAttrLists reads any number of consecutive attribute lists, merging
+// them together.
+func (p *dotParser) parseAttrLists() (map[string]string, error) {
+	attrs := make(map[string]string)
+	for p.accept("[") {
+		for !p.accept("]") {
+			key, err := p.expectID()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			value, err := p.expectID()
+			if err != nil {
+				return nil, err
+			}
+			attrs[key.value] = value.value
+
+			if !p.accept(",") {
+				p.accept(";")
+			}
+		}
+	}
+	return attrs, nil
+}
+
+// node returns the node for the given dot ID, declaring it if this is the
+// first time it has been seen.
+func (p *dotParser) node(block, id string) *dotNode {
+	graph, name := dotGraphName(block), id
+	if strings.HasPrefix(id, "[") {
+		if end := strings.Index(id, "] "); end > 0 {
+			graph, name = id[1:end], id[end+2:]
+		}
+	}
+
+	key := [2]string{graph, name}
+	if n, ok := p.byName[key]; ok {
+		return n
+	}
+
+	n := &dotNode{
+		graph: graph,
+		name:  name,
+		attrs: make(map[string]string),
+	}
+	p.nodes = append(p.nodes, n)
+	p.byName[key] = n
+	return n
+}
+
+// dotGraphName returns the graph name for a subgraph block, undoing the
+// cluster_ prefix added by Dot.
+func dotGraphName(block string) string {
+	return strings.TrimPrefix(block, "cluster_")
+}
+
+// build constructs the Graph, with any subgraphs, from the parsed statements.
+func (p *dotParser) build() (*Graph, error) {
+	graphs := map[string]*Graph{dotRootGraph: {}}
+	for _, n := range p.nodes {
+		if _, ok := graphs[n.graph]; !ok {
+			graphs[n.graph] = &Graph{}
+		}
+	}
+
+	var names []string
+	for name := range graphs {
+		if name != dotRootGraph {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// Find the vertex containing each subgraph, preferring the root graph
+	// and otherwise the first graph by name.
+	containers := make(map[*dotNode]string)
+	for _, name := range names {
+		var container *dotNode
+		for _, n := range p.nodes {
+			if n.name != name || n.graph == name {
+				continue
+			}
+			if container == nil || n.graph == dotRootGraph ||
+				(container.graph != dotRootGraph && n.graph < container.graph) {
+				container = n
+			}
+		}
+
+		if container == nil {
+			container = p.node(dotRootGraph, name)
+		}
+		containers[container] = name
+	}
+
+	vertices := make(map[*dotNode]Vertex, len(p.nodes))
+	for _, n := range p.nodes {
+		pv := &ParsedVertex{
+			ID:    fmt.Sprintf("[%s] %s", n.graph, n.name),
+			Name:  n.name,
+			Attrs: n.attrs,
+		}
+
+		var v Vertex = pv
+		if name, ok := containers[n]; ok {
+			v = &ParsedSubgraph{ParsedVertex: pv, Graph: graphs[name]}
+		}
+
+		vertices[n] = v
+		graphs[n.graph].Add(v)
+	}
+
+	for _, e := range p.edges {
+		if e.source.graph != e.target.graph {
+			return nil, fmt.Errorf("edge from %q to %q crosses between subgraphs",
+				VertexName(vertices[e.source]), VertexName(vertices[e.target]))
+		}
+
+		source, target := vertices[e.source], vertices[e.target]
+		attrs := e.attrs
+		weight, err := strconv.ParseFloat(attrs["weight"], 64)
+		weighted := err == nil
+		if weighted {
+			attrs = make(map[string]string, len(e.attrs))
+			for k, v := range e.attrs {
+				if k != "weight" {
+					attrs[k] = v
+				}
+			}
+		}
+		graphs[e.source.graph].Connect(rebuildEdge(source, target, "", weight, weighted, attrs))
+	}
+
+	return graphs[dotRootGraph], nil
+}
@@ -0,0 +1,187 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDot_basic(t *testing.T) {
+	g, err := ParseDot(strings.NewReader(testGraphDotBasicStr))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual := strings.TrimSpace(g.String())
+	expected := strings.TrimSpace(testParseDotBasicStr)
+	if actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestParseDot_quoted(t *testing.T) {
+	g, err := ParseDot(strings.NewReader(testGraphDotQuotedStr))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual := strings.TrimSpace(g.String())
+	expected := strings.TrimSpace(testParseDotQuotedStr)
+	if actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestParseDot_attrs(t *testing.T) {
+	g, err := ParseDot(strings.NewReader(testGraphDotAttrsStr))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	vs := g.Vertices()
+	if len(vs) != 1 {
+		t.Fatalf("expected 1 vertex, got: %#v", vs)
+	}
+	v := vs[0].(*ParsedVertex)
+	if v.Name != "foo" || v.Attrs["foo"] != "bar" {
+		t.Fatalf("bad vertex: %#v", v)
+	}
+}
+
+func TestParseDot_edgeAttrs(t *testing.T) {
+	g, err := ParseDot(strings.NewReader(testGraphDotEdgeAttrsStr))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	edges := g.Edges()
+	if len(edges) != 1 {
+		t.Fatalf("expected 1 edge, got: %#v", edges)
+	}
+	ae, ok := edges[0].(AttrEdge)
+	if !ok || ae.Attrs()["label"] != "notifies" {
+		t.Fatalf("bad edge: %#v", edges[0])
+	}
+}
+
+func TestParseDot_weights(t *testing.T) {
+	g, err := ParseDot(strings.NewReader(testGraphDotWeightsStr))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, e := range g.Edges() {
+		name := VertexName(e.Source()) + " -> " + VertexName(e.Target())
+		switch name {
+		case "1 -> 2":
+			if _, ok := e.(WeightedEdge); !ok || edgeWeight(e) != 2.5 {
+				t.Fatalf("bad edge %s: %#v", name, e)
+			}
+			if _, ok := e.(AttrEdge); ok {
+				t.Fatalf("weight should not be kept as an attribute: %#v", e)
+			}
+		case "2 -> 3":
+			ae, ok := e.(AttrEdge)
+			if !ok || edgeWeight(e) != 10 || ae.Attrs()["label"] != "slow" || ae.Attrs()["weight"] != "" {
+				t.Fatalf("bad edge %s: %#v", name, e)
+			}
+		}
+	}
+}
+
+func TestParseDot_subgraph(t *testing.T) {
+	var sub Graph
+	sub.Add("x")
+	sub.Add("y")
+	sub.Connect(BasicEdge("x", "y"))
+
+	var g Graph
+	s := &testSubgrapher{name: "sub", graph: &sub}
+	g.Add("a")
+	g.Add(s)
+	g.Connect(BasicEdge("a", s))
+
+	parsed, err := ParseDot(strings.NewReader(string(g.Dot(nil))))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if parsed.String() != g.String() {
+		t.Fatalf("got:\n%s\nwant:\n%s", parsed.String(), g.String())
+	}
+
+	var sg *ParsedSubgraph
+	for _, v := range parsed.Vertices() {
+		if v, ok := v.(*ParsedSubgraph); ok {
+			sg = v
+		}
+	}
+	if sg == nil {
+		t.Fatal("subgraph not found")
+	}
+	if sg.Graph.String() != sub.String() {
+		t.Fatalf("got:\n%s\nwant:\n%s", sg.Graph.String(), sub.String())
+	}
+}
+
+func TestParseDot_plain(t *testing.T) {
+	src := `
+// a hand written graph
+digraph deps {
+	rankdir = LR;
+	node [shape = box];
+	a -> b -> c [label = "x"];
+	subgraph cluster_s {
+		d;
+	}
+}`
+
+	g, err := ParseDot(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual := strings.TrimSpace(g.String())
+	expected := strings.TrimSpace(testParseDotPlainStr)
+	if actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestParseDot_errors(t *testing.T) {
+	cases := []string{
+		``,
+		`graph { a }`,
+		`digraph { a -> }`,
+		`digraph { "a }`,
+		`digraph { a [b = ] }`,
+		`digraph { a `,
+		`digraph { "[x] a" -> "[y] b" }`,
+	}
+
+	for _, src := range cases {
+		if _, err := ParseDot(strings.NewReader(src)); err == nil {
+			t.Fatalf("expected an error parsing %q", src)
+		}
+	}
+}
+
+const testParseDotBasicStr = `
+1
+  3
+3
+`
+
+const testParseDotQuotedStr = `
+name["with-quotes"]
+  other
+other
+`
+
+const testParseDotPlainStr = `
+a
+  b
+b
+  c
+c
+s
+`
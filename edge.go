@@ -20,8 +20,10 @@ type basicEdge struct {
 	S, T Vertex
 }
 
+// Hashcode is made of the hashcodes of the vertices, so that an edge between
+// Hashable vertices is the same edge whichever values they are given as.
 func (e *basicEdge) Hashcode() interface{} {
-	return [...]interface{}{e.S, e.T}
+	return [...]interface{}{hashcode(e.S), hashcode(e.T)}
 }
 
 func (e *basicEdge) Source() Vertex {
@@ -31,3 +33,195 @@ func (e *basicEdge) Source() Vertex {
 func (e *basicEdge) Target() Vertex {
 	return e.T
 }
+
+// WeightedEdge is an Edge with an associated weight, such as a cost or a
+// duration. Edges that don't implement WeightedEdge are treated as having a
+// weight of 1 by the path finding methods.
+type WeightedEdge interface {
+	Edge
+
+	Weight() float64
+}
+
+// BasicWeightedEdge returns a WeightedEdge implementation that tracks the
+// source, target and weight given as-is. It is considered the same edge as
+// a BasicEdge with the same source and target.
+func BasicWeightedEdge(source, target Vertex, weight float64) WeightedEdge {
+	return &basicWeightedEdge{basicEdge: basicEdge{S: source, T: target}, W: weight}
+}
+
+// basicWeightedEdge is a basicEdge that also has a weight.
+type basicWeightedEdge struct {
+	basicEdge
+	W float64
+}
+
+func (e *basicWeightedEdge) Weight() float64 {
+	return e.W
+}
+
+// edgeWeight returns the weight of e, defaulting to 1 for edges which don't
+// implement WeightedEdge.
+func edgeWeight(e Edge) float64 {
+	if w, ok := e.(WeightedEdge); ok {
+		return w.Weight()
+	}
+	return 1
+}
+
+// AttrEdge is an optional interface that can be implemented by an Edge to
+// carry arbitrary attributes, such as the kind of dependency it represents.
+// The attributes are included in the marshaled output of the graph, and as
+// attributes of the edge in the dot output.
+type AttrEdge interface {
+	Edge
+
+	Attrs() map[string]string
+}
+
+// BasicAttrEdge returns an AttrEdge implementation that tracks the source,
+// target and attributes given as-is. It is considered the same edge as a
+// BasicEdge with the same source and target.
+func BasicAttrEdge(source, target Vertex, attrs map[string]string) AttrEdge {
+	return &basicAttrEdge{basicEdge: basicEdge{S: source, T: target}, A: attrs}
+}
+
+// basicAttrEdge is a basicEdge that also has attributes.
+type basicAttrEdge struct {
+	basicEdge
+	A map[string]string
+}
+
+func (e *basicAttrEdge) Attrs() map[string]string {
+	return e.A
+}
+
+// basicWeightedAttrEdge is a basicEdge with both a weight and attributes.
+type basicWeightedAttrEdge struct {
+	basicEdge
+	W float64
+	A map[string]string
+}
+
+func (e *basicWeightedAttrEdge) Weight() float64 {
+	return e.W
+}
+
+func (e *basicWeightedAttrEdge) Attrs() map[string]string {
+	return e.A
+}
+
+// LabeledEdge is an optional interface that can be implemented by an Edge
+// to tell it apart from other edges between the same two vertices, such as a
+// "build" and a "runtime" dependency. Edges with different labels are
+// different edges, and can all be in a graph at once. An empty label is the
+// same as no label.
+type LabeledEdge interface {
+	Edge
+
+	Label() string
+}
+
+// BasicLabeledEdge returns a LabeledEdge implementation that tracks the
+// source, target and label given as-is. With an empty label, it is
+// considered the same edge as a BasicEdge with the same source and target.
+func BasicLabeledEdge(source, target Vertex, label string) LabeledEdge {
+	return &basicLabeledEdge{basicEdge: basicEdge{S: source, T: target}, L: label, W: 1}
+}
+
+// basicLabeledEdge is a basicEdge that also has a label. It keeps the weight
+// and attributes of the edge it was copied from, if any.
+type basicLabeledEdge struct {
+	basicEdge
+	L string
+	W float64
+	A map[string]string
+}
+
+func (e *basicLabeledEdge) Hashcode() interface{} {
+	if e.L == "" {
+		return e.basicEdge.Hashcode()
+	}
+	return [...]interface{}{hashcode(e.S), hashcode(e.T), e.L}
+}
+
+func (e *basicLabeledEdge) Label() string {
+	return e.L
+}
+
+func (e *basicLabeledEdge) Weight() float64 {
+	return e.W
+}
+
+func (e *basicLabeledEdge) Attrs() map[string]string {
+	return e.A
+}
+
+// edgeLabel returns the label of e, or an empty string for edges which don't
+// implement LabeledEdge.
+func edgeLabel(e Edge) string {
+	if le, ok := e.(LabeledEdge); ok {
+		return le.Label()
+	}
+	return ""
+}
+
+// copyEdge returns an edge from source to target which has the same weight
+// and attributes as e, along with its label. If the source and target are
+// unchanged, e itself is returned.
+func copyEdge(e Edge, source, target Vertex) Edge {
+	if hashcode(e.Source()) == hashcode(source) && hashcode(e.Target()) == hashcode(target) {
+		return e
+	}
+
+	we, weighted := e.(WeightedEdge)
+	ae, attributed := e.(AttrEdge)
+	if label := edgeLabel(e); label != "" {
+		c := &basicLabeledEdge{basicEdge: basicEdge{S: source, T: target}, L: label, W: edgeWeight(e)}
+		if attributed {
+			c.A = ae.Attrs()
+		}
+		return c
+	}
+
+	switch {
+	case weighted && attributed:
+		return &basicWeightedAttrEdge{
+			basicEdge: basicEdge{S: source, T: target},
+			W:         we.Weight(),
+			A:         ae.Attrs(),
+		}
+	case weighted:
+		return BasicWeightedEdge(source, target, we.Weight())
+	case attributed:
+		return BasicAttrEdge(source, target, ae.Attrs())
+	default:
+		return BasicEdge(source, target)
+	}
+}
+
+// rebuildEdge returns an edge from source to target as one of the basic edge
+// types of this package, for decoding an edge that was serialized along with
+// its label, weight and attributes. The weight is only kept when weighted is
+// set, and the attributes when there are any.
+func rebuildEdge(source, target Vertex, label string, weight float64, weighted bool, attrs map[string]string) Edge {
+	if len(attrs) == 0 {
+		attrs = nil
+	}
+	if !weighted {
+		weight = 1
+	}
+
+	switch {
+	case label != "":
+		return &basicLabeledEdge{basicEdge: basicEdge{S: source, T: target}, L: label, W: weight, A: attrs}
+	case weighted && attrs != nil:
+		return &basicWeightedAttrEdge{basicEdge: basicEdge{S: source, T: target}, W: weight, A: attrs}
+	case weighted:
+		return BasicWeightedEdge(source, target, weight)
+	case attrs != nil:
+		return BasicAttrEdge(source, target, attrs)
+	default:
+		return BasicEdge(source, target)
+	}
+}
@@ -24,3 +24,40 @@ func TestBasicEdgeHashcode_pointer(t *testing.T) {
 		t.Fatalf("bad")
 	}
 }
+
+func TestBasicWeightedEdgeHashcode(t *testing.T) {
+	e1 := BasicWeightedEdge(1, 2, 3)
+	e2 := BasicEdge(1, 2)
+	if e1.Hashcode() != e2.Hashcode() {
+		t.Fatalf("bad")
+	}
+	if e1.Weight() != 3 {
+		t.Fatalf("bad weight: %v", e1.Weight())
+	}
+}
+
+func TestBasicAttrEdgeHashcode(t *testing.T) {
+	e1 := BasicAttrEdge(1, 2, map[string]string{"label": "foo"})
+	e2 := BasicEdge(1, 2)
+	if e1.Hashcode() != e2.Hashcode() {
+		t.Fatalf("bad")
+	}
+}
+
+func TestBasicLabeledEdgeHashcode(t *testing.T) {
+	build := BasicLabeledEdge(1, 2, "build")
+	if build.Hashcode() == BasicLabeledEdge(1, 2, "runtime").Hashcode() {
+		t.Fatal("edges with different labels should differ")
+	}
+	if build.Hashcode() != BasicLabeledEdge(1, 2, "build").Hashcode() {
+		t.Fatal("edges with the same label should match")
+	}
+	if BasicLabeledEdge(1, 2, "").Hashcode() != BasicEdge(1, 2).Hashcode() {
+		t.Fatal("an empty label should match an unlabeled edge")
+	}
+
+	c := copyEdge(build, 2, 1)
+	if edgeLabel(c) != "build" {
+		t.Fatalf("copy lost its label: %#v", c)
+	}
+}
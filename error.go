@@ -1,5 +1,7 @@
 package dag
 
+import "fmt"
+
 // nativeError is a Diagnostic implementation that wraps a normal Go error
 type nativeError struct {
 	err error
@@ -20,3 +22,27 @@ func (e nativeError) Description() Description {
 func (e nativeError) Unwrap() error {
 	return e.err
 }
+
+// skippedVertex is a warning Diagnostic recording a vertex that was left out
+// of a walk.
+type skippedVertex struct {
+	v      Vertex
+	reason string
+}
+
+var _ VertexDiagnostic = skippedVertex{}
+
+func (s skippedVertex) Severity() Severity {
+	return Warning
+}
+
+func (s skippedVertex) Vertex() Vertex {
+	return s.v
+}
+
+func (s skippedVertex) Description() Description {
+	return Description{
+		Summary: fmt.Sprintf("Skipped %s", VertexName(s.v)),
+		Detail:  s.reason,
+	}
+}
@@ -0,0 +1,136 @@
+package dag
+
+import "fmt"
+
+// FilterOpts are the options for Filter.
+type FilterOpts struct {
+	// Contract keeps the dependencies between the remaining vertices that
+	// passed through removed ones. If a remaining vertex depended on another
+	// through any number of removed vertices, the result has a BasicEdge
+	// between the two.
+	Contract bool
+}
+
+// Filter returns a new graph containing only the vertices for which keep
+// returns true, and the edges between them. The vertices and edges
+// themselves are not copied.
+//
+// Complexity: O(V+E), or O(V*(V+E)) with FilterOpts.Contract
+func (g *Graph) Filter(keep func(Vertex) bool, opts *FilterOpts) *Graph {
+	if opts == nil {
+		opts = &FilterOpts{}
+	}
+
+	result := &Graph{}
+	result.init()
+
+	for _, v := range g.vertices {
+		if keep(v) {
+			result.Add(v)
+			g.copyVertexAttrs(result, v, v)
+		}
+	}
+
+	for _, raw := range g.edges {
+		e := raw.(Edge)
+		if result.HasVertex(e.Source()) && result.HasVertex(e.Target()) {
+			result.Connect(e)
+		}
+	}
+
+	if !opts.Contract {
+		return result
+	}
+
+	// Search from each remaining vertex through the removed ones, connecting
+	// it to the remaining vertices found on the other side.
+	for _, source := range result.Vertices() {
+		seen := make(Set)
+		var stack []Vertex
+		for _, t := range g.downEdgesNoCopy(source) {
+			if !result.HasVertex(t) {
+				stack = append(stack, t)
+			}
+		}
+
+		for len(stack) > 0 {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if seen.Include(v) {
+				continue
+			}
+			seen.Add(v)
+
+			for _, t := range g.downEdgesNoCopy(v) {
+				if result.HasVertex(t) {
+					result.Connect(BasicEdge(source, t))
+				} else {
+					stack = append(stack, t)
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// Filter is like Graph.Filter, returning an AcyclicGraph.
+func (g *AcyclicGraph) Filter(keep func(Vertex) bool, opts *FilterOpts) *AcyclicGraph {
+	return &AcyclicGraph{Graph: *g.Graph.Filter(keep, opts)}
+}
+
+// filterEdges returns a new graph with every vertex of g, and only the edges
+// for which keep returns true.
+func (g *AcyclicGraph) filterEdges(keep func(Edge) bool) *AcyclicGraph {
+	result := &AcyclicGraph{}
+	for _, v := range g.vertices {
+		result.Add(v)
+	}
+	for _, raw := range g.edges {
+		if e := raw.(Edge); keep(e) {
+			result.Connect(e)
+		}
+	}
+	return result
+}
+
+// Neighborhood returns the part of the graph around v, for a focused view of
+// a large graph. It contains v, the ancestors of v up to up levels away, and
+// the descendants of v down to down levels away, along with the edges
+// between all of them. A negative number of levels has no limit.
+//
+// Complexity: O(V+E)
+func (g *AcyclicGraph) Neighborhood(v Vertex, up, down int) (*AcyclicGraph, error) {
+	if !g.HasVertex(v) {
+		return nil, fmt.Errorf("vertex %s is not in the graph", VertexName(v))
+	}
+
+	keep := make(Set)
+	keep.Add(v)
+	withinDepth(v, up, g.upEdgesNoCopy, keep)
+	withinDepth(v, down, g.downEdgesNoCopy, keep)
+
+	return g.Filter(func(v Vertex) bool { return keep.Include(v) }, nil), nil
+}
+
+// withinDepth adds every vertex reachable from v by following next, up to
+// depth steps away, to s. A negative depth has no limit.
+func withinDepth(v Vertex, depth int, next func(Vertex) Set, s Set) {
+	frontier := []Vertex{v}
+	seen := make(Set)
+	seen.Add(v)
+	for d := 0; d != depth && len(frontier) > 0; d++ {
+		var following []Vertex
+		for _, u := range frontier {
+			for _, n := range next(u) {
+				if seen.Include(n) {
+					continue
+				}
+				seen.Add(n)
+				s.Add(n)
+				following = append(following, n)
+			}
+		}
+		frontier = following
+	}
+}
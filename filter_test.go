@@ -0,0 +1,113 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphFilter(t *testing.T) {
+	g := testFilterGraph()
+
+	actual := strings.TrimSpace(g.Filter(testFilterKeep, nil).String())
+	expected := strings.TrimSpace(testGraphFilterStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+
+	// the original is unchanged
+	if len(g.Vertices()) != 6 {
+		t.Fatalf("bad: %s", g.String())
+	}
+}
+
+func TestGraphFilter_contract(t *testing.T) {
+	g := testFilterGraph()
+
+	filtered := g.Filter(testFilterKeep, &FilterOpts{Contract: true})
+	actual := strings.TrimSpace(filtered.String())
+	expected := strings.TrimSpace(testGraphFilterContractStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+
+	// existing edges are kept as they were
+	for _, e := range filtered.EdgesFrom("db.a") {
+		if e.Target() == "db.b" {
+			if _, ok := e.(WeightedEdge); !ok {
+				t.Fatalf("edge was replaced: %#v", e)
+			}
+		}
+	}
+}
+
+func TestAcyclicGraphNeighborhood(t *testing.T) {
+	var g AcyclicGraph
+	for i := 1; i <= 7; i++ {
+		g.Add(i)
+	}
+	// a chain 1 -> 2 -> 3 -> 4 -> 5, with 6 depending on 3 and 3 on 7
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 4))
+	g.Connect(BasicEdge(4, 5))
+	g.Connect(BasicEdge(6, 3))
+	g.Connect(BasicEdge(3, 7))
+
+	cases := []struct {
+		up, down int
+		expected string
+	}{
+		{0, 0, "3"},
+		{1, 1, "2\n  3\n3\n  4\n  7\n4\n6\n  3\n7"},
+		{1, 0, "2\n  3\n3\n6\n  3"},
+		{0, -1, "3\n  4\n  7\n4\n  5\n5\n7"},
+	}
+	for _, tc := range cases {
+		n, err := g.Neighborhood(3, tc.up, tc.down)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := strings.TrimSpace(n.String())
+		if actual != tc.expected {
+			t.Errorf("up %d, down %d:\n%s", tc.up, tc.down, actual)
+		}
+	}
+
+	if _, err := g.Neighborhood(42, 1, 1); err == nil {
+		t.Fatal("should error for a missing vertex")
+	}
+}
+
+// testFilterGraph returns a graph where the "db." vertices depend on each
+// other both directly and through other vertices.
+func testFilterGraph() *Graph {
+	var g Graph
+	for _, v := range []string{"db.a", "db.b", "db.c", "app", "cache", "web"} {
+		g.Add(v)
+	}
+	g.Connect(BasicWeightedEdge("db.a", "db.b", 2))
+	g.Connect(BasicEdge("db.a", "app"))
+	g.Connect(BasicEdge("app", "cache"))
+	g.Connect(BasicEdge("cache", "db.c"))
+	g.Connect(BasicEdge("web", "db.a"))
+	return &g
+}
+
+func testFilterKeep(v Vertex) bool {
+	return strings.HasPrefix(VertexName(v), "db.")
+}
+
+const testGraphFilterStr = `
+db.a
+  db.b
+db.b
+db.c
+`
+
+const testGraphFilterContractStr = `
+db.a
+  db.b
+  db.c
+db.b
+db.c
+`
@@ -0,0 +1,64 @@
+package dag
+
+// Flatten inlines every Subgrapher vertex into the graph, so that a single
+// walk covers the vertices of all nested graphs. The vertices and edges of
+// each subgraph, which is itself flattened first, are added to the graph and
+// the subgraph vertex is removed.
+//
+// Edges to and from a subgraph vertex are rewired to keep the same ordering:
+// an edge to the subgraph vertex is connected to each root of the subgraph,
+// and an edge from it is connected from each leaf of the subgraph. An empty
+// subgraph is replaced with edges between its dependents and dependencies.
+// Rewired edges keep their weight and attributes.
+//
+// The subgraphs themselves are not modified. A vertex that appears in more
+// than one subgraph, or in both a subgraph and the graph, is only added once.
+func (g *AcyclicGraph) Flatten() {
+	for _, v := range g.Vertices() {
+		sg, ok := marshalSubgrapher(v)
+		if !ok {
+			continue
+		}
+
+		sub := &AcyclicGraph{Graph: *sg.Snapshot()}
+		sub.Flatten()
+		g.inline(v, sub)
+	}
+}
+
+// inline replaces v with the contents of sub.
+func (g *AcyclicGraph) inline(v Vertex, sub *AcyclicGraph) {
+	in := g.EdgesTo(v)
+	out := g.EdgesFrom(v)
+	g.Remove(v)
+
+	for _, sv := range sub.Vertices() {
+		g.Add(sv)
+	}
+	for _, e := range sub.Edges() {
+		g.Connect(e)
+	}
+
+	roots, leaves := sub.Roots(), sub.Leaves()
+	if len(roots) == 0 {
+		// Nothing to rewire through, so connect the dependents of v directly
+		// to its dependencies.
+		for _, ie := range in {
+			for _, oe := range out {
+				g.Connect(copyEdge(ie, ie.Source(), oe.Target()))
+			}
+		}
+		return
+	}
+
+	for _, e := range in {
+		for _, r := range roots {
+			g.Connect(copyEdge(e, e.Source(), r))
+		}
+	}
+	for _, e := range out {
+		for _, l := range leaves {
+			g.Connect(copyEdge(e, l, e.Target()))
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAcyclicGraphFlatten(t *testing.T) {
+	var inner AcyclicGraph
+	inner.Add("inner1")
+	inner.Add("inner2")
+	inner.Connect(BasicEdge("inner1", "inner2"))
+
+	var sub AcyclicGraph
+	sub.Add("sub1")
+	sub.Add("sub2")
+	iv := sub.Add(&testSubgrapher{name: "inner", graph: &inner})
+	sub.Connect(BasicEdge("sub1", iv))
+	sub.Connect(BasicEdge("sub2", iv))
+
+	var g AcyclicGraph
+	g.Add("a")
+	g.Add("b")
+	sv := g.Add(&testSubgrapher{name: "sub", graph: &sub})
+	g.Connect(BasicEdge("a", sv))
+	g.Connect(BasicAttrEdge(sv, "b", map[string]string{"color": "red"}))
+
+	g.Flatten()
+
+	actual := strings.TrimSpace(g.String())
+	expected := strings.TrimSpace(testGraphFlattenStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+
+	if err := g.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range g.EdgesTo("b") {
+		if e.(AttrEdge).Attrs()["color"] != "red" {
+			t.Fatalf("rewired edge lost its attributes: %s", VertexName(e.Source()))
+		}
+	}
+
+	// the subgraphs are left alone
+	if len(sub.Vertices()) != 3 || len(inner.Vertices()) != 2 {
+		t.Fatal("subgraphs were modified")
+	}
+}
+
+func TestAcyclicGraphFlatten_empty(t *testing.T) {
+	var g AcyclicGraph
+	g.Add("a")
+	g.Add("b")
+	empty := g.Add(&testSubgrapher{name: "empty", graph: &AcyclicGraph{}})
+	g.Connect(BasicEdge("a", empty))
+	g.Connect(BasicEdge(empty, "b"))
+
+	g.Flatten()
+
+	if g.HasVertex(empty) {
+		t.Fatal("subgraph vertex should be removed")
+	}
+	if !g.HasEdge(BasicEdge("a", "b")) {
+		t.Fatalf("missing edge through empty subgraph:\n\n%s", g.String())
+	}
+}
+
+const testGraphFlattenStr = `
+a
+  sub1
+  sub2
+b
+inner1
+  inner2
+inner2
+  b
+sub1
+  inner1
+sub2
+  inner1
+`
@@ -0,0 +1,137 @@
+package dag
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// checkParsed fails the test if the invariants of g, or of any subgraph
+// within it, don't hold.
+func checkParsed(t *testing.T, g *Graph) {
+	t.Helper()
+	if err := CheckInvariants(g); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range g.Vertices() {
+		if sg, ok := v.(*ParsedSubgraph); ok {
+			checkParsed(t, sg.Graph)
+		}
+	}
+}
+
+func FuzzParseJSON(f *testing.F) {
+	var g Graph
+	g.Add("a")
+	g.Add("b")
+	g.Add(&testSubgrapher{name: "sub", graph: &AcyclicGraph{}})
+	g.Connect(BasicLabeledEdge("a", "b", "x"))
+	g.Connect(BasicAttrEdge("b", "sub", map[string]string{"color": "red"}))
+	g.SetVertexAttr("a", "shape", "box")
+	js, err := g.MarshalJSON()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(js)
+	f.Add([]byte(`{}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		g, err := ParseJSON(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		checkParsed(t, g)
+
+		js, err := g.MarshalJSON()
+		if err != nil {
+			t.Fatalf("can't marshal parsed graph: %s", err)
+		}
+		if _, err := ParseJSON(bytes.NewReader(js)); err != nil {
+			t.Fatalf("can't parse marshaled graph: %s\n%s", err, js)
+		}
+	})
+}
+
+func FuzzParseDot(f *testing.F) {
+	f.Add(testGraphDotBasicStr)
+	f.Add(testGraphDotQuotedStr)
+	f.Add(testGraphDotAttrsStr)
+	f.Add("digraph { a -> b; b -> c }")
+
+	f.Fuzz(func(t *testing.T, src string) {
+		g, err := ParseDot(strings.NewReader(src))
+		if err != nil {
+			return
+		}
+		checkParsed(t, g)
+	})
+}
+
+func FuzzParseYAML(f *testing.F) {
+	f.Add(testYAMLGraph)
+	f.Add("nodes:\n  a:\n    depends_on: [b]\n  b:\n")
+
+	f.Fuzz(func(t *testing.T, src string) {
+		g, err := ParseYAML(strings.NewReader(src))
+		if err != nil {
+			return
+		}
+		checkParsed(t, &g.Graph)
+		if cycles := g.Cycles(); len(cycles) > 0 {
+			t.Fatalf("parsed graph has cycles: %v", cycles)
+		}
+	})
+}
+
+// FuzzGraph_mutations applies a sequence of changes to a graph, read from
+// the fuzzed bytes three at a time as an operation and two vertices, and
+// checks the invariants after each one.
+func FuzzGraph_mutations(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 0, 2, 0, 2, 1, 2, 4, 1, 2, 1, 2, 0})
+	f.Add([]byte{2, 1, 2, 2, 2, 3, 6, 2, 0, 7, 1, 3, 8, 0, 0, 5, 3, 1})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		var g Graph
+		var snapshots []*Graph
+		for len(ops) >= 3 {
+			op, a, b := ops[0]%10, int(ops[1]%8), int(ops[2]%8)
+			ops = ops[3:]
+
+			switch op {
+			case 0:
+				g.Add(a)
+			case 1:
+				g.Remove(a)
+			case 2:
+				g.Add(a)
+				g.Add(b)
+				g.Connect(BasicEdge(a, b))
+			case 3:
+				g.Add(a)
+				g.Add(b)
+				g.Connect(BasicLabeledEdge(a, b, string(rune('x'+b%2))))
+			case 4:
+				g.RemoveEdge(BasicEdge(a, b))
+			case 5:
+				g.Replace(a, b)
+			case 6:
+				g.Splice(a)
+			case 7:
+				g.Contract(a, b, a)
+			case 8:
+				snapshots = append(snapshots, g.Snapshot())
+			case 9:
+				g.VertexByName(VertexName(a))
+			}
+
+			if err := CheckInvariants(&g); err != nil {
+				t.Fatalf("after op %d on %d and %d: %s", op, a, b, err)
+			}
+		}
+		for _, s := range snapshots {
+			if err := CheckInvariants(s); err != nil {
+				t.Fatalf("snapshot: %s", err)
+			}
+		}
+	})
+}
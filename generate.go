@@ -0,0 +1,116 @@
+package dag
+
+import (
+	"math/rand"
+)
+
+// GenOpts are the options for GenerateRandomDAG.
+type GenOpts struct {
+	// Layers splits the vertices into this many layers of near equal size,
+	// with each edge going from a vertex to one in an earlier layer, which
+	// gives the deep, narrow shape of a build or deployment pipeline. When 0,
+	// edges are chosen uniformly from all pairs of vertices, and oriented by
+	// a random order of the vertices so the result is acyclic.
+	Layers int
+
+	// Vertex returns the vertex to add for each number from 0 to n-1. The
+	// default uses the int itself.
+	Vertex func(i int) Vertex
+}
+
+// GenerateRandomDAG returns a random acyclic graph with n vertices and m
+// edges, for benchmarking algorithms and fuzzing walk callbacks against
+// realistic graph shapes. The same arguments always generate the same graph.
+//
+// The vertices are numbered in a random order, so the numbers do not reveal
+// the topological order. If m is more than the number of edges the shape
+// allows, every possible edge is added.
+//
+// Complexity: O(V + E) on average
+func GenerateRandomDAG(n, m int, seed int64, opts *GenOpts) *AcyclicGraph {
+	if opts == nil {
+		opts = &GenOpts{}
+	}
+	if n < 0 {
+		n = 0
+	}
+	if m < 0 {
+		m = 0
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	g := NewAcyclicGraph(n, m)
+
+	vertices := make([]Vertex, n)
+	for i, p := range r.Perm(n) {
+		if opts.Vertex != nil {
+			vertices[i] = opts.Vertex(p)
+		} else {
+			vertices[i] = p
+		}
+		g.Add(vertices[i])
+	}
+
+	// Vertices are ordered by their index, with each edge going from a later
+	// vertex to an earlier one. layer returns the layer of an index.
+	layer := func(i int) int { return i }
+	if opts.Layers > 0 {
+		layers := opts.Layers
+		if layers > n {
+			layers = n
+		}
+		layer = func(i int) int { return i * layers / n }
+	}
+
+	// maxEdges counts the pairs of vertices in different layers.
+	maxEdges := n * (n - 1) / 2
+	if opts.Layers > 0 {
+		sizes := make(map[int]int)
+		for i := 0; i < n; i++ {
+			sizes[layer(i)]++
+		}
+		for _, size := range sizes {
+			maxEdges -= size * (size - 1) / 2
+		}
+	}
+	if m > maxEdges {
+		m = maxEdges
+	}
+
+	if m <= maxEdges/2 {
+		// Sparse graphs pick random pairs until there are enough edges,
+		// which rarely hits a duplicate.
+		seen := make(map[[2]int]bool, m)
+		for len(seen) < m {
+			i, j := r.Intn(n), r.Intn(n)
+			if layer(i) == layer(j) {
+				continue
+			}
+			if i < j {
+				i, j = j, i
+			}
+			if seen[[2]int{i, j}] {
+				continue
+			}
+			seen[[2]int{i, j}] = true
+			g.Connect(BasicEdge(vertices[i], vertices[j]))
+		}
+		return g
+	}
+
+	// Dense graphs list every possible edge, of which there are at most 2m,
+	// and take the first m from a shuffle.
+	pairs := make([][2]int, 0, maxEdges)
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			if layer(i) != layer(j) {
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+	}
+	r.Shuffle(len(pairs), func(a, b int) { pairs[a], pairs[b] = pairs[b], pairs[a] })
+	for _, p := range pairs[:m] {
+		g.Connect(BasicEdge(vertices[p[0]], vertices[p[1]]))
+	}
+	return g
+}
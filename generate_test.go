@@ -0,0 +1,71 @@
+package dag
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGenerateRandomDAG(t *testing.T) {
+	cases := map[string]struct {
+		N, M  int
+		Opts  *GenOpts
+		Edges int
+	}{
+		"sparse":       {100, 300, nil, 300},
+		"dense":        {30, 400, nil, 400},
+		"complete":     {20, 1000, nil, 190},
+		"layered":      {100, 500, &GenOpts{Layers: 5}, 500},
+		"layered full": {10, 1000, &GenOpts{Layers: 2}, 25},
+		"empty":        {0, 10, nil, 0},
+		"single":       {1, 10, &GenOpts{Layers: 3}, 0},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := GenerateRandomDAG(tc.N, tc.M, 42, tc.Opts)
+			if cycles := g.Cycles(); len(cycles) > 0 {
+				t.Fatalf("expected no cycles, got %v", cycles)
+			}
+
+			s := g.Stats()
+			if s.Vertices != tc.N {
+				t.Fatalf("expected %d vertices, got %d", tc.N, s.Vertices)
+			}
+			if s.Edges != tc.Edges {
+				t.Fatalf("expected %d edges, got %d", tc.Edges, s.Edges)
+			}
+			if tc.Opts != nil && tc.Opts.Layers > 0 && s.Depth >= tc.Opts.Layers {
+				t.Fatalf("depth %d is more than the layers allow", s.Depth)
+			}
+
+			if again := GenerateRandomDAG(tc.N, tc.M, 42, tc.Opts); again.String() != g.String() {
+				t.Fatal("the same seed should generate the same graph")
+			}
+		})
+	}
+}
+
+func TestGenerateRandomDAG_seed(t *testing.T) {
+	a := GenerateRandomDAG(50, 100, 1, nil)
+	b := GenerateRandomDAG(50, 100, 2, nil)
+	if a.String() == b.String() {
+		t.Fatal("different seeds should generate different graphs")
+	}
+}
+
+func TestGenerateRandomDAG_vertex(t *testing.T) {
+	g := GenerateRandomDAG(5, 4, 1, &GenOpts{
+		Vertex: func(i int) Vertex { return fmt.Sprintf("v%d", i) },
+	})
+	for i := 0; i < 5; i++ {
+		if !g.HasVertex(fmt.Sprintf("v%d", i)) {
+			t.Fatalf("missing vertex v%d:\n%s", i, g.String())
+		}
+	}
+}
+
+func BenchmarkGenerateRandomDAG(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		GenerateRandomDAG(10000, 50000, int64(i), &GenOpts{Layers: 10})
+	}
+}
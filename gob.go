@@ -0,0 +1,108 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// RegisterVertex registers the concrete type of v with encoding/gob, which
+// must be done for every type of vertex in a graph, other than the basic
+// types such as string and int, before it can be encoded or decoded. It is
+// usually called from an init function.
+func RegisterVertex(v Vertex) {
+	gob.Register(v)
+}
+
+// gobGraph is the form of a Graph encoded with encoding/gob. Edges refer to
+// their vertices by index.
+type gobGraph struct {
+	Vertices    []Vertex
+	VertexAttrs map[int]map[string]string
+	Edges       []gobEdge
+}
+
+type gobEdge struct {
+	Source, Target int
+	Label          string
+	Weight         float64
+	Weighted       bool
+	Attrs          map[string]string
+}
+
+// GobEncode implements gob.GobEncoder, so that a graph can be cached to disk
+// and reloaded quickly. Unlike MarshalJSON, the vertices themselves are
+// encoded, so their types must be registered with RegisterVertex. The
+// weight, attributes and label of each edge are kept, but edges are decoded
+// as the basic edge types of this package rather than their original types.
+// Attributes set with SetVertexAttr are kept.
+func (g *Graph) GobEncode() ([]byte, error) {
+	vs := g.SortedVertices()
+	index := make(map[interface{}]int, len(vs))
+	gg := gobGraph{
+		Vertices:    vs,
+		VertexAttrs: make(map[int]map[string]string),
+	}
+	for i, v := range vs {
+		index[hashcode(v)] = i
+		if attrs := g.vertexAttrs[hashcode(v)]; len(attrs) > 0 {
+			gg.VertexAttrs[i] = attrs
+		}
+	}
+
+	for _, e := range g.SortedEdges() {
+		ge := gobEdge{
+			Source: index[hashcode(e.Source())],
+			Target: index[hashcode(e.Target())],
+			Label:  edgeLabel(e),
+		}
+		if we, ok := e.(WeightedEdge); ok {
+			ge.Weight, ge.Weighted = we.Weight(), true
+		}
+		if ae, ok := e.(AttrEdge); ok {
+			ge.Attrs = ae.Attrs()
+		}
+		gg.Edges = append(gg.Edges, ge)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&gg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder, replacing the contents of the graph
+// with those encoded by GobEncode.
+func (g *Graph) GobDecode(data []byte) error {
+	var gg gobGraph
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&gg); err != nil {
+		return err
+	}
+
+	result := &Graph{}
+	result.init()
+	for _, v := range gg.Vertices {
+		result.Add(v)
+	}
+	for i, attrs := range gg.VertexAttrs {
+		if i < 0 || i >= len(gg.Vertices) {
+			return fmt.Errorf("attributes for unknown vertex %d", i)
+		}
+		for k, v := range attrs {
+			result.SetVertexAttr(gg.Vertices[i], k, v)
+		}
+	}
+
+	for _, ge := range gg.Edges {
+		if ge.Source < 0 || ge.Source >= len(gg.Vertices) || ge.Target < 0 || ge.Target >= len(gg.Vertices) {
+			return fmt.Errorf("edge references unknown vertex %d or %d", ge.Source, ge.Target)
+		}
+		source, target := gg.Vertices[ge.Source], gg.Vertices[ge.Target]
+
+		result.Connect(rebuildEdge(source, target, ge.Label, ge.Weight, ge.Weighted, ge.Attrs))
+	}
+
+	*g = *result
+	return nil
+}
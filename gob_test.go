@@ -0,0 +1,71 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+	"testing"
+)
+
+type testGobVertex struct {
+	Name_ string
+	Size  int
+}
+
+func (v *testGobVertex) Name() string { return v.Name_ }
+
+func init() {
+	RegisterVertex(&testGobVertex{})
+}
+
+func TestGraphGob(t *testing.T) {
+	var g AcyclicGraph
+	a := g.Add(&testGobVertex{Name_: "a", Size: 3})
+	g.Add("b")
+	g.Add(3)
+	g.Connect(BasicWeightedEdge(a, "b", 2.5))
+	g.Connect(BasicAttrEdge("b", 3, map[string]string{"color": "red"}))
+	g.Connect(BasicLabeledEdge(a, 3, "build"))
+	g.Connect(BasicLabeledEdge(a, 3, "runtime"))
+	g.SetVertexAttr("b", "owner", "team-a")
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&g); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded AcyclicGraph
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	actual := strings.TrimSpace(decoded.String())
+	expected := strings.TrimSpace(g.String())
+	if actual != expected {
+		t.Fatalf("got:\n%s\nwant:\n%s", actual, expected)
+	}
+
+	var da *testGobVertex
+	for _, v := range decoded.Vertices() {
+		if v, ok := v.(*testGobVertex); ok {
+			da = v
+		}
+	}
+	if da == nil || da.Size != 3 {
+		t.Fatalf("bad vertex: %#v", da)
+	}
+
+	edges := decoded.EdgesBetween(da, "b")
+	if len(edges) != 1 || edgeWeight(edges[0]) != 2.5 {
+		t.Fatalf("bad weighted edge: %#v", edges)
+	}
+	if n := len(decoded.EdgesBetween(da, 3)); n != 2 {
+		t.Fatalf("expected 2 labeled edges, got %d", n)
+	}
+	if ae, ok := decoded.EdgesBetween("b", 3)[0].(AttrEdge); !ok || ae.Attrs()["color"] != "red" {
+		t.Fatal("edge attributes were lost")
+	}
+	if decoded.VertexAttrs("b")["owner"] != "team-a" {
+		t.Fatal("vertex attributes were lost")
+	}
+}
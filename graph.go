@@ -3,7 +3,9 @@ package dag
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"sort"
+	"sync"
 )
 
 // Graph is used to represent a dependency graph.
@@ -12,6 +14,58 @@ type Graph struct {
 	edges     Set
 	downEdges map[interface{}]Set
 	upEdges   map[interface{}]Set
+
+	// reach is a lazily built reachability index, and adj a lazily built
+	// copy of the edges numbered for fast traversal. Both are discarded
+	// whenever the graph is changed. walked counts the edges followed by
+	// walks since then, which decides when adj is worth building.
+	reach  *reachIndex
+	adj    *adjacency
+	walked int
+
+	// relatives caches the results of Descendants and Ancestors when enabled
+	// with CacheRelatives, and is emptied whenever the graph is changed.
+	relatives *relativesCache
+
+	// shared is set when the sets and maps above may be referenced by a
+	// snapshot, and so must be copied before they are modified.
+	shared bool
+
+	// listeners are notified of changes to the graph.
+	listeners graphListeners
+
+	// vertexAttrs are the attributes set with SetVertexAttr, keyed by the
+	// hashcode of their vertex.
+	vertexAttrs map[interface{}]map[string]string
+
+	// pairs holds the edges which can't be found by the hashcode of a
+	// BasicEdge between their vertices, such as those with a label, keyed by
+	// the hashcodes of their source and target so that every edge between
+	// two vertices can be found. See pairIndexed.
+	pairs map[[2]interface{}]Set
+
+	// names indexes the vertices by VertexName. It is built by the first
+	// call to VertexByName, and kept up to date from then on.
+	names map[string][]Vertex
+
+	// indexLock is held while reach, adj or names is built on demand, and
+	// while walked is updated, so that concurrent readers of an unchanging
+	// graph can safely trigger the build. It is allocated by init.
+	indexLock *sync.Mutex
+
+	// strict is set by GraphOpts.Strict, and rejected holds the errors for
+	// the edges it has caused Connect to reject.
+	strict   bool
+	rejected Diagnostics
+}
+
+// graphListeners holds the functions registered to be notified of changes
+// to a Graph.
+type graphListeners struct {
+	add        []func(Vertex)
+	remove     []func(Vertex)
+	connect    []func(Edge)
+	removeEdge []func(Edge)
 }
 
 // Subgrapher allows a Vertex to be a Graph itself, by returning a Grapher.
@@ -29,6 +83,14 @@ type Grapher interface {
 // Vertex of the graph.
 type Vertex interface{}
 
+// VertexCloner is an optional interface that can be implemented by a Vertex
+// to be copied when the graph containing it is cloned, rather than being
+// shared by both graphs. This is mainly useful for a Subgrapher, to give the
+// clone its own copy of the subgraph.
+type VertexCloner interface {
+	CloneVertex() Vertex
+}
+
 // NamedVertex is an optional interface that can be implemented by Vertex
 // to give it a human-friendly name that is used for outputting the graph.
 type NamedVertex interface {
@@ -36,6 +98,84 @@ type NamedVertex interface {
 	Name() string
 }
 
+// GraphOpts are the options for NewGraphWithOpts.
+type GraphOpts struct {
+	// VertexHint and EdgeHint are the number of vertices and edges to make
+	// room for, so that building a large graph of a known size doesn't
+	// repeatedly grow its internal maps.
+	VertexHint int
+	EdgeHint   int
+
+	// Strict makes Connect reject edges from a vertex to itself, and edges
+	// which are already in the graph, as these are almost always mistakes
+	// when building a DAG. A rejected edge isn't added, and the reason is
+	// returned by ConnectErr. ConnectChecked returns it directly.
+	Strict bool
+}
+
+// NewGraph returns an empty graph with room for about vertexHint vertices
+// and edgeHint edges. A zero Graph is equally usable, and either hint may be
+// zero.
+func NewGraph(vertexHint, edgeHint int) *Graph {
+	return NewGraphWithOpts(&GraphOpts{VertexHint: vertexHint, EdgeHint: edgeHint})
+}
+
+// NewGraphWithOpts returns an empty graph, configured by opts.
+func NewGraphWithOpts(opts *GraphOpts) *Graph {
+	if opts == nil {
+		opts = &GraphOpts{}
+	}
+
+	g := &Graph{strict: opts.Strict}
+	g.allocate(opts.VertexHint, opts.EdgeHint)
+	return g
+}
+
+// NewAcyclicGraph is like NewGraph, returning an AcyclicGraph.
+func NewAcyclicGraph(vertexHint, edgeHint int) *AcyclicGraph {
+	return &AcyclicGraph{Graph: *NewGraph(vertexHint, edgeHint)}
+}
+
+// NewAcyclicGraphWithOpts is like NewGraphWithOpts, returning an
+// AcyclicGraph.
+func NewAcyclicGraphWithOpts(opts *GraphOpts) *AcyclicGraph {
+	return &AcyclicGraph{Graph: *NewGraphWithOpts(opts)}
+}
+
+// Grow makes room for about vertices more vertices and edges more edges, as
+// with the hints to NewGraph. It copies the current contents of the graph,
+// so it is best called once before adding many vertices and edges, rather
+// than between each.
+//
+// Complexity: O(V+E)
+func (g *Graph) Grow(vertices, edges int) {
+	g.unshare()
+
+	old := *g
+	g.allocate(len(old.vertices)+vertices, len(old.edges)+edges)
+	for k, v := range old.vertices {
+		g.vertices[k] = v
+	}
+	for k, e := range old.edges {
+		g.edges[k] = e
+	}
+	for k, s := range old.downEdges {
+		g.downEdges[k] = s
+	}
+	for k, s := range old.upEdges {
+		g.upEdges[k] = s
+	}
+}
+
+// allocate replaces the sets and maps of g with empty ones of the given
+// sizes.
+func (g *Graph) allocate(vertices, edges int) {
+	g.vertices = make(Set, vertices)
+	g.edges = make(Set, edges)
+	g.downEdges = make(map[interface{}]Set, vertices)
+	g.upEdges = make(map[interface{}]Set, vertices)
+}
+
 func (g *Graph) DirectedGraph() Grapher {
 	return g
 }
@@ -60,6 +200,23 @@ func (g *Graph) Edges() []Edge {
 	return result
 }
 
+// SortedVertices returns the list of all the vertices in the graph, sorted by
+// VertexName so that the order is the same each time.
+func (g *Graph) SortedVertices() []Vertex {
+	result := g.Vertices()
+	sort.Sort(byVertexName(result))
+	return result
+}
+
+// SortedEdges returns the list of all the edges in the graph, sorted by the
+// VertexName of their source and then their target, so that the order is the
+// same each time. Edges between the same vertices are sorted by label.
+func (g *Graph) SortedEdges() []Edge {
+	result := g.Edges()
+	sort.Sort(byEdgeName(result))
+	return result
+}
+
 // EdgesFrom returns the list of edges from the given source.
 func (g *Graph) EdgesFrom(v Vertex) []Edge {
 	var result []Edge
@@ -91,78 +248,465 @@ func (g *Graph) HasVertex(v Vertex) bool {
 	return g.vertices.Include(v)
 }
 
-// HasEdge checks if the given Edge is present in the graph.
+// HasEdge checks if the given Edge is present in the graph. Edges with
+// different labels are different edges, so a LabeledEdge is only found if an
+// edge with the same label is present.
 func (g *Graph) HasEdge(e Edge) bool {
 	return g.edges.Include(e)
 }
 
+// VertexByName returns the vertex with the given VertexName, for finding
+// vertices referred to by name, such as in a file being loaded. If several
+// vertices have the name, any one of them may be returned. The second result
+// is false if there is none.
+//
+// The first call builds an index of the names of every vertex, which is
+// then kept up to date as vertices are added and removed. A vertex whose name
+// changes after it is added must be removed and added again to be found by
+// its new name. VertexByName may be called from several goroutines at once,
+// as long as the graph isn't being changed.
+//
+// Complexity: O(1), or O(V) for the first call
+func (g *Graph) VertexByName(name string) (Vertex, bool) {
+	g.init()
+	g.indexLock.Lock()
+	if g.names == nil {
+		names := make(map[string][]Vertex, len(g.vertices))
+		for _, v := range g.Vertices() {
+			n := VertexName(v)
+			names[n] = append(names[n], v)
+		}
+		g.names = names
+	}
+	vs := g.names[name]
+	g.indexLock.Unlock()
+
+	if len(vs) == 0 {
+		return nil, false
+	}
+	return vs[0], true
+}
+
+// unindexName removes v from the index of vertex names.
+func (g *Graph) unindexName(v Vertex) {
+	name := VertexName(v)
+	vs := g.names[name]
+	for i, n := range vs {
+		if hashcode(n) == hashcode(v) {
+			vs = append(vs[:i:i], vs[i+1:]...)
+			break
+		}
+	}
+
+	if len(vs) == 0 {
+		delete(g.names, name)
+	} else {
+		g.names[name] = vs
+	}
+}
+
+// EdgeBetween returns the edge from source to target, whatever Edge
+// implementation it was connected with. Where there are several edges with
+// different labels, the unlabeled edge is returned if there is one, and
+// otherwise the edge with the first label. The second result is false if
+// there is no edge.
+func (g *Graph) EdgeBetween(source, target Vertex) (Edge, bool) {
+	if e, ok := g.edges[hashcode(BasicEdge(source, target))]; ok {
+		return e.(Edge), true
+	}
+
+	var first Edge
+	for _, raw := range g.pairs[edgePair(source, target)] {
+		if e := raw.(Edge); first == nil || edgeLabel(e) < edgeLabel(first) {
+			first = e
+		}
+	}
+	return first, first != nil
+}
+
+// HasEdgeBetween reports whether there is any edge from source to target.
+func (g *Graph) HasEdgeBetween(source, target Vertex) bool {
+	return g.downEdgesNoCopy(source).Include(target)
+}
+
+// EdgesBetween returns the list of edges from source to target, of which
+// there may be more than one with different labels.
+func (g *Graph) EdgesBetween(source, target Vertex) []Edge {
+	var result []Edge
+	if e, ok := g.edges[hashcode(BasicEdge(source, target))]; ok {
+		result = append(result, e.(Edge))
+	}
+	for _, e := range g.pairs[edgePair(source, target)] {
+		result = append(result, e.(Edge))
+	}
+
+	return result
+}
+
+// removeEdgesBetween removes every edge from source to target, whatever
+// their labels.
+func (g *Graph) removeEdgesBetween(source, target Vertex) {
+	for _, e := range g.EdgesBetween(source, target) {
+		g.RemoveEdge(e)
+	}
+}
+
+// edgeLike returns the edge from source to target with the given label,
+// whatever Edge implementation it was connected with. The second result is
+// false if there is none.
+func (g *Graph) edgeLike(source, target Vertex, label string) (Edge, bool) {
+	if !g.downEdgesNoCopy(source).Include(target) {
+		return nil, false
+	}
+	for _, e := range g.EdgesBetween(source, target) {
+		if edgeLabel(e) == label {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// edgePair returns the key of the edges from source to target in
+// Graph.pairs.
+func edgePair(source, target Vertex) [2]interface{} {
+	return [2]interface{}{hashcode(source), hashcode(target)}
+}
+
+// pairIndexed reports whether e is kept in Graph.pairs, because it can't be
+// found by the hashcode of a BasicEdge between its vertices. This is the case
+// for edges with a label, and for Edge implementations hashed differently.
+func pairIndexed(e Edge) bool {
+	return hashcode(e) != hashcode(BasicEdge(e.Source(), e.Target()))
+}
+
 // Add adds a vertex to the graph. This is safe to call multiple time with
 // the same Vertex.
 func (g *Graph) Add(v Vertex) Vertex {
-	g.init()
+	if debugInvariants {
+		defer g.debugCheck(v)
+	}
+	g.unshare()
+	added := !g.vertices.Include(v)
 	g.vertices.Add(v)
+	if !added {
+		return v
+	}
+	g.invalidate()
+
+	if g.names != nil {
+		name := VertexName(v)
+		g.names[name] = append(g.names[name], v)
+	}
+
+	for _, f := range g.listeners.add {
+		f(v)
+	}
 	return v
 }
 
 // Remove removes a vertex from the graph. This will also remove any
-// edges with this vertex as a source or target.
+// edges with this vertex as a source or target. See RemoveWithEdges.
 func (g *Graph) Remove(v Vertex) Vertex {
+	g.RemoveWithEdges(v)
+	return nil
+}
+
+// RemoveWithEdges removes a vertex from the graph along with every edge with
+// it as a source or target, and returns those edges sorted by the names of
+// their vertices. Nothing is left referring to the vertex afterwards, so it
+// can't be reached by a later walk or appear in a marshaled graph. Edges are
+// removed even if the vertex itself was never added.
+func (g *Graph) RemoveWithEdges(v Vertex) []Edge {
+	if debugInvariants {
+		defer g.debugCheck(v)
+	}
+	g.unshare()
+
 	// Delete the vertex itself
+	removed := g.vertices.Include(v)
 	g.vertices.Delete(v)
+	delete(g.vertexAttrs, hashcode(v))
+	if removed {
+		if g.names != nil {
+			g.unindexName(v)
+		}
+		g.invalidate()
+	}
 
 	// Delete the edges to non-existent things
+	var edges []Edge
 	for _, target := range g.downEdgesNoCopy(v) {
-		g.RemoveEdge(BasicEdge(v, target))
+		for _, e := range g.EdgesBetween(v, target) {
+			edges = append(edges, e)
+			g.RemoveEdge(e)
+		}
 	}
 	for _, source := range g.upEdgesNoCopy(v) {
-		g.RemoveEdge(BasicEdge(source, v))
+		for _, e := range g.EdgesBetween(source, v) {
+			edges = append(edges, e)
+			g.RemoveEdge(e)
+		}
 	}
+	delete(g.downEdges, hashcode(v))
+	delete(g.upEdges, hashcode(v))
 
-	return nil
+	if removed {
+		for _, f := range g.listeners.remove {
+			f(v)
+		}
+	}
+
+	sort.Sort(byEdgeName(edges))
+	return edges
 }
 
 // Replace replaces the original Vertex with replacement. If the original
 // does not exist within the graph, then false is returned. Otherwise, true
 // is returned.
 func (g *Graph) Replace(original, replacement Vertex) bool {
-	// If we don't have the original, we can't do anything
-	if !g.vertices.Include(original) {
-		return false
+	return g.ReplaceWithOpts(original, replacement, nil)
+}
+
+// ReplaceOpts are the options for ReplaceWithOpts and ReplaceFunc.
+type ReplaceOpts struct {
+	// EdgeAttrs keeps the weight, label and attributes of each edge moved to
+	// a replacement. Otherwise the moved edges are BasicEdges, as with
+	// Replace.
+	EdgeAttrs bool
+
+	// Subgraphs also replaces the vertices within the subgraphs of any
+	// Subgrapher vertices, recursively. The subgraphs are modified in place.
+	Subgraphs bool
+}
+
+// ReplaceWithOpts is like Replace, with options for also moving edge data
+// and replacing the vertex within subgraphs. Vertex attributes are always
+// moved to the replacement. With ReplaceOpts.Subgraphs, true is returned if
+// the original was found in the graph or any of its subgraphs.
+func (g *Graph) ReplaceWithOpts(original, replacement Vertex, opts *ReplaceOpts) bool {
+	if opts == nil {
+		opts = &ReplaceOpts{}
+	}
+
+	found := g.vertices.Include(original)
+	if opts.Subgraphs {
+		for _, v := range g.Vertices() {
+			if sg, ok := marshalSubgrapher(v); ok && sg.ReplaceWithOpts(original, replacement, opts) {
+				found = true
+			}
+		}
 	}
 
 	// If they're the same, then don't do anything
-	if original == replacement {
-		return true
+	if !g.vertices.Include(original) || original == replacement {
+		return found
+	}
+
+	g.replace(map[interface{}]Vertex{hashcode(original): replacement}, opts)
+	return true
+}
+
+// ReplaceFunc replaces every vertex in the graph with the result of calling
+// f on it, in a single pass. Vertices for which f returns the vertex itself
+// are left alone. As f is called for every vertex before any are replaced,
+// a replacement may itself be the original of another replacement. The
+// number of vertices replaced is returned.
+//
+// Complexity: O(V+E)
+func (g *Graph) ReplaceFunc(f func(Vertex) Vertex, opts *ReplaceOpts) int {
+	if opts == nil {
+		opts = &ReplaceOpts{}
+	}
+
+	replacements := make(map[interface{}]Vertex)
+	for _, v := range g.Vertices() {
+		if opts.Subgraphs {
+			if sg, ok := marshalSubgrapher(v); ok {
+				sg.ReplaceFunc(f, opts)
+			}
+		}
+		if r := f(v); r != v {
+			replacements[hashcode(v)] = r
+		}
+	}
+
+	g.replace(replacements, opts)
+	return len(replacements)
+}
+
+// replace swaps each vertex whose hashcode is in replacements for its
+// replacement, moving its attributes and edges.
+func (g *Graph) replace(replacements map[interface{}]Vertex, opts *ReplaceOpts) {
+	if len(replacements) == 0 {
+		return
+	}
+
+	mapped := func(v Vertex) Vertex {
+		if r, ok := replacements[hashcode(v)]; ok {
+			return r
+		}
+		return v
+	}
+
+	// Gather everything that moves before removing the originals, as a
+	// replacement may be the original of another.
+	var originals []Vertex
+	var edges []Edge
+	attrs := make(map[interface{}]map[string]string)
+	for _, v := range g.vertices {
+		if _, ok := replacements[hashcode(v)]; !ok {
+			continue
+		}
+		originals = append(originals, v)
+		attrs[hashcode(v)] = g.VertexAttrs(v)
+		for _, target := range g.downEdgesNoCopy(v) {
+			edges = append(edges, g.EdgesBetween(v, target)...)
+		}
+		for _, source := range g.upEdgesNoCopy(v) {
+			// edges between two originals were already gathered
+			if _, ok := replacements[hashcode(source)]; !ok {
+				edges = append(edges, g.EdgesBetween(source, v)...)
+			}
+		}
+	}
+
+	for _, v := range originals {
+		g.Remove(v)
+	}
+
+	for _, v := range originals {
+		r := mapped(v)
+		g.Add(r)
+		for k, value := range attrs[hashcode(v)] {
+			g.SetVertexAttr(r, k, value)
+		}
 	}
 
-	// Add our new vertex, then copy all the edges
-	g.Add(replacement)
-	for _, target := range g.downEdgesNoCopy(original) {
-		g.Connect(BasicEdge(replacement, target))
+	for _, e := range edges {
+		source, target := mapped(e.Source()), mapped(e.Target())
+		if opts.EdgeAttrs {
+			g.Connect(copyEdge(e, source, target))
+		} else {
+			g.Connect(BasicEdge(source, target))
+		}
 	}
-	for _, source := range g.upEdgesNoCopy(original) {
-		g.Connect(BasicEdge(source, replacement))
+}
+
+// Contract replaces the vertices a and b with the single vertex merged,
+// which may be either of them. The edges to and from a and b are moved to
+// merged, keeping their weights and attributes, and any edges between a and
+// b are dropped. Where a and b both have an edge to or from the same vertex,
+// only one is kept. The attributes of a and b are combined onto merged, with
+// those of a kept where both have the same attribute. If either a or b does
+// not exist within the graph, then false is returned and the graph is
+// unchanged. Otherwise, true is returned.
+func (g *Graph) Contract(a, b, merged Vertex) bool {
+	if !g.HasVertex(a) || !g.HasVertex(b) {
+		return false
+	}
+
+	inPair := func(v Vertex) bool {
+		return hashcode(v) == hashcode(a) || hashcode(v) == hashcode(b)
+	}
+
+	out := append(g.EdgesFrom(a), g.EdgesFrom(b)...)
+	in := append(g.EdgesTo(a), g.EdgesTo(b)...)
+	attrs := []map[string]string{g.VertexAttrs(b), g.VertexAttrs(a)}
+
+	g.Remove(a)
+	g.Remove(b)
+	g.Add(merged)
+	for _, m := range attrs {
+		for k, value := range m {
+			g.SetVertexAttr(merged, k, value)
+		}
 	}
 
-	// Remove our old vertex, which will also remove all the edges
-	g.Remove(original)
+	for _, e := range out {
+		if !inPair(e.Target()) {
+			g.Connect(copyEdge(e, merged, e.Target()))
+		}
+	}
+	for _, e := range in {
+		if !inPair(e.Source()) {
+			g.Connect(copyEdge(e, e.Source(), merged))
+		}
+	}
+
+	return true
+}
+
+// Splice removes v from the graph, connecting each vertex which depended on
+// v directly to each of the dependencies of v, so that reachability between
+// the remaining vertices is unchanged. The new edges keep the weight and
+// attributes of the edges into v, while the attributes of v itself are
+// removed with it. If v does not exist within the graph, then false is
+// returned. Otherwise, true is returned.
+func (g *Graph) Splice(v Vertex) bool {
+	if !g.HasVertex(v) {
+		return false
+	}
+
+	in := g.EdgesTo(v)
+	out := g.EdgesFrom(v)
+	g.Remove(v)
+
+	for _, ie := range in {
+		if hashcode(ie.Source()) == hashcode(v) {
+			continue
+		}
+		for _, oe := range out {
+			if hashcode(oe.Target()) != hashcode(v) {
+				g.Connect(copyEdge(ie, ie.Source(), oe.Target()))
+			}
+		}
+	}
 
 	return true
 }
 
 // RemoveEdge removes an edge from the graph.
 func (g *Graph) RemoveEdge(edge Edge) {
-	g.init()
+	if debugInvariants {
+		defer g.debugCheck(edge.Source(), edge.Target())
+	}
+	g.unshare()
+
+	// Notify listeners with the edge that was connected, which may be a
+	// different Edge implementation to the one given.
+	removed, ok := g.edges[hashcode(edge)].(Edge)
+	if !ok {
+		removed, ok = g.edgeLike(edge.Source(), edge.Target(), edgeLabel(edge))
+	}
+	if !ok {
+		return
+	}
 
 	// Delete the edge from the set
-	g.edges.Delete(edge)
+	g.edges.Delete(removed)
+	g.invalidate()
+
+	pair := edgePair(edge.Source(), edge.Target())
+	if s, ok := g.pairs[pair]; ok {
+		s.Delete(removed)
+		if len(s) == 0 {
+			delete(g.pairs, pair)
+		}
+	}
 
-	// Delete the up/down edges
-	if s, ok := g.downEdges[hashcode(edge.Source())]; ok {
-		s.Delete(edge.Target())
+	// Delete the up/down edges, unless another edge with a different label
+	// still connects the same vertices.
+	if len(g.EdgesBetween(edge.Source(), edge.Target())) == 0 {
+		if s, ok := g.downEdges[hashcode(edge.Source())]; ok {
+			s.Delete(edge.Target())
+		}
+		if s, ok := g.upEdges[hashcode(edge.Target())]; ok {
+			s.Delete(edge.Source())
+		}
 	}
-	if s, ok := g.upEdges[hashcode(edge.Target())]; ok {
-		s.Delete(edge.Source())
+
+	for _, f := range g.listeners.removeEdge {
+		f(removed)
 	}
 }
 
@@ -196,22 +740,47 @@ func (g *Graph) upEdgesNoCopy(v Vertex) Set {
 // Connect adds an edge with the given source and target. This is safe to
 // call multiple times with the same value. Note that the same value is
 // verified through pointer equality of the vertices, not through the
-// value of the edge itself.
+// value of the edge itself. A LabeledEdge is added alongside any edges
+// between the same vertices with a different label.
+//
+// Complexity: O(1), or O(k) for k parallel edges between the vertices
 func (g *Graph) Connect(edge Edge) {
-	g.init()
+	if debugInvariants {
+		defer g.debugCheck(edge.Source(), edge.Target())
+	}
+	g.unshare()
 
 	source := edge.Source()
 	target := edge.Target()
 	sourceCode := hashcode(source)
 	targetCode := hashcode(target)
 
+	if g.strict {
+		if err := g.checkStrict(edge); err != nil {
+			g.rejected = g.rejected.Append(err)
+			return
+		}
+	}
+
 	// Do we have this already? If so, don't add it again.
-	if s, ok := g.downEdges[sourceCode]; ok && s.Include(target) {
+	if _, ok := g.edgeLike(source, target, edgeLabel(edge)); ok || g.edges.Include(edge) {
 		return
 	}
 
 	// Add the edge to the set
 	g.edges.Add(edge)
+	g.invalidate()
+
+	if pairIndexed(edge) {
+		if g.pairs == nil {
+			g.pairs = make(map[[2]interface{}]Set)
+		}
+		pair := edgePair(source, target)
+		if g.pairs[pair] == nil {
+			g.pairs[pair] = make(Set)
+		}
+		g.pairs[pair].Add(edge)
+	}
 
 	// Add the down edge
 	s, ok := g.downEdges[sourceCode]
@@ -228,6 +797,133 @@ func (g *Graph) Connect(edge Edge) {
 		g.upEdges[targetCode] = s
 	}
 	s.Add(source)
+
+	for _, f := range g.listeners.connect {
+		f(edge)
+	}
+}
+
+// ConnectErr returns an error listing every edge rejected by Connect since
+// the graph was created, for a graph created with GraphOpts.Strict. It is
+// nil if no edges have been rejected.
+func (g *Graph) ConnectErr() error {
+	return g.rejected.Err()
+}
+
+// checkStrict returns the reason for a graph with GraphOpts.Strict to reject
+// edge, or nil if it is allowed.
+func (g *Graph) checkStrict(edge Edge) error {
+	if hashcode(edge.Source()) == hashcode(edge.Target()) {
+		return fmt.Errorf("Self reference: %s", VertexName(edge.Source()))
+	}
+	if _, ok := g.edgeLike(edge.Source(), edge.Target(), edgeLabel(edge)); ok || g.edges.Include(edge) {
+		return fmt.Errorf("Duplicate edge: %s -> %s",
+			VertexName(edge.Source()), VertexName(edge.Target()))
+	}
+	return nil
+}
+
+// OnAdd registers f to be called after a vertex is added to the graph. It is
+// not called when adding a vertex that is already present.
+func (g *Graph) OnAdd(f func(Vertex)) {
+	g.listeners.add = append(g.listeners.add, f)
+}
+
+// OnRemove registers f to be called after a vertex is removed from the
+// graph, once the edges to and from it have been removed.
+func (g *Graph) OnRemove(f func(Vertex)) {
+	g.listeners.remove = append(g.listeners.remove, f)
+}
+
+// OnConnect registers f to be called after an edge is added to the graph. It
+// is not called when connecting an edge that is already present.
+func (g *Graph) OnConnect(f func(Edge)) {
+	g.listeners.connect = append(g.listeners.connect, f)
+}
+
+// OnRemoveEdge registers f to be called after an edge is removed from the
+// graph, including when the edge is removed along with one of its vertices.
+func (g *Graph) OnRemoveEdge(f func(Edge)) {
+	g.listeners.removeEdge = append(g.listeners.removeEdge, f)
+}
+
+// SetVertexAttr sets an attribute of the vertex v, which is stored in the
+// graph rather than in the vertex itself. This allows vertices of types which
+// can't be changed to be annotated. The attributes of a vertex are included
+// in the marshaled output of the graph, and as attributes of the node in the
+// dot output. Nothing is set if v is not in the graph.
+//
+// The attributes are removed along with the vertex, moved to its replacement
+// by Replace, and kept by Clone, Reverse and Filter.
+func (g *Graph) SetVertexAttr(v Vertex, key, value string) {
+	if !g.HasVertex(v) {
+		return
+	}
+
+	g.unshare()
+	if g.vertexAttrs == nil {
+		g.vertexAttrs = make(map[interface{}]map[string]string)
+	}
+	attrs := g.vertexAttrs[hashcode(v)]
+	if attrs == nil {
+		attrs = make(map[string]string)
+		g.vertexAttrs[hashcode(v)] = attrs
+	}
+	attrs[key] = value
+}
+
+// VertexAttrs returns a copy of the attributes set on v with SetVertexAttr,
+// or nil if there are none.
+func (g *Graph) VertexAttrs(v Vertex) map[string]string {
+	return copyAttrs(g.vertexAttrs[hashcode(v)])
+}
+
+// GroupAttr is the vertex attribute holding the group set by SetVertexGroup.
+const GroupAttr = "dag.group"
+
+// SetVertexGroup puts v in the named group of logically related vertices,
+// such as all the vertices of one service, replacing any group it was in
+// before. An empty group takes v out of its group. Nothing is set if v is not
+// in the graph.
+//
+// The group is stored as the GroupAttr attribute, so it is marshaled and
+// kept along with the other attributes of v. DotOpts.RankGroups draws the
+// vertices of each group at the same rank.
+func (g *Graph) SetVertexGroup(v Vertex, group string) {
+	if group != "" {
+		g.SetVertexAttr(v, GroupAttr, group)
+		return
+	}
+	if _, ok := g.vertexAttrs[hashcode(v)][GroupAttr]; ok {
+		g.unshare()
+		delete(g.vertexAttrs[hashcode(v)], GroupAttr)
+	}
+}
+
+// VertexGroup returns the group of v set by SetVertexGroup, or an empty
+// string if it is not in a group.
+func (g *Graph) VertexGroup(v Vertex) string {
+	return g.vertexAttrs[hashcode(v)][GroupAttr]
+}
+
+// copyVertexAttrs sets the attributes of from in g as the attributes of to
+// in dst.
+func (g *Graph) copyVertexAttrs(dst *Graph, from, to Vertex) {
+	for k, v := range g.vertexAttrs[hashcode(from)] {
+		dst.SetVertexAttr(to, k, v)
+	}
+}
+
+// copyAttrs returns a copy of attrs, or nil if it is empty.
+func copyAttrs(attrs map[string]string) map[string]string {
+	if len(attrs) == 0 {
+		return nil
+	}
+	c := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		c[k] = v
+	}
+	return c
 }
 
 // String outputs some human-friendly output for the graph structure.
@@ -324,16 +1020,168 @@ func (g *Graph) init() {
 	if g.upEdges == nil {
 		g.upEdges = make(map[interface{}]Set)
 	}
+	if g.indexLock == nil {
+		g.indexLock = new(sync.Mutex)
+	}
+}
+
+// unshare initializes the graph, and takes a private copy of its contents if
+// they may be referenced by a snapshot. It must be called before any change
+// is made to the graph.
+func (g *Graph) unshare() {
+	g.init()
+	if !g.shared {
+		return
+	}
+
+	g.vertices = g.vertices.Copy()
+	g.edges = g.edges.Copy()
+
+	downEdges := make(map[interface{}]Set, len(g.downEdges))
+	for k, s := range g.downEdges {
+		downEdges[k] = s.Copy()
+	}
+	g.downEdges = downEdges
+
+	upEdges := make(map[interface{}]Set, len(g.upEdges))
+	for k, s := range g.upEdges {
+		upEdges[k] = s.Copy()
+	}
+	g.upEdges = upEdges
+
+	if g.pairs != nil {
+		pairs := make(map[[2]interface{}]Set, len(g.pairs))
+		for k, s := range g.pairs {
+			pairs[k] = s.Copy()
+		}
+		g.pairs = pairs
+	}
+
+	if g.vertexAttrs != nil {
+		vertexAttrs := make(map[interface{}]map[string]string, len(g.vertexAttrs))
+		for k, attrs := range g.vertexAttrs {
+			vertexAttrs[k] = copyAttrs(attrs)
+		}
+		g.vertexAttrs = vertexAttrs
+	}
+
+	g.shared = false
+}
+
+// Snapshot returns a frozen view of the graph in constant time. The
+// snapshot shares its contents with g until either of them is changed, at
+// which point the graph being changed takes a private copy. This allows one
+// goroutine to continue changing g while others read from the snapshot.
+//
+// Snapshot itself must not be called concurrently with changes to g.
+func (g *Graph) Snapshot() *Graph {
+	g.init()
+	g.shared = true
+	return &Graph{
+		vertices:    g.vertices,
+		edges:       g.edges,
+		downEdges:   g.downEdges,
+		upEdges:     g.upEdges,
+		reach:       g.reach,
+		adj:         g.adj,
+		shared:      true,
+		vertexAttrs: g.vertexAttrs,
+		pairs:       g.pairs,
+		strict:      g.strict,
+		indexLock:   new(sync.Mutex),
+	}
+}
+
+// Snapshot returns a frozen view of the graph in constant time. See
+// Graph.Snapshot.
+func (g *AcyclicGraph) Snapshot() *AcyclicGraph {
+	return &AcyclicGraph{Graph: *g.Graph.Snapshot()}
+}
+
+// Clone returns a copy of the graph which can be changed independently of g.
+// Vertices are shared between the two graphs, except for those implementing
+// VertexCloner, which are replaced by their copy. Edges to or from a copied
+// vertex are recreated, keeping their weight and attributes.
+func (g *Graph) Clone() *Graph {
+	c := &Graph{strict: g.strict}
+	c.init()
+
+	clones := make(map[interface{}]Vertex)
+	clone := func(v Vertex) Vertex {
+		if cv, ok := clones[hashcode(v)]; ok {
+			return cv
+		}
+		return v
+	}
+
+	for _, v := range g.Vertices() {
+		orig := v
+		if vc, ok := v.(VertexCloner); ok {
+			cv := vc.CloneVertex()
+			clones[hashcode(v)] = cv
+			v = cv
+		}
+		c.Add(v)
+		g.copyVertexAttrs(c, orig, v)
+	}
+
+	for _, e := range g.Edges() {
+		c.Connect(copyEdge(e, clone(e.Source()), clone(e.Target())))
+	}
+
+	return c
+}
+
+// Clone returns a copy of the graph which can be changed independently of g.
+// See Graph.Clone.
+func (g *AcyclicGraph) Clone() *AcyclicGraph {
+	return &AcyclicGraph{Graph: *g.Graph.Clone()}
+}
+
+// Reverse returns a new graph with the same vertices as g and every edge
+// flipped, so that each vertex depends on the vertices which depended on it
+// in g. Flipped edges keep their weight and attributes. Walking the reversed
+// graph visits the vertices in the opposite order, as needed for teardown.
+func (g *Graph) Reverse() *Graph {
+	r := &Graph{}
+	r.init()
+
+	for _, v := range g.vertices {
+		r.Add(v)
+		g.copyVertexAttrs(r, v, v)
+	}
+	for _, raw := range g.edges {
+		e := raw.(Edge)
+		r.Connect(copyEdge(e, e.Target(), e.Source()))
+	}
+
+	return r
+}
+
+// Reverse returns a new graph with every edge flipped. See Graph.Reverse.
+func (g *AcyclicGraph) Reverse() *AcyclicGraph {
+	return &AcyclicGraph{Graph: *g.Graph.Reverse()}
 }
 
 // Dot returns a dot-formatted representation of the Graph.
 func (g *Graph) Dot(opts *DotOpts) []byte {
-	return newMarshalGraph("", g).Dot(opts)
+	return newMarshalGraph("", g, nil).Dot(opts)
+}
+
+// WriteDot writes the dot-formatted representation of the Graph to w as it
+// is generated, rather than buffering the whole output like Dot. The graph is
+// still converted to a MarshalGraph first, so memory use grows with the size
+// of the graph, though not with the size of its output. The first error from
+// w is returned.
+func (g *Graph) WriteDot(w io.Writer, opts *DotOpts) error {
+	return newMarshalGraph("", g, nil).WriteDot(w, opts)
 }
 
 // VertexName returns the name of a vertex.
 func VertexName(raw Vertex) string {
 	switch v := raw.(type) {
+	case string:
+		return v
 	case fmt.Stringer:
 		return v.String()
 	case NamedVertex:
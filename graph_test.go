@@ -2,7 +2,10 @@ package dag
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -48,6 +51,713 @@ func TestGraph_remove(t *testing.T) {
 	}
 }
 
+func TestGraph_snapshot(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+
+	snap := g.Snapshot()
+	expected := g.String()
+
+	g.Add(4)
+	g.Connect(BasicEdge(3, 4))
+	g.RemoveEdge(BasicEdge(1, 2))
+	g.Remove(2)
+
+	if actual := snap.String(); actual != expected {
+		t.Fatalf("snapshot changed:\n%s", actual)
+	}
+
+	// changing the snapshot must not affect the live graph either
+	live := g.String()
+	snap.Remove(1)
+	if actual := g.String(); actual != live {
+		t.Fatalf("graph changed:\n%s", actual)
+	}
+}
+
+func TestGraph_snapshotConcurrent(t *testing.T) {
+	var g AcyclicGraph
+	for i := 0; i < 100; i++ {
+		g.Add(i)
+		if i > 0 {
+			g.Connect(BasicEdge(i-1, i))
+		}
+	}
+
+	snap := g.Snapshot()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 100; i < 200; i++ {
+			g.Add(i)
+			g.Connect(BasicEdge(i-1, i))
+		}
+	}()
+
+	descendants, err := snap.Descendants(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if descendants.Len() != 99 {
+		t.Fatalf("expected 99 descendants, got %d", descendants.Len())
+	}
+	if len(g.Vertices()) != 200 {
+		t.Fatalf("expected 200 vertices, got %d", len(g.Vertices()))
+	}
+}
+
+func TestGraph_clone(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(1, 3))
+	g.Connect(BasicEdge(2, 3))
+
+	c := g.Clone()
+	expected := g.String()
+	if actual := c.String(); actual != expected {
+		t.Fatalf("bad clone:\n%s", actual)
+	}
+
+	c.TransitiveReduction()
+	if actual := g.String(); actual != expected {
+		t.Fatalf("original changed:\n%s", actual)
+	}
+	if c.HasEdge(BasicEdge(1, 3)) {
+		t.Fatal("clone should have been reduced")
+	}
+}
+
+func TestGraph_cloneSubgraph(t *testing.T) {
+	sub := &Graph{}
+	sub.Add("x")
+
+	var g Graph
+	s := &ParsedSubgraph{ParsedVertex: &ParsedVertex{Name: "sub"}, Graph: sub}
+	g.Add("a")
+	g.Add(s)
+	g.Connect(BasicAttrEdge("a", s, map[string]string{"label": "foo"}))
+
+	c := g.Clone()
+	if actual, expected := c.String(), g.String(); actual != expected {
+		t.Fatalf("bad clone:\n%s", actual)
+	}
+	if c.HasVertex(s) {
+		t.Fatal("subgraph vertex should have been cloned")
+	}
+
+	var cs *ParsedSubgraph
+	for _, v := range c.Vertices() {
+		if v, ok := v.(*ParsedSubgraph); ok {
+			cs = v
+		}
+	}
+	cs.Graph.Add("y")
+	if sub.HasVertex("y") {
+		t.Fatal("original subgraph changed")
+	}
+
+	edges := c.EdgesTo(cs)
+	if len(edges) != 1 {
+		t.Fatalf("expected 1 edge, got: %#v", edges)
+	}
+	if ae, ok := edges[0].(AttrEdge); !ok || ae.Attrs()["label"] != "foo" {
+		t.Fatalf("edge attributes lost: %#v", edges[0])
+	}
+}
+
+func TestGraph_contract(t *testing.T) {
+	var g Graph
+	for _, v := range []string{"a", "b", "c", "d", "e"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("a", "b"))
+	g.Connect(BasicWeightedEdge("b", "c", 3))
+	g.Connect(BasicEdge("a", "c"))
+	g.Connect(BasicEdge("d", "a"))
+	g.Connect(BasicEdge("d", "b"))
+	g.Connect(BasicEdge("e", "b"))
+
+	if !g.Contract("a", "b", "ab") {
+		t.Fatal("should contract")
+	}
+
+	actual := strings.TrimSpace(g.String())
+	expected := strings.TrimSpace(testGraphContractStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+
+	if g.Contract("ab", "missing", "x") {
+		t.Fatal("should not contract a missing vertex")
+	}
+	if !g.HasVertex("ab") {
+		t.Fatal("graph should be unchanged")
+	}
+}
+
+func TestGraph_contractAttrs(t *testing.T) {
+	var g Graph
+	g.Add("a")
+	g.Add("b")
+	g.Add("c")
+	g.Connect(BasicEdge("a", "b"))
+	g.Connect(BasicEdge("b", "c"))
+	g.SetVertexAttr("a", "owner", "team-a")
+	g.SetVertexAttr("a", "tier", "1")
+	g.SetVertexAttr("b", "owner", "team-b")
+	g.SetVertexAttr("b", "region", "eu")
+
+	if !g.Contract("a", "b", "a") {
+		t.Fatal("should contract")
+	}
+
+	expected := map[string]string{"owner": "team-a", "tier": "1", "region": "eu"}
+	if got := g.VertexAttrs("a"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("bad attributes: %#v", got)
+	}
+	if got := g.VertexAttrs("b"); got != nil {
+		t.Fatalf("attributes left on the contracted vertex: %#v", got)
+	}
+	if !g.HasEdge(BasicEdge("a", "c")) {
+		t.Fatal("edge should move to the merged vertex")
+	}
+}
+
+func TestNewGraph(t *testing.T) {
+	g := NewGraph(10, 20)
+	g.Add(1)
+	g.Add(2)
+	g.Connect(BasicEdge(1, 2))
+	g.SetVertexAttr(1, "owner", "team-a")
+
+	g.Grow(100, 100)
+	g.Add(3)
+	g.Connect(BasicEdge(2, 3))
+
+	actual := strings.TrimSpace(g.String())
+	expected := strings.TrimSpace(testNewGraphStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+	if g.VertexAttrs(1)["owner"] != "team-a" {
+		t.Fatal("Grow lost the vertex attributes")
+	}
+
+	// a snapshot taken before growing is unchanged
+	snap := g.Snapshot()
+	g.Grow(10, 10)
+	g.Remove(3)
+	if !snap.HasVertex(3) || !snap.HasEdge(BasicEdge(2, 3)) {
+		t.Fatal("snapshot changed")
+	}
+
+	a := NewAcyclicGraph(0, 0)
+	a.Add(1)
+	if err := a.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGraph_removeWithEdges(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicLabeledEdge(2, 3, "build"))
+	g.Connect(BasicLabeledEdge(2, 3, "runtime"))
+	g.Connect(BasicEdge(2, 2))
+	g.Connect(BasicEdge(1, 3))
+
+	removed := g.RemoveWithEdges(2)
+	var names []string
+	for _, e := range removed {
+		names = append(names, fmt.Sprintf("%v->%v %s", e.Source(), e.Target(), edgeLabel(e)))
+	}
+	expected := []string{"1->2 ", "2->2 ", "2->3 build", "2->3 runtime"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad removed edges: %#v", names)
+	}
+
+	if _, ok := g.downEdges[hashcode(2)]; ok {
+		t.Fatal("left adjacency from the removed vertex")
+	}
+	if _, ok := g.upEdges[hashcode(2)]; ok {
+		t.Fatal("left adjacency to the removed vertex")
+	}
+	if g.UpEdges(3).Include(2) || g.DownEdges(1).Include(2) {
+		t.Fatal("left adjacency in the remaining vertices")
+	}
+	if len(g.Edges()) != 1 {
+		t.Fatalf("bad edges: %#v", g.Edges())
+	}
+
+	if removed := g.RemoveWithEdges(42); len(removed) != 0 {
+		t.Fatalf("removed edges of a missing vertex: %#v", removed)
+	}
+}
+
+func TestGraph_removeWithEdges_custom(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(&testEdge{S: 1, T: 2})
+	g.Connect(&testEdge{S: 2, T: 3})
+	g.Connect(BasicLabeledEdge(2, 3, "build"))
+
+	if removed := g.RemoveWithEdges(2); len(removed) != 3 {
+		t.Fatalf("bad removed edges: %#v", removed)
+	}
+	if n := len(g.Edges()); n != 0 {
+		t.Fatalf("expected no edges, got %d", n)
+	}
+	if err := CheckInvariants(&g); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGraph_removeWithEdges_hashable(t *testing.T) {
+	var g Graph
+	g.Add(&hashVertex{code: 1})
+	g.Add(&hashVertex{code: 2})
+	g.Connect(BasicEdge(&hashVertex{code: 1}, &hashVertex{code: 2}))
+	g.Connect(BasicEdge(&hashVertex{code: 1}, &hashVertex{code: 2}))
+	g.Connect(BasicLabeledEdge(&hashVertex{code: 1}, &hashVertex{code: 2}, "build"))
+
+	if n := len(g.Edges()); n != 2 {
+		t.Fatalf("expected 2 edges, got %d", n)
+	}
+	if removed := g.RemoveWithEdges(&hashVertex{code: 1}); len(removed) != 2 {
+		t.Fatalf("bad removed edges: %#v", removed)
+	}
+	if n := len(g.Edges()); n != 0 {
+		t.Fatalf("expected no edges, got %d", n)
+	}
+	if err := CheckInvariants(&g); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGraph_strict(t *testing.T) {
+	g := NewAcyclicGraphWithOpts(&GraphOpts{Strict: true})
+	g.Add(1)
+	g.Add(2)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicLabeledEdge(1, 2, "build"))
+	if err := g.ConnectErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicWeightedEdge(1, 2, 3))
+	g.Connect(BasicEdge(2, 2))
+
+	err := g.ConnectErr()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"Duplicate edge: 1 -> 2", "Self reference: 2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q:\n%s", want, err)
+		}
+	}
+	if g.HasEdge(BasicEdge(2, 2)) || edgeWeight(g.EdgesBetween(1, 2)[0]) != 1 {
+		t.Fatal("rejected edges were added")
+	}
+
+	if err := g.Validate(); err == nil || !strings.Contains(err.Error(), "Duplicate edge") {
+		t.Fatalf("Validate should report rejected edges, got %v", err)
+	}
+	if err := g.ConnectChecked(BasicEdge(1, 2)); err == nil || !strings.Contains(err.Error(), "Duplicate edge") {
+		t.Fatalf("ConnectChecked should reject duplicates, got %v", err)
+	}
+
+	// a graph isn't strict by default
+	var plain Graph
+	plain.Connect(BasicEdge(1, 1))
+	plain.Connect(BasicEdge(1, 1))
+	if err := plain.ConnectErr(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGraph_edgeBetween(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicWeightedEdge(1, 2, 4))
+	g.Connect(BasicLabeledEdge(2, 3, "runtime"))
+	g.Connect(BasicLabeledEdge(2, 3, "build"))
+
+	e, ok := g.EdgeBetween(1, 2)
+	if !ok || edgeWeight(e) != 4 {
+		t.Fatalf("bad edge: %#v", e)
+	}
+	e, ok = g.EdgeBetween(2, 3)
+	if !ok || edgeLabel(e) != "build" {
+		t.Fatalf("bad edge: %#v", e)
+	}
+	if _, ok := g.EdgeBetween(2, 1); ok {
+		t.Fatal("edges are directed")
+	}
+
+	if !g.HasEdgeBetween(1, 2) || !g.HasEdgeBetween(2, 3) {
+		t.Fatal("missing edges")
+	}
+	if g.HasEdgeBetween(1, 3) || g.HasEdgeBetween(3, 2) {
+		t.Fatal("unexpected edges")
+	}
+
+	g.Connect(BasicEdge(2, 3))
+	if e, _ := g.EdgeBetween(2, 3); edgeLabel(e) != "" {
+		t.Fatalf("should prefer the unlabeled edge: %#v", e)
+	}
+}
+
+func TestGraph_vertexByName(t *testing.T) {
+	var g Graph
+	a := g.Add(&testVertexByName{"a"})
+	g.Add("b")
+
+	if v, ok := g.VertexByName("a"); !ok || v != a {
+		t.Fatalf("bad vertex: %#v", v)
+	}
+
+	// the index is kept up to date
+	c := g.Add(&testVertexByName{"c"})
+	if v, ok := g.VertexByName("c"); !ok || v != c {
+		t.Fatalf("bad vertex: %#v", v)
+	}
+
+	a2 := g.Add(&testVertexByName{"a"})
+	g.Remove(a)
+	if v, ok := g.VertexByName("a"); !ok || v != a2 {
+		t.Fatalf("bad vertex: %#v", v)
+	}
+	g.Remove(a2)
+	if _, ok := g.VertexByName("a"); ok {
+		t.Fatal("found a removed vertex")
+	}
+
+	g.Replace("b", "d")
+	if _, ok := g.VertexByName("b"); ok {
+		t.Fatal("found a replaced vertex")
+	}
+	if _, ok := g.VertexByName("d"); !ok {
+		t.Fatal("missing replacement")
+	}
+}
+
+type testVertexByName struct {
+	name string
+}
+
+func (v *testVertexByName) Name() string { return v.name }
+
+func TestGraph_vertexByNameConcurrent(t *testing.T) {
+	var g Graph
+	for i := 0; i < 100; i++ {
+		g.Add(i)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if v, ok := g.VertexByName(strconv.Itoa(i)); !ok || v != i {
+				t.Errorf("bad vertex for %d: %v", i, v)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestGraph_splice(t *testing.T) {
+	var g Graph
+	for _, v := range []string{"a", "b", "c", "d", "e"} {
+		g.Add(v)
+	}
+	g.Connect(BasicWeightedEdge("a", "c", 2))
+	g.Connect(BasicEdge("b", "c"))
+	g.Connect(BasicEdge("c", "d"))
+	g.Connect(BasicEdge("c", "e"))
+	g.Connect(BasicEdge("a", "d"))
+
+	if !g.Splice("c") {
+		t.Fatal("should splice")
+	}
+
+	actual := strings.TrimSpace(g.String())
+	expected := strings.TrimSpace(testGraphSpliceStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+
+	if w := edgeWeight(g.EdgesBetween("a", "e")[0]); w != 2 {
+		t.Fatalf("spliced edge should keep its weight, got %v", w)
+	}
+
+	if g.Splice("c") {
+		t.Fatal("should not splice a missing vertex")
+	}
+}
+
+func TestGraph_reverse(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicAttrEdge(2, 3, map[string]string{"label": "x"}))
+
+	r := g.Reverse()
+
+	actual := strings.TrimSpace(r.String())
+	expected := strings.TrimSpace(testGraphReverseStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+
+	order, err := r.TopologicalSort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, []Vertex{1, 2, 3}) {
+		t.Fatalf("bad order: %#v", order)
+	}
+
+	for _, e := range r.EdgesFrom(3) {
+		if e.(AttrEdge).Attrs()["label"] != "x" {
+			t.Fatalf("edge lost its attributes: %#v", e)
+		}
+	}
+
+	// the original is unchanged
+	if !g.HasEdge(BasicEdge(1, 2)) || g.HasEdge(BasicEdge(2, 1)) {
+		t.Fatalf("bad: %s", g.String())
+	}
+}
+
+func TestGraph_vertexAttrs(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Connect(BasicEdge(1, 2))
+
+	g.SetVertexAttr(1, "owner", "team-a")
+	g.SetVertexAttr(1, "color", "red")
+	g.SetVertexAttr(3, "owner", "team-b")
+
+	expected := map[string]string{"owner": "team-a", "color": "red"}
+	if actual := g.VertexAttrs(1); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if actual := g.VertexAttrs(2); actual != nil {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if actual := g.VertexAttrs(3); actual != nil {
+		t.Fatalf("attribute set on a missing vertex: %#v", actual)
+	}
+
+	// the returned attributes are a copy
+	g.VertexAttrs(1)["owner"] = "team-c"
+	if g.VertexAttrs(1)["owner"] != "team-a" {
+		t.Fatal("attributes changed through the returned map")
+	}
+
+	// a snapshot isn't affected by later changes
+	snap := g.Snapshot()
+	g.SetVertexAttr(1, "owner", "team-c")
+	if snap.VertexAttrs(1)["owner"] != "team-a" {
+		t.Fatal("snapshot attributes changed")
+	}
+
+	if g.Clone().VertexAttrs(1)["owner"] != "team-c" {
+		t.Fatal("clone lost its attributes")
+	}
+
+	g.Replace(1, 42)
+	if g.VertexAttrs(42)["owner"] != "team-c" {
+		t.Fatal("replacement lost its attributes")
+	}
+
+	g.Remove(42)
+	g.Add(42)
+	if actual := g.VertexAttrs(42); actual != nil {
+		t.Fatalf("attributes kept after removal: %#v", actual)
+	}
+}
+
+func TestGraph_labeledEdges(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Connect(BasicLabeledEdge(1, 2, "build"))
+	g.Connect(BasicLabeledEdge(1, 2, "runtime"))
+	g.Connect(BasicLabeledEdge(1, 2, "runtime"))
+	g.Connect(BasicEdge(1, 2))
+
+	if n := len(g.EdgesBetween(1, 2)); n != 3 {
+		t.Fatalf("expected 3 edges, got %d", n)
+	}
+	if len(g.EdgesBetween(2, 1)) != 0 {
+		t.Fatal("expected no edges from 2 to 1")
+	}
+	if !g.HasEdge(BasicLabeledEdge(1, 2, "build")) {
+		t.Fatal("missing build edge")
+	}
+	if g.HasEdge(BasicLabeledEdge(1, 2, "test")) {
+		t.Fatal("unexpected test edge")
+	}
+
+	// the vertices stay connected until the last edge between them is gone
+	g.RemoveEdge(BasicLabeledEdge(1, 2, "build"))
+	g.RemoveEdge(BasicEdge(1, 2))
+	if !g.DownEdges(1).Include(2) || !g.UpEdges(2).Include(1) {
+		t.Fatal("vertices should still be connected")
+	}
+	g.RemoveEdge(BasicLabeledEdge(1, 2, "runtime"))
+	if g.DownEdges(1).Len() != 0 || g.UpEdges(2).Len() != 0 {
+		t.Fatal("vertices should not be connected")
+	}
+
+	// removing a vertex removes every edge to it
+	g.Connect(BasicLabeledEdge(1, 2, "build"))
+	g.Connect(BasicLabeledEdge(1, 2, "runtime"))
+	g.Remove(2)
+	if n := len(g.Edges()); n != 0 {
+		t.Fatalf("expected no edges, got %d", n)
+	}
+}
+
+// testEdge is an Edge hashed by its own pointer, rather than by its
+// vertices like a BasicEdge.
+type testEdge struct {
+	S, T Vertex
+}
+
+func (e *testEdge) Hashcode() interface{} { return e }
+func (e *testEdge) Source() Vertex        { return e.S }
+func (e *testEdge) Target() Vertex        { return e.T }
+
+func TestGraph_customEdges(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	var first Edge = &testEdge{S: 1, T: 2}
+	g.Connect(first)
+	g.Connect(&testEdge{S: 1, T: 2})
+	g.Connect(BasicEdge(1, 2))
+
+	// the same vertices make the same edge, whatever its type
+	if n := len(g.Edges()); n != 1 {
+		t.Fatalf("expected 1 edge, got %d", n)
+	}
+	if e, ok := g.EdgeBetween(1, 2); !ok || e != first {
+		t.Fatalf("bad edge: %#v", e)
+	}
+	if es := g.EdgesBetween(1, 2); len(es) != 1 || es[0] != first {
+		t.Fatalf("bad edges: %#v", es)
+	}
+
+	// a label still makes a separate edge
+	g.Connect(BasicLabeledEdge(1, 2, "build"))
+	if n := len(g.EdgesBetween(1, 2)); n != 2 {
+		t.Fatalf("expected 2 edges, got %d", n)
+	}
+
+	// the edge can be removed as a BasicEdge
+	g.RemoveEdge(BasicEdge(1, 2))
+	if es := g.EdgesBetween(1, 2); len(es) != 1 || edgeLabel(es[0]) != "build" {
+		t.Fatalf("bad edges: %#v", es)
+	}
+	if err := CheckInvariants(&g); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGraph_sorted(t *testing.T) {
+	var g Graph
+	for _, v := range []string{"c", "a", "b"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("c", "a"))
+	g.Connect(BasicLabeledEdge("a", "c", "runtime"))
+	g.Connect(BasicLabeledEdge("a", "c", "build"))
+	g.Connect(BasicEdge("a", "b"))
+
+	var vs []string
+	for _, v := range g.SortedVertices() {
+		vs = append(vs, VertexName(v))
+	}
+	if !reflect.DeepEqual(vs, []string{"a", "b", "c"}) {
+		t.Fatalf("bad vertices: %#v", vs)
+	}
+
+	var es []string
+	for _, e := range g.SortedEdges() {
+		es = append(es, fmt.Sprintf("%s-%s:%s", VertexName(e.Source()), VertexName(e.Target()), edgeLabel(e)))
+	}
+	expected := []string{"a-b:", "a-c:build", "a-c:runtime", "c-a:"}
+	if !reflect.DeepEqual(es, expected) {
+		t.Fatalf("bad edges: %#v", es)
+	}
+}
+
+func TestGraph_listeners(t *testing.T) {
+	var g Graph
+
+	var events []string
+	g.OnAdd(func(v Vertex) {
+		events = append(events, fmt.Sprintf("add %v", v))
+	})
+	g.OnRemove(func(v Vertex) {
+		events = append(events, fmt.Sprintf("remove %v", v))
+	})
+	g.OnConnect(func(e Edge) {
+		events = append(events, fmt.Sprintf("connect %v->%v", e.Source(), e.Target()))
+	})
+	g.OnRemoveEdge(func(e Edge) {
+		events = append(events, fmt.Sprintf("remove edge %v->%v", e.Source(), e.Target()))
+	})
+
+	g.Add(1)
+	g.Add(2)
+	g.Add(2)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(1, 2))
+	g.RemoveEdge(BasicEdge(1, 2))
+	g.RemoveEdge(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 1))
+	g.Remove(1)
+	g.Remove(1)
+
+	expected := []string{
+		"add 1",
+		"add 2",
+		"connect 1->2",
+		"remove edge 1->2",
+		"connect 2->1",
+		"remove edge 2->1",
+		"remove 1",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("bad events:\n%s", strings.Join(events, "\n"))
+	}
+}
+
 func TestGraph_replace(t *testing.T) {
 	var g Graph
 	g.Add(1)
@@ -80,6 +790,82 @@ func TestGraph_replaceSelf(t *testing.T) {
 	}
 }
 
+func TestGraph_replaceWithOpts(t *testing.T) {
+	var sub Graph
+	sub.Add(2)
+	sub.Add(4)
+	sub.Connect(BasicEdge(4, 2))
+
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	s := g.Add(&testSubgrapher{name: "sub", graph: &sub})
+	g.Connect(BasicLabeledEdge(1, 2, "build"))
+	g.Connect(BasicAttrEdge(2, 3, map[string]string{"color": "red"}))
+	g.Connect(BasicEdge(s, 3))
+	g.SetVertexAttr(2, "owner", "team-a")
+
+	if !g.ReplaceWithOpts(2, 42, &ReplaceOpts{EdgeAttrs: true, Subgraphs: true}) {
+		t.Fatal("should replace")
+	}
+
+	if g.HasVertex(2) || sub.HasVertex(2) || !sub.HasEdge(BasicEdge(4, 42)) {
+		t.Fatalf("bad subgraph:\n%s", sub.String())
+	}
+	if g.VertexAttrs(42)["owner"] != "team-a" {
+		t.Fatal("replacement lost its attributes")
+	}
+	if es := g.EdgesBetween(1, 42); len(es) != 1 || edgeLabel(es[0]) != "build" {
+		t.Fatalf("bad edges from 1: %#v", es)
+	}
+	if es := g.EdgesBetween(42, 3); len(es) != 1 || es[0].(AttrEdge).Attrs()["color"] != "red" {
+		t.Fatalf("bad edges to 3: %#v", es)
+	}
+
+	// only in a subgraph
+	if !g.ReplaceWithOpts(4, 44, &ReplaceOpts{Subgraphs: true}) || !sub.HasVertex(44) {
+		t.Fatal("should replace within the subgraph")
+	}
+	if g.ReplaceWithOpts(4, 44, &ReplaceOpts{Subgraphs: true}) {
+		t.Fatal("should not replace a missing vertex")
+	}
+}
+
+func TestGraph_replaceFunc(t *testing.T) {
+	var g Graph
+	for i := 1; i <= 4; i++ {
+		g.Add(i)
+	}
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 4))
+	g.SetVertexAttr(3, "owner", "team-a")
+
+	// 2 and 3 swap places, as the function sees the original graph
+	n := g.ReplaceFunc(func(v Vertex) Vertex {
+		switch v {
+		case 2:
+			return 3
+		case 3:
+			return 2
+		}
+		return v
+	}, nil)
+	if n != 2 {
+		t.Fatalf("replaced %d vertices", n)
+	}
+
+	actual := strings.TrimSpace(g.String())
+	expected := strings.TrimSpace(testGraphReplaceFuncStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+	if g.VertexAttrs(2)["owner"] != "team-a" || g.VertexAttrs(3) != nil {
+		t.Fatal("attributes should move with the vertices")
+	}
+}
+
 // This tests that connecting edges works based on custom Hashcode
 // implementations for uniqueness.
 func TestGraph_hashcode(t *testing.T) {
@@ -244,6 +1030,16 @@ const testGraphReplaceStr = `
   3
 `
 
+const testGraphReplaceFuncStr = `
+1
+  3
+2
+  4
+3
+  2
+4
+`
+
 const testGraphReplaceSelfStr = `
 1
   2
@@ -251,3 +1047,47 @@ const testGraphReplaceSelfStr = `
   3
 3
 `
+
+const testGraphContractStr = `
+ab
+  c
+c
+d
+  ab
+e
+  ab
+`
+
+const testNewGraphStr = `
+1
+  2
+2
+  3
+3
+`
+
+const testGraphSpliceStr = `
+a
+  d
+  e
+b
+  d
+  e
+d
+e
+`
+
+const testGraphReverseStr = `
+1
+2
+  1
+3
+  2
+`
+
+func BenchmarkVertexName_string(b *testing.B) {
+	var v Vertex = "vertex"
+	for n := 0; n < b.N; n++ {
+		VertexName(v)
+	}
+}
@@ -0,0 +1,97 @@
+package dag
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Hash returns a SHA-256 hash of the content of the graph: the names and
+// attributes of its vertices, the names of the vertices connected by each
+// edge along with the edge's label, weight and attributes, and the content of
+// any subgraphs. It doesn't depend on the order vertices and edges were
+// added, or on the identity of the vertices, so the same graph built by two
+// runs of a program hashes the same, and a changed hash means the graph has
+// changed. Graphs whose vertices can't be told apart by name may hash the
+// same while differing in which of those vertices are connected.
+//
+// An error is returned for a Subgrapher without a *Graph or *AcyclicGraph.
+//
+// Complexity: O((V+E) log (V+E))
+func (g *Graph) Hash() ([32]byte, error) {
+	var b strings.Builder
+	if err := g.writeHashContent(&b); err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256([]byte(b.String())), nil
+}
+
+// writeHashContent writes a canonical description of g to b, for Hash.
+func (g *Graph) writeHashContent(b *strings.Builder) error {
+	var records []string
+	for _, v := range g.vertices {
+		var r strings.Builder
+		writeHashString(&r, "v")
+		writeHashString(&r, VertexName(v))
+		writeHashAttrs(&r, g.vertexAttrs[hashcode(v)])
+
+		if sg, ok := marshalSubgrapher(v); ok {
+			writeHashString(&r, "subgraph")
+			if err := sg.writeHashContent(&r); err != nil {
+				return err
+			}
+		} else if _, ok := v.(Subgrapher); ok {
+			return fmt.Errorf("subgraph %q has no *Graph or *AcyclicGraph to hash", VertexName(v))
+		}
+
+		records = append(records, r.String())
+	}
+
+	for _, raw := range g.edges {
+		e := raw.(Edge)
+		var r strings.Builder
+		writeHashString(&r, "e")
+		writeHashString(&r, VertexName(e.Source()))
+		writeHashString(&r, VertexName(e.Target()))
+		writeHashString(&r, edgeLabel(e))
+		writeHashString(&r, strconv.FormatFloat(edgeWeight(e), 'g', -1, 64))
+		if ae, ok := e.(AttrEdge); ok {
+			writeHashAttrs(&r, ae.Attrs())
+		} else {
+			writeHashAttrs(&r, nil)
+		}
+		records = append(records, r.String())
+	}
+
+	sort.Strings(records)
+	writeHashString(b, strconv.Itoa(len(records)))
+	for _, r := range records {
+		writeHashString(b, r)
+	}
+	return nil
+}
+
+// writeHashAttrs writes attrs to b in key order.
+func writeHashAttrs(b *strings.Builder, attrs map[string]string) {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	writeHashString(b, strconv.Itoa(len(keys)))
+	for _, k := range keys {
+		writeHashString(b, k)
+		writeHashString(b, attrs[k])
+	}
+}
+
+// writeHashString writes s to b prefixed with its length, so that the
+// boundaries between strings are unambiguous.
+func writeHashString(b *strings.Builder, s string) {
+	b.WriteString(strconv.Itoa(len(s)))
+	b.WriteByte(':')
+	b.WriteString(s)
+}
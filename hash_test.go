@@ -0,0 +1,66 @@
+package dag
+
+import (
+	"testing"
+)
+
+func TestGraphHash(t *testing.T) {
+	build := func(order []string) *Graph {
+		var g Graph
+		for _, v := range order {
+			g.Add(v)
+		}
+		g.Connect(BasicEdge("a", "b"))
+		g.Connect(BasicLabeledEdge("b", "c", "build"))
+		g.SetVertexAttr("a", "owner", "team-a")
+		return &g
+	}
+
+	hash := func(g *Graph) [32]byte {
+		h, err := g.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	g := build([]string{"a", "b", "c"})
+	base := hash(g)
+	if hash(build([]string{"c", "b", "a"})) != base {
+		t.Fatal("hash should not depend on the order vertices are added")
+	}
+
+	changes := map[string]func(g *Graph){
+		"vertex":      func(g *Graph) { g.Add("d") },
+		"edge":        func(g *Graph) { g.Connect(BasicEdge("a", "c")) },
+		"edge label":  func(g *Graph) { g.Connect(BasicLabeledEdge("b", "c", "runtime")) },
+		"edge weight": func(g *Graph) { g.RemoveEdge(BasicEdge("a", "b")); g.Connect(BasicWeightedEdge("a", "b", 2)) },
+		"vertex attr": func(g *Graph) { g.SetVertexAttr("a", "owner", "team-b") },
+		"edge attr": func(g *Graph) {
+			g.RemoveEdge(BasicEdge("a", "b"))
+			g.Connect(BasicAttrEdge("a", "b", map[string]string{"color": "red"}))
+		},
+		"subgraph": func(g *Graph) {
+			var sub Graph
+			sub.Add("x")
+			g.Add(&testSubgrapher{name: "d", graph: &sub})
+		},
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			g := build([]string{"a", "b", "c"})
+			change(g)
+			if hash(g) == base {
+				t.Fatal("hash should change")
+			}
+		})
+	}
+
+	if _, err := build(nil).Hash(); err != nil {
+		t.Fatal(err)
+	}
+	g.Add(&testSubgrapher{name: "broken"})
+	if _, err := g.Hash(); err == nil {
+		t.Fatal("should error for a subgrapher without a graph")
+	}
+}
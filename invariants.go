@@ -0,0 +1,177 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CheckInvariants verifies that the internal indexes of g are consistent
+// with each other, returning an error describing every problem found. It
+// checks that:
+//
+//   - each vertex and edge is stored under its current hashcode, so a
+//     Hashable vertex hasn't changed its hashcode since it was added
+//   - the edges set holds only Edge values, and the vertices set no nil ones
+//   - every edge has a matching down-edge and up-edge, and every down-edge
+//     and up-edge has a matching edge
+//   - no edge references a vertex missing from the graph
+//
+// This is meant for tests of code that manipulates graphs. Building with the
+// dagdebug build tag also checks the vertices and edges touched by every
+// change to any graph, panicking if they are stored inconsistently. That
+// check allows edges referencing missing vertices, which are normal while a
+// graph is being built.
+//
+// Complexity: O(V + E)
+func CheckInvariants(g *Graph) error {
+	var diags Diagnostics
+	for _, err := range g.checkIndexes() {
+		diags = diags.Append(err)
+	}
+	return diags.Err()
+}
+
+// checkIndexes returns the problems found by CheckInvariants, sorted by their
+// messages.
+func (g *Graph) checkIndexes() []error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	for code, v := range g.vertices {
+		if v == nil {
+			add("Nil vertex stored under hashcode %v", code)
+		} else if hashcode(v) != code {
+			add("Vertex %s stored under hashcode %v, but has hashcode %v",
+				VertexName(v), code, hashcode(v))
+		}
+	}
+
+	// pairs holds the hashcodes of the source and target of every edge.
+	pairs := make(map[[2]interface{}]bool, len(g.edges))
+	for code, raw := range g.edges {
+		e, ok := raw.(Edge)
+		if !ok {
+			add("Edge set holds %T, which is not an Edge", raw)
+			continue
+		}
+		name := fmt.Sprintf("%s -> %s", VertexName(e.Source()), VertexName(e.Target()))
+		if hashcode(e) != code {
+			add("Edge %s stored under hashcode %v, but has hashcode %v", name, code, hashcode(e))
+		}
+
+		pair := edgePair(e.Source(), e.Target())
+		pairs[pair] = true
+		if !g.downEdges[pair[0]].Include(e.Target()) {
+			add("Edge %s has no down-edge", name)
+		}
+		if !g.upEdges[pair[1]].Include(e.Source()) {
+			add("Edge %s has no up-edge", name)
+		}
+		if pairIndexed(e) && !g.pairs[pair].Include(e) {
+			add("Edge %s is missing from the pair index", name)
+		}
+		for _, v := range []Vertex{e.Source(), e.Target()} {
+			if !g.vertices.Include(v) {
+				add("Dangling edge: %s references missing vertex %s", name, VertexName(v))
+			}
+		}
+	}
+
+	for source, targets := range g.downEdges {
+		for target, v := range targets {
+			if !pairs[[2]interface{}{source, target}] {
+				add("Down-edge from hashcode %v to %s has no edge", source, VertexName(v))
+			}
+		}
+	}
+	for target, sources := range g.upEdges {
+		for source, v := range sources {
+			if !pairs[[2]interface{}{source, target}] {
+				add("Up-edge from %s to hashcode %v has no edge", VertexName(v), target)
+			}
+		}
+	}
+
+	for pair, s := range g.pairs {
+		for _, raw := range s {
+			if e, ok := raw.(Edge); !ok || !g.edges.Include(e) || edgePair(e.Source(), e.Target()) != pair {
+				add("Pair index holds %v, which is not an edge of the graph", raw)
+			}
+		}
+	}
+
+	if g.names != nil {
+		indexed := 0
+		for name, vs := range g.names {
+			for _, v := range vs {
+				indexed++
+				if !g.vertices.Include(v) || VertexName(v) != name {
+					add("Name index holds %s under %q, which is not a vertex of the graph", VertexName(v), name)
+				}
+			}
+		}
+		if indexed != len(g.vertices) {
+			add("Name index holds %d vertices, but the graph has %d", indexed, len(g.vertices))
+		}
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+// checkAround returns the inconsistencies in how v and the edges to and from
+// it are stored, which finds most of those caused by a single change
+// involving v without checking the whole graph. Each down-edge and up-edge
+// is only checked against its mirror image, since finding the matching edge
+// would mean searching them all.
+func (g *Graph) checkAround(v Vertex) []error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	code := hashcode(v)
+	stored, ok := g.vertices[code]
+	if ok && hashcode(stored) != code {
+		add("Vertex %s stored under hashcode %v, but has hashcode %v",
+			VertexName(stored), code, hashcode(stored))
+	}
+
+	for _, target := range g.downEdges[code] {
+		if !g.upEdges[hashcode(target)].Include(v) {
+			add("Down-edge from %s to %s has no up-edge", VertexName(v), VertexName(target))
+		}
+	}
+	for _, source := range g.upEdges[code] {
+		if !g.downEdges[hashcode(source)].Include(v) {
+			add("Up-edge from %s to %s has no down-edge", VertexName(source), VertexName(v))
+		}
+	}
+
+	if g.names != nil {
+		indexed := false
+		for _, n := range g.names[VertexName(v)] {
+			indexed = indexed || hashcode(n) == code
+		}
+		if indexed != ok {
+			add("Name index disagrees with the graph about vertex %s", VertexName(v))
+		}
+	}
+
+	return errs
+}
+
+// debugCheck panics if the vertices given are stored inconsistently, as
+// found by checkAround. It is only called when built with the dagdebug build
+// tag.
+func (g *Graph) debugCheck(vs ...Vertex) {
+	var errs []error
+	for _, v := range vs {
+		errs = append(errs, g.checkAround(v)...)
+	}
+	if len(errs) > 0 {
+		panic(fmt.Sprintf("dag: inconsistent graph: %v", errs))
+	}
+}
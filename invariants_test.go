@@ -0,0 +1,90 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckInvariants(t *testing.T) {
+	var g Graph
+	if err := CheckInvariants(&g); err != nil {
+		t.Fatal(err)
+	}
+
+	g.Add("a")
+	g.Add("b")
+	g.Add("c")
+	g.Connect(BasicEdge("a", "b"))
+	g.Connect(BasicLabeledEdge("a", "c", "x"))
+	g.Connect(BasicLabeledEdge("a", "c", "y"))
+	g.VertexByName("a")
+	g.RemoveEdge(BasicLabeledEdge("a", "c", "x"))
+	g.Remove("b")
+	if err := CheckInvariants(&g); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := g.Snapshot()
+	g.Add("d")
+	if err := CheckInvariants(snapshot); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckInvariants_broken(t *testing.T) {
+	cases := map[string]struct {
+		Break func(g *Graph)
+		Error string
+	}{
+		"dangling edge": {
+			func(g *Graph) { g.Connect(BasicEdge("a", "missing")) },
+			"Dangling edge: a -> missing references missing vertex missing",
+		},
+		"missing down-edge": {
+			func(g *Graph) { g.downEdges["a"].Delete("b") },
+			"Edge a -> b has no down-edge",
+		},
+		"missing up-edge": {
+			func(g *Graph) { delete(g.upEdges, "b") },
+			"Edge a -> b has no up-edge",
+		},
+		"extra down-edge": {
+			func(g *Graph) { g.downEdges["b"] = Set{"a": "a"} },
+			"Down-edge from hashcode b to a has no edge",
+		},
+		"not an edge": {
+			func(g *Graph) { g.edges.Add("a") },
+			"Edge set holds string, which is not an Edge",
+		},
+		"nil vertex": {
+			func(g *Graph) { g.vertices["x"] = nil },
+			"Nil vertex stored under hashcode x",
+		},
+		"wrong hashcode": {
+			func(g *Graph) { g.vertices["x"] = "y" },
+			"Vertex y stored under hashcode x, but has hashcode y",
+		},
+		"stale name": {
+			func(g *Graph) {
+				g.VertexByName("a")
+				g.vertices.Delete("a")
+			},
+			`Name index holds a under "a", which is not a vertex of the graph`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var g Graph
+			g.Add("a")
+			g.Add("b")
+			g.Connect(BasicEdge("a", "b"))
+			tc.Break(&g)
+
+			err := CheckInvariants(&g)
+			if err == nil || !strings.Contains(err.Error(), tc.Error) {
+				t.Fatalf("expected an error containing %q, got: %v", tc.Error, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package dag
+
+import "sort"
+
+// Iterator steps through the vertices of a graph one at a time, in the order
+// of a depth-first or breadth-first walk, so that callers can stop early or
+// interleave the walk with other work without a callback. It is created by
+// AcyclicGraph.DFS or AcyclicGraph.BFS.
+//
+// The graph must not be changed while an Iterator is in use.
+type Iterator struct {
+	g     *AcyclicGraph
+	bfs   bool
+	queue []*vertexAtDepth
+	seen  map[interface{}]struct{}
+
+	current *vertexAtDepth
+}
+
+// DFS returns an Iterator over the vertices reachable from start by
+// following edges down the graph, in depth-first order. Each vertex is
+// visited once, before its dependencies, which are visited in order of
+// VertexName.
+//
+//	for it := g.DFS(start); it.Next(); {
+//		fmt.Println(VertexName(it.Vertex()), it.Depth())
+//	}
+func (g *AcyclicGraph) DFS(start []Vertex) *Iterator {
+	return newIterator(g, start, false)
+}
+
+// BFS returns an Iterator over the vertices reachable from start by
+// following edges down the graph, in breadth-first order. Each vertex is
+// visited once, at its shallowest depth, and the vertices at each depth are
+// visited in the order their dependents were, then by VertexName.
+func (g *AcyclicGraph) BFS(start []Vertex) *Iterator {
+	return newIterator(g, start, true)
+}
+
+func newIterator(g *AcyclicGraph, start []Vertex, bfs bool) *Iterator {
+	it := &Iterator{
+		g:    g,
+		bfs:  bfs,
+		seen: make(map[interface{}]struct{}),
+	}
+	it.push(start, 0)
+	return it
+}
+
+// Next advances to the next vertex, returning false once there are no more.
+func (it *Iterator) Next() bool {
+	for len(it.queue) > 0 {
+		var current *vertexAtDepth
+		if it.bfs {
+			current = it.queue[0]
+			it.queue = it.queue[1:]
+		} else {
+			n := len(it.queue)
+			current = it.queue[n-1]
+			it.queue = it.queue[:n-1]
+		}
+
+		if _, ok := it.seen[hashcode(current.Vertex)]; ok {
+			continue
+		}
+		it.seen[hashcode(current.Vertex)] = struct{}{}
+
+		targets := AsVertexList(it.g.downEdgesNoCopy(current.Vertex))
+		sort.Sort(byVertexName(targets))
+		it.push(targets, current.Depth+1)
+
+		it.current = current
+		return true
+	}
+
+	it.current = nil
+	return false
+}
+
+// Vertex returns the current vertex, or nil before the first call to Next
+// and after Next returns false.
+func (it *Iterator) Vertex() Vertex {
+	if it.current == nil {
+		return nil
+	}
+	return it.current.Vertex
+}
+
+// Depth returns the distance of the current vertex from the start of the
+// walk, in the order it was reached.
+func (it *Iterator) Depth() int {
+	if it.current == nil {
+		return 0
+	}
+	return it.current.Depth
+}
+
+// Seq returns a function which yields the remaining vertices, for use as an
+// iter.Seq[Vertex] with Go 1.23 or later:
+//
+//	for v := range g.DFS(start).Seq() {
+//		...
+//	}
+func (it *Iterator) Seq() func(yield func(Vertex) bool) {
+	return func(yield func(Vertex) bool) {
+		for it.Next() {
+			if !yield(it.Vertex()) {
+				return
+			}
+		}
+	}
+}
+
+// push adds vs to be visited at the given depth, so that they are visited
+// in the order given.
+func (it *Iterator) push(vs []Vertex, depth int) {
+	if it.bfs {
+		for _, v := range vs {
+			it.queue = append(it.queue, &vertexAtDepth{Vertex: v, Depth: depth})
+		}
+		return
+	}
+
+	// the last vertex pushed is visited first
+	for i := len(vs) - 1; i >= 0; i-- {
+		it.queue = append(it.queue, &vertexAtDepth{Vertex: vs[i], Depth: depth})
+	}
+}
@@ -0,0 +1,67 @@
+package dag
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func testIteratorGraph() *AcyclicGraph {
+	var g AcyclicGraph
+	for _, v := range []string{"a", "b", "c", "d", "e"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("a", "c"))
+	g.Connect(BasicEdge("a", "b"))
+	g.Connect(BasicEdge("b", "d"))
+	g.Connect(BasicEdge("c", "d"))
+	g.Connect(BasicEdge("d", "e"))
+	return &g
+}
+
+func testIteratorVisits(it *Iterator) []string {
+	var visits []string
+	for it.Next() {
+		visits = append(visits, fmt.Sprintf("%s:%d", VertexName(it.Vertex()), it.Depth()))
+	}
+	return visits
+}
+
+func TestAcyclicGraphDFS(t *testing.T) {
+	g := testIteratorGraph()
+
+	actual := testIteratorVisits(g.DFS([]Vertex{"a"}))
+	expected := []string{"a:0", "b:1", "d:2", "e:3", "c:1"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestAcyclicGraphBFS(t *testing.T) {
+	g := testIteratorGraph()
+
+	actual := testIteratorVisits(g.BFS([]Vertex{"a"}))
+	expected := []string{"a:0", "b:1", "c:1", "d:2", "e:3"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestIterator_stopEarly(t *testing.T) {
+	g := testIteratorGraph()
+
+	it := g.BFS([]Vertex{"a"})
+	var visits []Vertex
+	it.Seq()(func(v Vertex) bool {
+		visits = append(visits, v)
+		return v != "b"
+	})
+	if !reflect.DeepEqual(visits, []Vertex{"a", "b"}) {
+		t.Fatalf("bad: %#v", visits)
+	}
+
+	// the iterator carries on from where it stopped
+	if !it.Next() || it.Vertex() != "c" {
+		t.Fatalf("bad: %#v", it.Vertex())
+	}
+}
@@ -0,0 +1,62 @@
+package dag
+
+// Map returns a new graph with every vertex replaced by the result of calling
+// f on it, and each edge re-created between the mapped vertices, keeping its
+// weight, label and attributes. f is called once for each vertex. Vertices
+// which map to the same vertex are merged, and their attributes combined.
+//
+// Complexity: O(V+E)
+func (g *Graph) Map(f func(Vertex) Vertex) *Graph {
+	result := &Graph{}
+	result.init()
+
+	mapped := make(map[interface{}]Vertex, len(g.vertices))
+	for _, v := range g.vertices {
+		m := f(v)
+		mapped[hashcode(v)] = m
+		result.Add(m)
+		g.copyVertexAttrs(result, v, m)
+	}
+
+	for _, raw := range g.edges {
+		e := raw.(Edge)
+		result.Connect(copyEdge(e, mapped[hashcode(e.Source())], mapped[hashcode(e.Target())]))
+	}
+
+	return result
+}
+
+// Map is like Graph.Map, returning an AcyclicGraph. Merging vertices may
+// create cycles, which can be found with Validate.
+func (g *AcyclicGraph) Map(f func(Vertex) Vertex) *AcyclicGraph {
+	return &AcyclicGraph{Graph: *g.Graph.Map(f)}
+}
+
+// MapEdges returns a new graph with the same vertices, and every edge
+// replaced by the result of calling f on it. Edges for which f returns nil
+// are dropped. The edges returned by f should connect vertices within the
+// graph.
+//
+// Complexity: O(V+E)
+func (g *Graph) MapEdges(f func(Edge) Edge) *Graph {
+	result := &Graph{}
+	result.init()
+
+	for _, v := range g.vertices {
+		result.Add(v)
+		g.copyVertexAttrs(result, v, v)
+	}
+
+	for _, raw := range g.edges {
+		if e := f(raw.(Edge)); e != nil {
+			result.Connect(e)
+		}
+	}
+
+	return result
+}
+
+// MapEdges is like Graph.MapEdges, returning an AcyclicGraph.
+func (g *AcyclicGraph) MapEdges(f func(Edge) Edge) *AcyclicGraph {
+	return &AcyclicGraph{Graph: *g.Graph.MapEdges(f)}
+}
@@ -0,0 +1,89 @@
+package dag
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestGraphMap(t *testing.T) {
+	var g Graph
+	for i := 1; i <= 4; i++ {
+		g.Add(i)
+	}
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicWeightedEdge(2, 3, 5))
+	g.Connect(BasicEdge(3, 4))
+	g.SetVertexAttr(2, "owner", "team-a")
+
+	calls := 0
+	m := g.Map(func(v Vertex) Vertex {
+		calls++
+		if v == 4 {
+			// merged with 3
+			return "v3"
+		}
+		return fmt.Sprintf("v%d", v)
+	})
+	if calls != 4 {
+		t.Fatalf("f called %d times", calls)
+	}
+
+	actual := strings.TrimSpace(m.String())
+	expected := strings.TrimSpace(testGraphMapStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+	if m.VertexAttrs("v2")["owner"] != "team-a" {
+		t.Fatal("mapped vertex lost its attributes")
+	}
+	if w := edgeWeight(m.EdgesBetween("v2", "v3")[0]); w != 5 {
+		t.Fatalf("mapped edge lost its weight: %v", w)
+	}
+
+	// the original is unchanged
+	if !g.HasEdge(BasicEdge(3, 4)) {
+		t.Fatal("original graph was modified")
+	}
+}
+
+func TestGraphMapEdges(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+
+	m := g.MapEdges(func(e Edge) Edge {
+		if e.Source() == 1 {
+			return nil
+		}
+		return BasicLabeledEdge(e.Source(), e.Target(), "runtime")
+	})
+
+	actual := strings.TrimSpace(m.String())
+	expected := strings.TrimSpace(testGraphMapEdgesStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+	if es := m.EdgesBetween(2, 3); len(es) != 1 || edgeLabel(es[0]) != "runtime" {
+		t.Fatalf("bad edges: %#v", es)
+	}
+}
+
+const testGraphMapStr = `
+v1
+  v2
+v2
+  v3
+v3
+  v3
+`
+
+const testGraphMapEdgesStr = `
+1
+2
+  3
+3
+`
@@ -1,14 +1,24 @@
 package dag
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 )
 
-// the marshal* structs are for serialization of the graph data.
-type marshalGraph struct {
+// The Marshal* structs are for serialization of the graph data. They can be
+// obtained with Graph.Marshal, to be inspected or filtered before rendering
+// with Dot or Mermaid, or by a custom renderer.
+
+// MarshalGraph is the serialized form of a graph, or of a subgraph.
+type MarshalGraph struct {
 	// Type is always "Graph", for identification as a top level object in the
 	// JSON stream.
 	Type string
@@ -24,20 +34,27 @@ type marshalGraph struct {
 	Attrs map[string]string `json:",omitempty"`
 
 	// List of graph vertices, sorted by ID.
-	Vertices []*marshalVertex `json:",omitempty"`
+	Vertices []*MarshalVertex `json:",omitempty"`
 
 	// List of edges, sorted by Source ID.
-	Edges []*marshalEdge `json:",omitempty"`
+	Edges []*MarshalEdge `json:",omitempty"`
 
 	// Any number of subgraphs. A subgraph itself is considered a vertex, and
 	// may be referenced by either end of an edge.
-	Subgraphs []*marshalGraph `json:",omitempty"`
+	Subgraphs []*MarshalGraph `json:",omitempty"`
 
 	// Any lists of vertices that are included in cycles.
-	Cycles [][]*marshalVertex `json:",omitempty"`
+	Cycles [][]*MarshalVertex `json:",omitempty"`
+
+	// Problems found while building this graph or its subgraphs, such as a
+	// Subgrapher without a graph. The affected vertices are still included,
+	// as ordinary vertices.
+	diags Diagnostics
 }
 
-func (g *marshalGraph) vertexByID(id string) *marshalVertex {
+// VertexByID returns the vertex in this graph with the given ID, not
+// including the vertices of any subgraphs, or nil if there is none.
+func (g *MarshalGraph) VertexByID(id string) *MarshalVertex {
 	for _, v := range g.Vertices {
 		if id == v.ID {
 			return v
@@ -46,7 +63,8 @@ func (g *marshalGraph) vertexByID(id string) *marshalVertex {
 	return nil
 }
 
-type marshalVertex struct {
+// MarshalVertex is the serialized form of a vertex.
+type MarshalVertex struct {
 	// Unique ID, used to reference this vertex from other structures.
 	ID string
 
@@ -60,7 +78,7 @@ type marshalVertex struct {
 	graphNodeDotter GraphNodeDotter
 }
 
-func newMarshalVertex(v Vertex) *marshalVertex {
+func newMarshalVertex(v Vertex, id func(Vertex) string) *MarshalVertex {
 	dn, ok := v.(GraphNodeDotter)
 	if !ok {
 		dn = nil
@@ -71,22 +89,37 @@ func newMarshalVertex(v Vertex) *marshalVertex {
 	name := strconv.Quote(VertexName(v))
 	name = name[1 : len(name)-1]
 
-	return &marshalVertex{
-		ID:              marshalVertexID(v),
+	return &MarshalVertex{
+		ID:              id(v),
 		Name:            name,
 		Attrs:           make(map[string]string),
 		graphNodeDotter: dn,
 	}
 }
 
+// marshaledName returns the original name of a vertex from the name in its
+// MarshalVertex, which is escaped for dot.
+func marshaledName(name string) string {
+	if unquoted, err := strconv.Unquote(`"` + name + `"`); err == nil {
+		return unquoted
+	}
+	return name
+}
+
 // vertices is a sort.Interface implementation for sorting vertices by ID
-type vertices []*marshalVertex
+type vertices []*MarshalVertex
 
-func (v vertices) Less(i, j int) bool { return v[i].Name < v[j].Name }
-func (v vertices) Len() int           { return len(v) }
-func (v vertices) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v vertices) Less(i, j int) bool {
+	if v[i].Name != v[j].Name {
+		return v[i].Name < v[j].Name
+	}
+	return v[i].ID < v[j].ID
+}
+func (v vertices) Len() int      { return len(v) }
+func (v vertices) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
 
-type marshalEdge struct {
+// MarshalEdge is the serialized form of an edge.
+type MarshalEdge struct {
 	// Human readable name
 	Name string
 
@@ -94,57 +127,140 @@ type marshalEdge struct {
 	Source string
 	Target string
 
+	// Label of a LabeledEdge, telling it apart from other edges between the
+	// same vertices.
+	Label string `json:",omitempty"`
+
+	// Weight of a WeightedEdge, such as a cost or duration used for
+	// scheduling. It is omitted for edges with the default weight of 1.
+	Weight *float64 `json:",omitempty"`
+
 	Attrs map[string]string `json:",omitempty"`
+
+	// Like graphNodeDotter on vertices, we record if the edge was a
+	// GraphEdgeDotter here, so we can call it to get attributes.
+	graphEdgeDotter GraphEdgeDotter
 }
 
-func newMarshalEdge(e Edge) *marshalEdge {
-	return &marshalEdge{
-		Name:   fmt.Sprintf("%s|%s", VertexName(e.Source()), VertexName(e.Target())),
-		Source: marshalVertexID(e.Source()),
-		Target: marshalVertexID(e.Target()),
-		Attrs:  make(map[string]string),
+func newMarshalEdge(e Edge, id func(Vertex) string) *MarshalEdge {
+	ed, ok := e.(GraphEdgeDotter)
+	if !ok {
+		ed = nil
+	}
+
+	attrs := make(map[string]string)
+	if ae, ok := e.(AttrEdge); ok {
+		for k, v := range ae.Attrs() {
+			attrs[k] = v
+		}
+	}
+
+	var weight *float64
+	if w := edgeWeight(e); w != 1 {
+		weight = &w
+	}
+
+	return &MarshalEdge{
+		Name:            fmt.Sprintf("%s|%s", VertexName(e.Source()), VertexName(e.Target())),
+		Source:          id(e.Source()),
+		Target:          id(e.Target()),
+		Label:           edgeLabel(e),
+		Weight:          weight,
+		Attrs:           attrs,
+		graphEdgeDotter: ed,
 	}
 }
 
 // edges is a sort.Interface implementation for sorting edges by Source ID
-type edges []*marshalEdge
+type edges []*MarshalEdge
+
+func (e edges) Less(i, j int) bool {
+	if e[i].Name != e[j].Name {
+		return e[i].Name < e[j].Name
+	}
+	if e[i].Source != e[j].Source {
+		return e[i].Source < e[j].Source
+	}
+	if e[i].Target != e[j].Target {
+		return e[i].Target < e[j].Target
+	}
+	return e[i].Label < e[j].Label
+}
+func (e edges) Len() int      { return len(e) }
+func (e edges) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+
+// MarshalOpts are the options for marshaling a graph.
+type MarshalOpts struct {
+	// StableIDs derives vertex IDs from a hash of the vertex name, rather
+	// than from the vertex itself, which for pointers is its address. This
+	// makes the output identical each time the same graph is marshaled, so
+	// the output of different runs can be compared.
+	//
+	// Vertices with the same name in the same graph are told apart with a
+	// numeric suffix, ordered by the names of the vertices they are connected
+	// to. If those are the same too, which vertex gets which suffix may vary.
+	StableIDs bool
+}
 
-func (e edges) Less(i, j int) bool { return e[i].Name < e[j].Name }
-func (e edges) Len() int           { return len(e) }
-func (e edges) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+// build a MarshalGraph structure from a *Graph
+func newMarshalGraph(name string, g *Graph, opts *MarshalOpts) *MarshalGraph {
+	if opts == nil {
+		opts = &MarshalOpts{}
+	}
 
-// build a marshalGraph structure from a *Graph
-func newMarshalGraph(name string, g *Graph) *marshalGraph {
-	mg := &marshalGraph{
+	mg := &MarshalGraph{
 		Type:  "Graph",
 		Name:  name,
 		Attrs: make(map[string]string),
 	}
 
+	vertexID := marshalVertexID
+	if opts.StableIDs {
+		vertexID = stableVertexIDs(g)
+	}
+
+	virtual := func(v Vertex) bool {
+		return g.vertexAttrs[hashcode(v)][VirtualAttr] != ""
+	}
+
 	for _, v := range g.Vertices() {
-		id := marshalVertexID(v)
+		if virtual(v) {
+			continue
+		}
+
+		id := vertexID(v)
 		if sg, ok := marshalSubgrapher(v); ok {
-			smg := newMarshalGraph(VertexName(v), sg)
+			smg := newMarshalGraph(VertexName(v), sg, opts)
 			smg.ID = id
 			mg.Subgraphs = append(mg.Subgraphs, smg)
+			mg.diags = mg.diags.Append(smg.diags)
+		} else if _, ok := v.(Subgrapher); ok {
+			mg.diags = mg.diags.Append(fmt.Errorf(
+				"subgraph %q has no *Graph or *AcyclicGraph to marshal", VertexName(v)))
 		}
 
-		mv := newMarshalVertex(v)
+		mv := newMarshalVertex(v, vertexID)
+		for k, val := range g.vertexAttrs[hashcode(v)] {
+			mv.Attrs[k] = val
+		}
 		mg.Vertices = append(mg.Vertices, mv)
 	}
 
 	sort.Sort(vertices(mg.Vertices))
 
 	for _, e := range g.Edges() {
-		mg.Edges = append(mg.Edges, newMarshalEdge(e))
+		if virtual(e.Source()) || virtual(e.Target()) {
+			continue
+		}
+		mg.Edges = append(mg.Edges, newMarshalEdge(e, vertexID))
 	}
 
 	sort.Sort(edges(mg.Edges))
 
 	for _, c := range (&AcyclicGraph{*g}).Cycles() {
-		var cycle []*marshalVertex
+		var cycle []*MarshalVertex
 		for _, v := range c {
-			mv := newMarshalVertex(v)
+			mv := newMarshalVertex(v, vertexID)
 			cycle = append(cycle, mv)
 		}
 		mg.Cycles = append(mg.Cycles, cycle)
@@ -153,6 +269,57 @@ func newMarshalGraph(name string, g *Graph) *marshalGraph {
 	return mg
 }
 
+// stableVertexIDs returns a function giving the ID of each vertex in g,
+// derived from a hash of the vertex name.
+func stableVertexIDs(g *Graph) func(Vertex) string {
+	// Vertices with the same name are ordered by the names of their
+	// neighbours, so they get the same suffix each time if they can be
+	// told apart at all.
+	vs := g.Vertices()
+	keys := make(map[interface{}]string, len(vs))
+	for _, v := range vs {
+		keys[hashcode(v)] = VertexName(v) + "\x00" +
+			sortedNames(g.downEdgesNoCopy(v)) + "\x00" +
+			sortedNames(g.upEdgesNoCopy(v))
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		return keys[hashcode(vs[i])] < keys[hashcode(vs[j])]
+	})
+
+	ids := make(map[interface{}]string, len(vs))
+	used := make(map[string]bool, len(vs))
+	for _, v := range vs {
+		sum := sha256.Sum256([]byte(VertexName(v)))
+		base := hex.EncodeToString(sum[:8])
+
+		id := base
+		for i := 1; used[id]; i++ {
+			id = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[id] = true
+		ids[hashcode(v)] = id
+	}
+
+	return func(v Vertex) string {
+		if id, ok := ids[hashcode(v)]; ok {
+			return id
+		}
+		// edges may refer to vertices which aren't in the graph
+		return marshalVertexID(v)
+	}
+}
+
+// sortedNames returns the sorted names of the vertices in s, joined with
+// newlines.
+func sortedNames(s Set) string {
+	names := make([]string, 0, len(s))
+	for _, v := range s {
+		names = append(names, VertexName(v))
+	}
+	sort.Strings(names)
+	return strings.Join(names, "\n")
+}
+
 // Attempt to return a unique ID for any vertex.
 func marshalVertexID(v Vertex) string {
 	val := reflect.ValueOf(v)
@@ -179,19 +346,290 @@ func marshalVertexID(v Vertex) string {
 	// interface, but we shouldn't get here from terraform right now.
 }
 
-// check for a Subgrapher, and return the underlying *Graph.
+// check for a Subgrapher, and return the underlying *Graph. A Subgrapher
+// without a graph, or with a Grapher of another type, isn't treated as a
+// subgraph.
 func marshalSubgrapher(v Vertex) (*Graph, bool) {
 	sg, ok := v.(Subgrapher)
-	if !ok {
+	if !ok || sg.Subgraph() == nil {
 		return nil, false
 	}
 
 	switch g := sg.Subgraph().DirectedGraph().(type) {
 	case *Graph:
-		return g, true
+		return g, g != nil
 	case *AcyclicGraph:
+		if g == nil {
+			return nil, false
+		}
 		return &g.Graph, true
 	}
 
 	return nil, false
 }
+
+// MarshalJSON encodes the graph, including any subgraphs, as JSON. The result
+// can be read back with ParseJSON.
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	mg, err := g.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mg)
+}
+
+// WriteJSON writes the same JSON as MarshalJSON to w, encoding one vertex
+// or edge at a time rather than buffering the whole document. The graph is
+// still converted to a MarshalGraph first, so memory use grows with the size
+// of the graph, though not with the size of its output. The first error from
+// w is returned.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	mg, err := g.Marshal()
+	if err != nil {
+		return err
+	}
+	return mg.WriteJSON(w)
+}
+
+// WriteJSON writes the graph to w as JSON, in the same form as encoding the
+// MarshalGraph with encoding/json, but encoding one vertex or edge at a time
+// rather than building the whole document in memory.
+func (g *MarshalGraph) WriteJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := g.writeJSON(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeJSON writes the fields of the graph in the order and with the
+// omissions of its struct tags. Write errors are left in w, to be returned
+// by Flush.
+func (g *MarshalGraph) writeJSON(w *bufio.Writer) error {
+	value := func(v interface{}) error {
+		js, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.Write(js)
+		return nil
+	}
+
+	w.WriteString(`{"Type":`)
+	if err := value(g.Type); err != nil {
+		return err
+	}
+	if g.ID != "" {
+		w.WriteString(`,"ID":`)
+		if err := value(g.ID); err != nil {
+			return err
+		}
+	}
+	if g.Name != "" {
+		w.WriteString(`,"Name":`)
+		if err := value(g.Name); err != nil {
+			return err
+		}
+	}
+	if len(g.Attrs) > 0 {
+		w.WriteString(`,"Attrs":`)
+		if err := value(g.Attrs); err != nil {
+			return err
+		}
+	}
+
+	if len(g.Vertices) > 0 {
+		w.WriteString(`,"Vertices":[`)
+		for i, v := range g.Vertices {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := value(v); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	}
+	if len(g.Edges) > 0 {
+		w.WriteString(`,"Edges":[`)
+		for i, e := range g.Edges {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := value(e); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	}
+	if len(g.Subgraphs) > 0 {
+		w.WriteString(`,"Subgraphs":[`)
+		for i, sg := range g.Subgraphs {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := sg.writeJSON(w); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	}
+	if len(g.Cycles) > 0 {
+		w.WriteString(`,"Cycles":`)
+		if err := value(g.Cycles); err != nil {
+			return err
+		}
+	}
+
+	w.WriteByte('}')
+	return nil
+}
+
+// Marshal returns the serialized structure of the graph, including any
+// subgraphs, which is what the JSON, dot and Mermaid outputs are built from.
+// Vertices and edges are sorted by name.
+//
+// An error is returned if any Subgrapher, at any depth, doesn't provide a
+// *Graph or *AcyclicGraph, since its subgraph could not be read back.
+func (g *Graph) Marshal() (*MarshalGraph, error) {
+	return g.MarshalWithOpts(nil)
+}
+
+// MarshalWithOpts is like Marshal, with options to control the output. The
+// result can be encoded with encoding/json to get the same format as
+// MarshalJSON.
+func (g *Graph) MarshalWithOpts(opts *MarshalOpts) (*MarshalGraph, error) {
+	mg := newMarshalGraph("", g, opts)
+	if err := mg.diags.Err(); err != nil {
+		return nil, err
+	}
+	return mg, nil
+}
+
+// UnmarshalJSON replaces the contents of the graph with the JSON produced by
+// MarshalJSON. Vertices are reconstructed as *ParsedVertex, or as
+// *ParsedSubgraph when they contained a subgraph, and their attributes are
+// also set with SetVertexAttr. Edges with attributes are reconstructed as an
+// AttrEdge, and edges with a weight as a WeightedEdge.
+func (g *Graph) UnmarshalJSON(data []byte) error {
+	var mg MarshalGraph
+	if err := json.Unmarshal(data, &mg); err != nil {
+		return err
+	}
+
+	parsed, err := mg.graph()
+	if err != nil {
+		return err
+	}
+
+	*g = *parsed
+	return nil
+}
+
+// ParseJSON reads a graph in the format produced by MarshalJSON.
+func ParseJSON(r io.Reader) (*Graph, error) {
+	var g Graph
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// ParsedVertex is a Vertex reconstructed from serialized graph output. It
+// retains the ID, name and attributes that were recorded for the original
+// vertex.
+type ParsedVertex struct {
+	ID    string
+	Name  string
+	Attrs map[string]string
+}
+
+func (v *ParsedVertex) String() string {
+	return v.Name
+}
+
+// ParsedSubgraph is a ParsedVertex that contained a subgraph.
+type ParsedSubgraph struct {
+	*ParsedVertex
+	Graph *Graph
+}
+
+func (v *ParsedSubgraph) Subgraph() Grapher {
+	return v.Graph
+}
+
+// CloneVertex implements VertexCloner, so that cloning a parsed graph also
+// clones its subgraphs.
+func (v *ParsedSubgraph) CloneVertex() Vertex {
+	return &ParsedSubgraph{ParsedVertex: v.ParsedVertex, Graph: v.Graph.Clone()}
+}
+
+// graph rebuilds a *Graph from the marshaled structure, recursing into any
+// subgraphs.
+func (mg *MarshalGraph) graph() (*Graph, error) {
+	if mg.Type != "Graph" {
+		return nil, fmt.Errorf("unexpected type %q, expected \"Graph\"", mg.Type)
+	}
+
+	subgraphs := make(map[string]*MarshalGraph, len(mg.Subgraphs))
+	for _, sg := range mg.Subgraphs {
+		subgraphs[sg.ID] = sg
+	}
+
+	g := &Graph{}
+	g.init()
+
+	byID := make(map[string]Vertex, len(mg.Vertices))
+	for _, mv := range mg.Vertices {
+		if _, ok := byID[mv.ID]; ok {
+			return nil, fmt.Errorf("duplicate vertex ID %q", mv.ID)
+		}
+
+		// names were escaped when marshaling, so that they could be quoted
+		// again for the dot output.
+		name, err := strconv.Unquote(`"` + mv.Name + `"`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name for vertex %q: %s", mv.ID, err)
+		}
+
+		pv := &ParsedVertex{
+			ID:    mv.ID,
+			Name:  name,
+			Attrs: mv.Attrs,
+		}
+
+		var v Vertex = pv
+		if smg, ok := subgraphs[mv.ID]; ok {
+			sg, err := smg.graph()
+			if err != nil {
+				return nil, fmt.Errorf("subgraph %q: %s", name, err)
+			}
+			v = &ParsedSubgraph{ParsedVertex: pv, Graph: sg}
+		}
+
+		byID[mv.ID] = v
+		g.Add(v)
+		for k, val := range mv.Attrs {
+			g.SetVertexAttr(v, k, val)
+		}
+	}
+
+	for _, me := range mg.Edges {
+		source, ok := byID[me.Source]
+		if !ok {
+			return nil, fmt.Errorf("edge %q references unknown vertex %q", me.Name, me.Source)
+		}
+		target, ok := byID[me.Target]
+		if !ok {
+			return nil, fmt.Errorf("edge %q references unknown vertex %q", me.Name, me.Target)
+		}
+
+		var weight float64
+		if me.Weight != nil {
+			weight = *me.Weight
+		}
+		g.Connect(rebuildEdge(source, target, me.Label, weight, me.Weight != nil, me.Attrs))
+	}
+
+	return g, nil
+}
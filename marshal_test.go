@@ -1,6 +1,11 @@
 package dag
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -63,6 +68,410 @@ func TestGraphDot_attrs(t *testing.T) {
 	}
 }
 
+func TestGraphDot_vertexAttrs(t *testing.T) {
+	var g Graph
+	g.Add(&testGraphNodeDotter{
+		Result: &DotNode{
+			Name:  "foo",
+			Attrs: map[string]string{"foo": "bar"},
+		},
+	})
+	g.Add("baz")
+	g.SetVertexAttr("baz", "shape", "box")
+	for _, v := range g.Vertices() {
+		g.SetVertexAttr(v, "color", "blue")
+	}
+
+	actual := strings.TrimSpace(string(g.Dot(nil)))
+	expected := strings.TrimSpace(testGraphDotVertexAttrsStr)
+	if actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	mg, err := g.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range mg.Vertices {
+		if v.Attrs["color"] != "blue" {
+			t.Fatalf("missing attribute on %s: %#v", v.Name, v.Attrs)
+		}
+	}
+}
+
+func TestGraphJSON_roundTrip(t *testing.T) {
+	var sub AcyclicGraph
+	sub.Add("x")
+	sub.Add("y")
+	sub.Connect(BasicEdge("x", "y"))
+
+	var g Graph
+	quoted := `name["with-quotes"]`
+	g.Add(1)
+	g.Add(quoted)
+	g.Add(&testSubgrapher{name: "sub", graph: &sub})
+	g.Connect(BasicEdge(1, quoted))
+
+	js, err := json.Marshal(&g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseJSON(bytes.NewReader(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.String() != g.String() {
+		t.Fatalf("got:\n%s\nwant:\n%s", parsed.String(), g.String())
+	}
+
+	var sg *ParsedSubgraph
+	for _, v := range parsed.Vertices() {
+		if v, ok := v.(*ParsedSubgraph); ok {
+			sg = v
+		}
+	}
+	if sg == nil {
+		t.Fatal("subgraph not found")
+	}
+	if sg.Name != "sub" {
+		t.Fatalf("wrong subgraph name %q", sg.Name)
+	}
+	if sg.Graph.String() != sub.String() {
+		t.Fatalf("got:\n%s\nwant:\n%s", sg.Graph.String(), sub.String())
+	}
+}
+
+func TestParseJSON_unknownVertex(t *testing.T) {
+	js := `{"Type":"Graph","Vertices":[{"ID":"1","Name":"1"}],"Edges":[{"Name":"1|2","Source":"1","Target":"2"}]}`
+	if _, err := ParseJSON(strings.NewReader(js)); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestGraphJSON_edgeAttrs(t *testing.T) {
+	var g Graph
+	g.Add("a")
+	g.Add("b")
+	g.Connect(BasicAttrEdge("a", "b", map[string]string{"label": "depends_on"}))
+
+	js, err := json.Marshal(&g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseJSON(bytes.NewReader(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edges := parsed.Edges()
+	if len(edges) != 1 {
+		t.Fatalf("expected 1 edge, got: %#v", edges)
+	}
+	ae, ok := edges[0].(AttrEdge)
+	if !ok {
+		t.Fatalf("expected an AttrEdge, got: %#v", edges[0])
+	}
+	if ae.Attrs()["label"] != "depends_on" {
+		t.Fatalf("bad attrs: %#v", ae.Attrs())
+	}
+}
+
+func TestGraphJSON_labeledEdges(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Connect(BasicLabeledEdge(1, 2, "build"))
+	g.Connect(BasicLabeledEdge(1, 2, "runtime"))
+
+	js, err := g.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseJSON(bytes.NewReader(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var labels []string
+	for _, e := range parsed.Edges() {
+		labels = append(labels, edgeLabel(e))
+	}
+	sort.Strings(labels)
+	if !reflect.DeepEqual(labels, []string{"build", "runtime"}) {
+		t.Fatalf("bad labels: %#v", labels)
+	}
+
+	actual := strings.TrimSpace(string(g.Dot(nil)))
+	expected := strings.TrimSpace(testGraphDotLabeledEdgesStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+func TestGraphJSON_weights(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Connect(BasicWeightedEdge(1, 2, 2.5))
+	g.Connect(BasicWeightedEdge(1, 3, 0))
+	g.Connect(BasicLabeledEdge(2, 4, "build"))
+	g.Connect(BasicLabeledEdge(3, 4, "run"))
+	g.Connect(&basicLabeledEdge{basicEdge: basicEdge{S: 2, T: 3}, L: "slow", W: 10})
+
+	js, err := g.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseJSON(bytes.NewReader(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weights := make(map[string]float64)
+	for _, e := range parsed.Edges() {
+		if _, ok := e.(WeightedEdge); !ok && edgeWeight(e) != 1 {
+			t.Fatalf("expected a WeightedEdge, got %#v", e)
+		}
+		weights[VertexName(e.Source())+"-"+VertexName(e.Target())+"-"+edgeLabel(e)] = edgeWeight(e)
+	}
+	expected := map[string]float64{
+		"1-2-":      2.5,
+		"1-3-":      0,
+		"2-4-build": 1,
+		"3-4-run":   1,
+		"2-3-slow":  10,
+	}
+	if !reflect.DeepEqual(weights, expected) {
+		t.Fatalf("bad weights: %#v", weights)
+	}
+
+	actual := strings.TrimSpace(string(g.Dot(nil)))
+	if actual != strings.TrimSpace(testGraphDotWeightsStr) {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+func TestGraphDot_nestedSubgraphs(t *testing.T) {
+	g := testNestedSubgraphs()
+
+	actual := strings.TrimSpace(string(g.Dot(&DotOpts{MaxDepth: -1})))
+	expected := strings.TrimSpace(testGraphDotNestedStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+
+	parsed, err := ParseDot(strings.NewReader(actual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCheckNestedSubgraphs(t, parsed)
+}
+
+func TestGraphJSON_nestedSubgraphs(t *testing.T) {
+	g := testNestedSubgraphs()
+
+	js, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseJSON(bytes.NewReader(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCheckNestedSubgraphs(t, parsed)
+}
+
+// testNestedSubgraphs returns a graph containing the subgraph "sub", which
+// itself contains the subgraph "inner".
+func TestGraphWriteJSON(t *testing.T) {
+	g := testNestedSubgraphs()
+	g.Add("<b>")
+	g.Add("c")
+	g.Connect(BasicWeightedEdge("<b>", "c", 2))
+	g.Connect(BasicLabeledEdge("c", "a", "uses"))
+	g.SetVertexAttr("c", "owner", "team-a")
+
+	expected, err := g.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if actual := buf.String(); actual != string(expected) {
+		t.Fatalf("bad:\n%s\n\nexpected:\n%s", actual, expected)
+	}
+
+	if err := g.WriteJSON(testFailingWriter{}); err != errTestWrite {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+}
+
+// testFailingWriter is an io.Writer which always fails with errTestWrite.
+type testFailingWriter struct{}
+
+var errTestWrite = errors.New("write failed")
+
+func (testFailingWriter) Write([]byte) (int, error) { return 0, errTestWrite }
+
+func testNestedSubgraphs() *Graph {
+	inner := &Graph{}
+	inner.Add("i1")
+	inner.Add("i2")
+	inner.Connect(BasicEdge("i1", "i2"))
+
+	sub := &Graph{}
+	sub.Add("s1")
+	iv := sub.Add(&testSubgrapher{name: "inner", graph: inner})
+	sub.Connect(BasicEdge("s1", iv))
+
+	g := &Graph{}
+	g.Add("a")
+	sv := g.Add(&testSubgrapher{name: "sub", graph: sub})
+	g.Connect(BasicEdge("a", sv))
+	return g
+}
+
+// testCheckNestedSubgraphs checks a parsed copy of testNestedSubgraphs.
+func testCheckNestedSubgraphs(t *testing.T, g *Graph) {
+	t.Helper()
+
+	find := func(g *Graph, name string) *Graph {
+		t.Helper()
+		for _, v := range g.Vertices() {
+			if v, ok := v.(*ParsedSubgraph); ok && v.Name == name {
+				return v.Graph
+			}
+		}
+		t.Fatalf("subgraph %q not found in:\n%s", name, g.String())
+		return nil
+	}
+
+	sub := find(g, "sub")
+	inner := find(sub, "inner")
+	if actual := strings.TrimSpace(inner.String()); actual != "i1\n  i2\ni2" {
+		t.Fatalf("bad inner subgraph:\n%s", actual)
+	}
+}
+
+func TestGraphJSON_badSubgraph(t *testing.T) {
+	var sub Graph
+	sub.Add("x")
+	sub.Add(&testSubgrapher{name: "empty"})
+
+	var g Graph
+	g.Add(1)
+	g.Add(&testSubgrapher{name: "sub", graph: &sub})
+
+	_, err := json.Marshal(&g)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), `subgraph "empty"`) {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	// the dot output draws it as an ordinary vertex instead
+	g.Dot(nil)
+}
+
+func TestGraphMarshal(t *testing.T) {
+	g := testNestedSubgraphs()
+
+	mg, err := g.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mg.Vertices) != 2 || len(mg.Edges) != 1 || len(mg.Subgraphs) != 1 {
+		t.Fatalf("bad graph: %#v", mg)
+	}
+
+	e := mg.Edges[0]
+	if mg.VertexByID(e.Source).Name != "a" || mg.VertexByID(e.Target).Name != "sub" {
+		t.Fatalf("bad edge: %#v", e)
+	}
+
+	sub := mg.Subgraphs[0]
+	if sub.Name != "sub" || sub.ID != e.Target {
+		t.Fatalf("bad subgraph: %#v", sub)
+	}
+	if sub.VertexByID("s1") == nil {
+		t.Fatal("missing s1")
+	}
+
+	// filtered structures can still be rendered
+	mg.Subgraphs = nil
+	actual := strings.TrimSpace(string(mg.Dot(&DotOpts{MaxDepth: -1})))
+	if strings.Contains(actual, "cluster_sub") {
+		t.Fatalf("subgraph should be removed:\n%s", actual)
+	}
+}
+
+func TestGraphMarshal_stableIDs(t *testing.T) {
+	marshal := func() []byte {
+		t.Helper()
+
+		g := testNestedSubgraphs()
+		a, b := &ParsedVertex{Name: "dup"}, &ParsedVertex{Name: "dup"}
+		g.Add(a)
+		g.Add(b)
+		g.Connect(BasicEdge("a", a))
+
+		mg, err := g.MarshalWithOpts(&MarshalOpts{StableIDs: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		js, err := json.Marshal(mg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return js
+	}
+
+	first, second := marshal(), marshal()
+	if !bytes.Equal(first, second) {
+		t.Fatalf("output differs:\n%s\n%s", first, second)
+	}
+
+	parsed, err := ParseJSON(bytes.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Vertices()) != 4 {
+		t.Fatalf("duplicate names should get distinct IDs:\n%s", first)
+	}
+}
+
+type testSubgrapher struct {
+	name  string
+	graph Grapher
+}
+
+func (s *testSubgrapher) Name() string      { return s.name }
+func (s *testSubgrapher) Subgraph() Grapher { return s.graph }
+
+func TestGraphDot_edgeAttrs(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Connect(BasicAttrEdge(1, 2, map[string]string{"label": "notifies"}))
+
+	actual := strings.TrimSpace(string(g.Dot(nil)))
+	expected := strings.TrimSpace(testGraphDotEdgeAttrsStr)
+	if actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
 type testGraphNodeDotter struct{ Result *DotNode }
 
 func (n *testGraphNodeDotter) Name() string                      { return n.Result.Name }
@@ -99,3 +508,57 @@ const testGraphDotAttrsStr = `digraph {
 		"[root] foo" [foo = "bar"]
 	}
 }`
+
+const testGraphDotVertexAttrsStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] baz" [color = "blue", shape = "box"]
+		"[root] foo" [color = "blue", foo = "bar"]
+	}
+}`
+
+const testGraphDotLabeledEdgesStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] 1" -> "[root] 2" [label = "build"]
+		"[root] 1" -> "[root] 2" [label = "runtime"]
+	}
+}`
+
+const testGraphDotWeightsStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] 1" -> "[root] 2" [weight = "2.5"]
+		"[root] 1" -> "[root] 3" [weight = "0"]
+		"[root] 2" -> "[root] 3" [label = "slow", weight = "10"]
+		"[root] 2" -> "[root] 4" [label = "build"]
+		"[root] 3" -> "[root] 4" [label = "run"]
+	}
+}`
+
+const testGraphDotEdgeAttrsStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] 1" -> "[root] 2" [label = "notifies"]
+	}
+}`
+
+const testGraphDotNestedStr = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] a" -> "[root] sub"
+	}
+	subgraph "cluster_sub" {
+		label = "sub"
+		"[sub] s1" -> "[sub] inner"
+		subgraph "cluster_inner" {
+			label = "inner"
+			"[inner] i1" -> "[inner] i2"
+		}
+	}
+}`
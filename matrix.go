@@ -0,0 +1,84 @@
+package dag
+
+import "fmt"
+
+// AdjacencyMatrix returns the graph as an adjacency matrix, along with its
+// vertices sorted by VertexName. m[i][j] is true when there is an edge from
+// vertex i to vertex j. Edges between the same vertices with different
+// labels are a single entry.
+//
+// Complexity: O(V^2)
+func (g *Graph) AdjacencyMatrix() ([][]bool, []Vertex) {
+	vs, index := g.matrixIndex()
+
+	m := make([][]bool, len(vs))
+	for i := range m {
+		m[i] = make([]bool, len(vs))
+	}
+	for i, v := range vs {
+		for _, t := range g.downEdgesNoCopy(v) {
+			m[i][index[hashcode(t)]] = true
+		}
+	}
+
+	return m, vs
+}
+
+// AdjacencyBitset is like AdjacencyMatrix, packing the matrix into a bitset
+// of V*V bits in row-major order, which is 64 times smaller. The entry for
+// the edge from vertex i to vertex j is bit k%64 of word k/64, where
+// k = i*V + j.
+func (g *Graph) AdjacencyBitset() ([]uint64, []Vertex) {
+	vs, index := g.matrixIndex()
+	n := len(vs)
+
+	bits := make([]uint64, (n*n+63)/64)
+	for i, v := range vs {
+		for _, t := range g.downEdgesNoCopy(v) {
+			k := i*n + index[hashcode(t)]
+			bits[k/64] |= 1 << uint(k%64)
+		}
+	}
+
+	return bits, vs
+}
+
+// matrixIndex returns the vertices of the graph in the order used for their
+// rows and columns, and the index of each keyed by hashcode.
+func (g *Graph) matrixIndex() ([]Vertex, map[interface{}]int) {
+	vs := g.SortedVertices()
+	index := make(map[interface{}]int, len(vs))
+	for i, v := range vs {
+		index[hashcode(v)] = i
+	}
+	return vs, index
+}
+
+// FromAdjacencyMatrix builds a graph from an adjacency matrix in the form
+// returned by AdjacencyMatrix, with a BasicEdge from vs[i] to vs[j] wherever
+// m[i][j] is true. An error is returned if m isn't a square matrix with a
+// row for each vertex.
+func FromAdjacencyMatrix(m [][]bool, vs []Vertex) (*Graph, error) {
+	if len(m) != len(vs) {
+		return nil, fmt.Errorf("matrix has %d rows for %d vertices", len(m), len(vs))
+	}
+	for i, row := range m {
+		if len(row) != len(vs) {
+			return nil, fmt.Errorf("matrix row %d has %d columns for %d vertices", i, len(row), len(vs))
+		}
+	}
+
+	g := &Graph{}
+	for _, v := range vs {
+		g.Add(v)
+	}
+	for i, row := range m {
+		for j, edge := range row {
+			if edge {
+				g.Connect(BasicEdge(vs[i], vs[j]))
+			}
+		}
+	}
+
+	return g, nil
+}
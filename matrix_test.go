@@ -0,0 +1,62 @@
+package dag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGraphAdjacencyMatrix(t *testing.T) {
+	var g Graph
+	for _, v := range []string{"c", "a", "b"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("a", "b"))
+	g.Connect(BasicEdge("a", "c"))
+	g.Connect(BasicLabeledEdge("c", "b", "build"))
+	g.Connect(BasicLabeledEdge("c", "b", "runtime"))
+
+	m, vs := g.AdjacencyMatrix()
+	if !reflect.DeepEqual(vs, []Vertex{"a", "b", "c"}) {
+		t.Fatalf("bad vertices: %#v", vs)
+	}
+	expected := [][]bool{
+		{false, true, true},
+		{false, false, false},
+		{false, true, false},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("bad matrix: %#v", m)
+	}
+
+	bits, _ := g.AdjacencyBitset()
+	// bits 1, 2 and 7
+	if !reflect.DeepEqual(bits, []uint64{0x86}) {
+		t.Fatalf("bad bitset: %#x", bits)
+	}
+
+	parsed, err := FromAdjacencyMatrix(m, vs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := strings.TrimSpace(parsed.String())
+	if actual != strings.TrimSpace(testGraphAdjacencyMatrixStr) {
+		t.Fatalf("bad graph:\n%s", actual)
+	}
+
+	if _, err := FromAdjacencyMatrix(m[:2], vs); err == nil {
+		t.Fatal("should error for missing rows")
+	}
+	if _, err := FromAdjacencyMatrix([][]bool{{true}, {}}, []Vertex{1, 2}); err == nil {
+		t.Fatal("should error for short rows")
+	}
+}
+
+const testGraphAdjacencyMatrixStr = `
+a
+  b
+  c
+b
+c
+  b
+`
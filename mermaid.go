@@ -0,0 +1,81 @@
+package dag
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// MermaidOpts are the options for generating a Mermaid flowchart.
+type MermaidOpts struct {
+	// Direction of the flowchart, such as "TD" (the default) or "LR".
+	Direction string
+}
+
+// Mermaid returns a Mermaid flowchart representation of the Graph, suitable
+// for embedding in Markdown. Subgraphs are drawn as Mermaid subgraph blocks.
+func (g *Graph) Mermaid(opts *MermaidOpts) []byte {
+	return newMarshalGraph("", g, nil).Mermaid(opts)
+}
+
+// Returns the Mermaid representation of this Graph.
+func (g *MarshalGraph) Mermaid(opts *MermaidOpts) []byte {
+	if opts == nil {
+		opts = &MermaidOpts{}
+	}
+	direction := opts.Direction
+	if direction == "" {
+		direction = "TD"
+	}
+
+	var buf bytes.Buffer
+	w := newIndentWriter(&buf)
+	w.WriteString("flowchart " + direction + "\n")
+	w.Indent()
+	g.writeMermaid(make(map[*MarshalVertex]string), w)
+	w.Unindent()
+
+	w.Flush()
+	return buf.Bytes()
+}
+
+// writeMermaid writes the vertices and edges of the graph, recursing into any
+// subgraphs. Mermaid node IDs are allocated in ids as each vertex is written,
+// since the marshaled IDs aren't necessarily valid Mermaid identifiers.
+func (g *MarshalGraph) writeMermaid(ids map[*MarshalVertex]string, w *indentWriter) {
+	subgraphs := make(map[string]*MarshalGraph, len(g.Subgraphs))
+	for _, sg := range g.Subgraphs {
+		subgraphs[sg.ID] = sg
+	}
+
+	for _, v := range g.Vertices {
+		id := fmt.Sprintf("n%d", len(ids))
+		ids[v] = id
+
+		sg, ok := subgraphs[v.ID]
+		if !ok {
+			w.WriteString(fmt.Sprintf("%s[%s]\n", id, mermaidLabel(v.Name)))
+			continue
+		}
+
+		w.WriteString(fmt.Sprintf("subgraph %s[%s]\n", id, mermaidLabel(v.Name)))
+		w.Indent()
+		sg.writeMermaid(ids, w)
+		w.Unindent()
+		w.WriteString("end\n")
+	}
+
+	for _, e := range g.Edges {
+		source := g.VertexByID(e.Source)
+		target := g.VertexByID(e.Target)
+		if source == nil || target == nil {
+			continue
+		}
+		w.WriteString(fmt.Sprintf("%s --> %s\n", ids[source], ids[target]))
+	}
+}
+
+// mermaidLabel quotes a marshaled vertex name for use as a Mermaid label.
+func mermaidLabel(name string) string {
+	return `"` + strings.ReplaceAll(marshaledName(name), `"`, "#quot;") + `"`
+}
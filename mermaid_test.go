@@ -0,0 +1,68 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphMermaid_basic(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 3))
+
+	actual := strings.TrimSpace(string(g.Mermaid(nil)))
+	expected := strings.TrimSpace(testGraphMermaidBasicStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+func TestGraphMermaid_direction(t *testing.T) {
+	var g Graph
+	g.Add(1)
+
+	actual := string(g.Mermaid(&MermaidOpts{Direction: "LR"}))
+	if !strings.HasPrefix(actual, "flowchart LR\n") {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+func TestGraphMermaid_subgraph(t *testing.T) {
+	var sub Graph
+	sub.Add("x")
+	sub.Add(`y "quoted"`)
+	sub.Connect(BasicEdge("x", `y "quoted"`))
+
+	var g Graph
+	s := &testSubgrapher{name: "sub", graph: &sub}
+	g.Add("a")
+	g.Add(s)
+	g.Connect(BasicEdge("a", s))
+
+	actual := strings.TrimSpace(string(g.Mermaid(nil)))
+	expected := strings.TrimSpace(testGraphMermaidSubgraphStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+const testGraphMermaidBasicStr = `
+flowchart TD
+	n0["1"]
+	n1["2"]
+	n2["3"]
+	n0 --> n2
+`
+
+const testGraphMermaidSubgraphStr = `
+flowchart TD
+	n0["a"]
+	subgraph n1["sub"]
+		n2["x"]
+		n3["y #quot;quoted#quot;"]
+		n2 --> n3
+	end
+	n0 --> n1
+`
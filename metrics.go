@@ -0,0 +1,68 @@
+package dag
+
+// Metrics receives measurements of walks and graphs, so they can be passed
+// on to a metrics system such as Prometheus. Each measurement is identified
+// by one of the Metric names below. Implementations must be safe for
+// concurrent use, as a walk reports from many goroutines at once.
+type Metrics interface {
+	// AddCounter adds delta to the counter called name.
+	AddCounter(name string, delta float64)
+
+	// SetGauge sets the gauge called name to value.
+	SetGauge(name string, value float64)
+
+	// Observe records value in the histogram called name.
+	Observe(name string, value float64)
+}
+
+// The names of the measurements reported to Metrics.
+const (
+	// MetricVerticesVisited counts the vertices visited by walks, whether or
+	// not they succeed.
+	MetricVerticesVisited = "dag_walk_vertices_visited_total"
+
+	// MetricVertexFailures counts the vertices which return errors when
+	// visited.
+	MetricVertexFailures = "dag_walk_vertex_failures_total"
+
+	// MetricQueueDepth is the number of vertices whose dependencies are
+	// done, waiting for a concurrency limit before being visited.
+	MetricQueueDepth = "dag_walk_queue_depth"
+
+	// MetricVertexDuration observes how long each vertex takes to visit, in
+	// seconds.
+	MetricVertexDuration = "dag_walk_vertex_duration_seconds"
+
+	// MetricWalkDuration observes how long each walk takes, in seconds, from
+	// the first call to Update to the return of Wait.
+	MetricWalkDuration = "dag_walk_duration_seconds"
+
+	// MetricGraphVertices and MetricGraphEdges are the number of vertices
+	// and edges in a graph reporting with ReportMetrics.
+	MetricGraphVertices = "dag_graph_vertices"
+	MetricGraphEdges    = "dag_graph_edges"
+)
+
+// NopMetrics is a Metrics which discards every measurement. It is used when
+// no Metrics is given.
+type NopMetrics struct{}
+
+func (NopMetrics) AddCounter(string, float64) {}
+func (NopMetrics) SetGauge(string, float64)   {}
+func (NopMetrics) Observe(string, float64)    {}
+
+// ReportMetrics reports the size of the graph to m, now and whenever it
+// changes, as MetricGraphVertices and MetricGraphEdges. It registers
+// listeners with OnAdd, OnRemove, OnConnect and OnRemoveEdge to do so.
+func (g *Graph) ReportMetrics(m Metrics) {
+	vertices := func(Vertex) { m.SetGauge(MetricGraphVertices, float64(len(g.vertices))) }
+	edges := func(Edge) { m.SetGauge(MetricGraphEdges, float64(len(g.edges))) }
+
+	g.OnAdd(vertices)
+	g.OnRemove(vertices)
+	g.OnConnect(edges)
+	g.OnRemoveEdge(edges)
+
+	vertices(nil)
+	edges(nil)
+}
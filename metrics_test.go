@@ -0,0 +1,97 @@
+package dag
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestWalker_metrics(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+
+	m := newTestMetrics()
+	diags := g.WalkWithOpts(func(v Vertex) Diagnostics {
+		var diags Diagnostics
+		if v == 3 {
+			diags = diags.Append(fmt.Errorf("error"))
+		}
+		return diags
+	}, &WalkOpts{Metrics: m, Parallelism: 1})
+	if !diags.HasErrors() {
+		t.Fatal("expected an error")
+	}
+
+	if n := m.counters[MetricVerticesVisited]; n != 3 {
+		t.Errorf("visited %v vertices", n)
+	}
+	if n := m.counters[MetricVertexFailures]; n != 1 {
+		t.Errorf("%v failures", n)
+	}
+	if n := len(m.observed[MetricVertexDuration]); n != 3 {
+		t.Errorf("%d vertex durations", n)
+	}
+	if n := len(m.observed[MetricWalkDuration]); n != 1 {
+		t.Errorf("%d walk durations", n)
+	}
+	if n := m.gauges[MetricQueueDepth]; n != 0 {
+		t.Errorf("queue depth %v after the walk", n)
+	}
+}
+
+func TestGraphReportMetrics(t *testing.T) {
+	var g Graph
+	g.Add(1)
+
+	m := newTestMetrics()
+	g.ReportMetrics(m)
+	if m.gauges[MetricGraphVertices] != 1 || m.gauges[MetricGraphEdges] != 0 {
+		t.Fatalf("bad initial gauges: %#v", m.gauges)
+	}
+
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Remove(3)
+	if m.gauges[MetricGraphVertices] != 2 || m.gauges[MetricGraphEdges] != 1 {
+		t.Fatalf("bad gauges: %#v", m.gauges)
+	}
+}
+
+// testMetrics records every measurement.
+type testMetrics struct {
+	lock     sync.Mutex
+	counters map[string]float64
+	gauges   map[string]float64
+	observed map[string][]float64
+}
+
+func newTestMetrics() *testMetrics {
+	return &testMetrics{
+		counters: make(map[string]float64),
+		gauges:   make(map[string]float64),
+		observed: make(map[string][]float64),
+	}
+}
+
+func (m *testMetrics) AddCounter(name string, delta float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.counters[name] += delta
+}
+
+func (m *testMetrics) SetGauge(name string, value float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.gauges[name] = value
+}
+
+func (m *testMetrics) Observe(name string, value float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.observed[name] = append(m.observed[name], value)
+}
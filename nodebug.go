@@ -0,0 +1,7 @@
+//go:build !dagdebug
+
+package dag
+
+// debugInvariants enables checking the consistency of a graph after every
+// change, with the dagdebug build tag.
+const debugInvariants = false
@@ -0,0 +1,73 @@
+package dag
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ParallelWalkAll calls f once for every vertex in the graph, from up to
+// parallelism goroutines at once, in no particular order. It is meant for
+// read-only analysis of large graphs, such as validating every vertex, where
+// the order of the calls doesn't matter. f must not change the graph. A
+// parallelism of zero or less uses runtime.GOMAXPROCS.
+//
+// Every vertex is visited even if f returns an error for some of them, and
+// the errors are returned together.
+//
+// Complexity: O(V) calls of f, divided between the goroutines
+func (g *Graph) ParallelWalkAll(f func(Vertex) error, parallelism int) error {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+
+	// set up the graph before it is shared between the goroutines
+	g.init()
+
+	vs := g.Vertices()
+	if len(vs) == 0 {
+		return nil
+	}
+	if parallelism > len(vs) {
+		parallelism = len(vs)
+	}
+
+	// Vertices are handed out in chunks, so that a few slow vertices don't
+	// hold up one goroutine while the others are idle.
+	chunk := len(vs) / (parallelism * 8)
+	if chunk < 1 {
+		chunk = 1
+	}
+
+	var next int64
+	var lock sync.Mutex
+	var diags Diagnostics
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				end := int(atomic.AddInt64(&next, int64(chunk)))
+				start := end - chunk
+				if start >= len(vs) {
+					return
+				}
+				if end > len(vs) {
+					end = len(vs)
+				}
+
+				for _, v := range vs[start:end] {
+					if err := f(v); err != nil {
+						lock.Lock()
+						diags = diags.Append(err)
+						lock.Unlock()
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return diags.Err()
+}
@@ -0,0 +1,55 @@
+package dag
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGraphParallelWalkAll(t *testing.T) {
+	var g Graph
+	for i := 0; i < 1000; i++ {
+		g.Add(i)
+		if i > 0 {
+			g.Connect(BasicEdge(i, i-1))
+		}
+	}
+
+	for _, parallelism := range []int{0, 1, 4, 5000} {
+		var lock sync.Mutex
+		seen := make(map[Vertex]int)
+		err := g.ParallelWalkAll(func(v Vertex) error {
+			lock.Lock()
+			defer lock.Unlock()
+			seen[v]++
+			return nil
+		}, parallelism)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(seen) != 1000 {
+			t.Fatalf("parallelism %d: visited %d vertices", parallelism, len(seen))
+		}
+		for v, n := range seen {
+			if n != 1 {
+				t.Fatalf("parallelism %d: visited %v %d times", parallelism, v, n)
+			}
+		}
+	}
+
+	err := g.ParallelWalkAll(func(v Vertex) error {
+		if v.(int)%500 == 0 {
+			return fmt.Errorf("bad vertex %d", v)
+		}
+		return nil
+	}, 4)
+	if err == nil || !strings.Contains(err.Error(), "bad vertex 0") || !strings.Contains(err.Error(), "bad vertex 500") {
+		t.Fatalf("expected both errors, got %v", err)
+	}
+
+	if err := (&Graph{}).ParallelWalkAll(func(Vertex) error { return nil }, 4); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,310 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// HasDuration is an optional interface that can be implemented by a Vertex
+// to report how long it takes to execute, for use by CriticalPath. Vertices
+// that don't implement HasDuration are treated as taking no time.
+type HasDuration interface {
+	Duration() time.Duration
+}
+
+// ShortestPath returns the path from src to dst with the lowest total edge
+// weight, along with that weight. Edges are followed from source to target,
+// and weighted according to WeightedEdge.
+//
+// An error is returned if either vertex is missing, if dst can't be reached
+// from src, or if the graph contains a cycle.
+//
+// Complexity: O(V log V + E)
+func (g *AcyclicGraph) ShortestPath(src, dst Vertex) ([]Vertex, float64, error) {
+	return g.relaxedPath(src, dst, func(a, b float64) bool { return a < b })
+}
+
+// LongestPath returns the path from src to dst with the highest total edge
+// weight, along with that weight. This is the critical path between the two
+// vertices when weights represent the cost of each step.
+//
+// An error is returned if either vertex is missing, if dst can't be reached
+// from src, or if the graph contains a cycle.
+//
+// Complexity: O(V log V + E)
+func (g *AcyclicGraph) LongestPath(src, dst Vertex) ([]Vertex, float64, error) {
+	return g.relaxedPath(src, dst, func(a, b float64) bool { return a > b })
+}
+
+// CriticalPath returns the chain of dependent vertices with the longest total
+// duration, according to HasDuration, along with that duration. This is the
+// minimum time a parallel walk of the graph could take to complete. The path
+// is returned in execution order, with each vertex followed by the vertex
+// that depends on it.
+//
+// An error is returned if the graph contains a cycle.
+//
+// Complexity: O(V log V + E)
+func (g *AcyclicGraph) CriticalPath() ([]Vertex, time.Duration, error) {
+	order, err := g.TopologicalSort()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// finish records the earliest time each vertex could complete, and next
+	// records the dependency which determined that time.
+	finish := make(map[interface{}]time.Duration, len(order))
+	next := make(map[interface{}]Vertex, len(order))
+
+	var end Vertex
+	var total time.Duration
+	for _, v := range order {
+		code := hashcode(v)
+
+		var start time.Duration
+		for _, raw := range g.downEdgesNoCopy(v) {
+			depCode := hashcode(raw)
+			if f, ok := finish[depCode]; ok && (f > start || next[code] == nil) {
+				start = f
+				next[code] = raw.(Vertex)
+			}
+		}
+
+		f := start + vertexDuration(v)
+		finish[code] = f
+		if end == nil || f > total {
+			end = v
+			total = f
+		}
+	}
+
+	var path []Vertex
+	for v := end; v != nil; v = next[hashcode(v)] {
+		path = append(path, v)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path, total, nil
+}
+
+// vertexDuration returns the duration of v, defaulting to 0 for vertices
+// which don't implement HasDuration.
+func vertexDuration(v Vertex) time.Duration {
+	if d, ok := v.(HasDuration); ok {
+		return d.Duration()
+	}
+	return 0
+}
+
+// AllPathsOpts are the options for AllPaths.
+type AllPathsOpts struct {
+	// MaxPaths limits the number of paths returned. Zero means no limit.
+	MaxPaths int
+}
+
+// AllPaths returns every simple path from src to dst, following edges from
+// source to target. Paths are enumerated depth-first, visiting targets in
+// order of VertexName, so the result is stable. If opts is nil, every path
+// is returned.
+//
+// An error is returned if either vertex is missing. Finding no paths is not
+// an error.
+func (g *AcyclicGraph) AllPaths(src, dst Vertex, opts *AllPathsOpts) ([][]Vertex, error) {
+	if opts == nil {
+		opts = &AllPathsOpts{}
+	}
+
+	for _, v := range []Vertex{src, dst} {
+		if !g.HasVertex(v) {
+			return nil, fmt.Errorf("vertex %s is not in the graph", VertexName(v))
+		}
+	}
+
+	// Only vertices which can reach dst are worth exploring, which keeps
+	// the search from wandering into unrelated parts of the graph.
+	relevant, err := g.Ancestors(dst)
+	if err != nil {
+		return nil, err
+	}
+	dstCode := hashcode(dst)
+
+	var paths [][]Vertex
+	var path []Vertex
+	onPath := make(map[interface{}]struct{})
+
+	var visit func(v Vertex) bool
+	visit = func(v Vertex) bool {
+		code := hashcode(v)
+		path = append(path, v)
+		onPath[code] = struct{}{}
+		defer func() {
+			path = path[:len(path)-1]
+			delete(onPath, code)
+		}()
+
+		if code == dstCode {
+			paths = append(paths, append([]Vertex(nil), path...))
+			return opts.MaxPaths <= 0 || len(paths) < opts.MaxPaths
+		}
+
+		targets := AsVertexList(g.downEdgesNoCopy(v))
+		sort.Sort(byVertexName(targets))
+		for _, t := range targets {
+			tCode := hashcode(t)
+			if _, ok := onPath[tCode]; ok {
+				continue
+			}
+			if tCode != dstCode && !relevant.Include(t) {
+				continue
+			}
+			if !visit(t) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if hashcode(src) == dstCode || relevant.Include(src) {
+		visit(src)
+	}
+
+	return paths, nil
+}
+
+// Explain returns one of the shortest chains of dependencies from src to dst,
+// answering the question of why src depends on dst. The chain counts edges
+// rather than weights, and ties are broken by VertexName.
+//
+// An error is returned if either vertex is missing, or if src does not depend
+// on dst.
+//
+// Complexity: O(V log V + E)
+func (g *AcyclicGraph) Explain(src, dst Vertex) ([]Vertex, error) {
+	for _, v := range []Vertex{src, dst} {
+		if !g.HasVertex(v) {
+			return nil, fmt.Errorf("vertex %s is not in the graph", VertexName(v))
+		}
+	}
+
+	srcCode, dstCode := hashcode(src), hashcode(dst)
+	prev := map[interface{}]Vertex{srcCode: nil}
+	frontier := []Vertex{src}
+	for len(frontier) > 0 && srcCode != dstCode {
+		current := frontier[0]
+		frontier = frontier[1:]
+
+		targets := AsVertexList(g.downEdgesNoCopy(current))
+		sort.Sort(byVertexName(targets))
+		for _, t := range targets {
+			code := hashcode(t)
+			if _, ok := prev[code]; ok {
+				continue
+			}
+			prev[code] = current
+			frontier = append(frontier, t)
+		}
+
+		if _, ok := prev[dstCode]; ok {
+			break
+		}
+	}
+
+	if _, ok := prev[dstCode]; !ok {
+		return nil, fmt.Errorf("no dependency from %s to %s", VertexName(src), VertexName(dst))
+	}
+
+	var path []Vertex
+	for v := dst; v != nil; v = prev[hashcode(v)] {
+		path = append(path, v)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path, nil
+}
+
+// relaxedPath finds the best path from src to dst by relaxing each edge in
+// topological order, where better reports whether distance a should replace
+// distance b.
+func (g *AcyclicGraph) relaxedPath(src, dst Vertex, better func(a, b float64) bool) ([]Vertex, float64, error) {
+	for _, v := range []Vertex{src, dst} {
+		if !g.HasVertex(v) {
+			return nil, 0, fmt.Errorf("vertex %s is not in the graph", VertexName(v))
+		}
+	}
+
+	order, err := g.TopologicalSort()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	weights := g.edgeWeights(better)
+
+	srcCode := hashcode(src)
+	dist := map[interface{}]float64{srcCode: 0}
+	prev := make(map[interface{}]Vertex)
+
+	// TopologicalSort orders targets first, so walk it backwards to visit
+	// every source before the targets of its edges.
+	for i := len(order) - 1; i >= 0; i-- {
+		u := order[i]
+		uCode := hashcode(u)
+		d, ok := dist[uCode]
+		if !ok {
+			// not reachable from src
+			continue
+		}
+
+		for _, raw := range g.downEdgesNoCopy(u) {
+			vCode := hashcode(raw)
+			next := d + weights[uCode][vCode]
+			if cur, ok := dist[vCode]; !ok || better(next, cur) {
+				dist[vCode] = next
+				prev[vCode] = u
+			}
+		}
+	}
+
+	total, ok := dist[hashcode(dst)]
+	if !ok {
+		return nil, 0, fmt.Errorf("no path from %s to %s", VertexName(src), VertexName(dst))
+	}
+
+	// Follow the chain of predecessors back to the start.
+	path := []Vertex{dst}
+	for code := hashcode(dst); code != srcCode; {
+		v := prev[code]
+		path = append(path, v)
+		code = hashcode(v)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path, total, nil
+}
+
+// edgeWeights returns the weight of every edge in the graph, indexed by the
+// hashcodes of the source and target. Where parallel edges connect the same
+// vertices, the weight kept is the one which better reports should replace
+// the others.
+func (g *Graph) edgeWeights(better func(a, b float64) bool) map[interface{}]map[interface{}]float64 {
+	weights := make(map[interface{}]map[interface{}]float64)
+	for _, e := range g.Edges() {
+		sourceCode := hashcode(e.Source())
+		m, ok := weights[sourceCode]
+		if !ok {
+			m = make(map[interface{}]float64)
+			weights[sourceCode] = m
+		}
+		targetCode := hashcode(e.Target())
+		if w, ok := m[targetCode]; !ok || better(edgeWeight(e), w) {
+			m[targetCode] = edgeWeight(e)
+		}
+	}
+	return weights
+}
@@ -0,0 +1,266 @@
+package dag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testWeightedGraph() *AcyclicGraph {
+	var g AcyclicGraph
+	g.Add("a")
+	g.Add("b")
+	g.Add("c")
+	g.Add("d")
+	g.Add("e")
+	g.Connect(BasicWeightedEdge("a", "b", 1))
+	g.Connect(BasicWeightedEdge("a", "c", 4))
+	g.Connect(BasicWeightedEdge("b", "c", 2))
+	g.Connect(BasicWeightedEdge("b", "d", 7))
+	g.Connect(BasicWeightedEdge("c", "d", 3))
+	return &g
+}
+
+func TestAcyclicGraphShortestPath(t *testing.T) {
+	g := testWeightedGraph()
+
+	path, weight, err := g.ShortestPath("a", "d")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Vertex{"a", "b", "c", "d"}
+	if !reflect.DeepEqual(path, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, path)
+	}
+	if weight != 6 {
+		t.Fatalf("expected weight 6, got: %v", weight)
+	}
+}
+
+func TestAcyclicGraphLongestPath(t *testing.T) {
+	g := testWeightedGraph()
+
+	path, weight, err := g.LongestPath("a", "d")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Vertex{"a", "b", "d"}
+	if !reflect.DeepEqual(path, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, path)
+	}
+	if weight != 8 {
+		t.Fatalf("expected weight 8, got: %v", weight)
+	}
+}
+
+func TestAcyclicGraphPath_parallelEdges(t *testing.T) {
+	var g AcyclicGraph
+	g.Add("a")
+	g.Add("b")
+	g.Connect(&basicLabeledEdge{basicEdge: basicEdge{S: "a", T: "b"}, L: "build", W: 2})
+	g.Connect(&basicLabeledEdge{basicEdge: basicEdge{S: "a", T: "b"}, L: "runtime", W: 5})
+	g.Connect(BasicWeightedEdge("a", "b", 3))
+
+	// the lightest edge counts for the shortest path, and the heaviest for
+	// the longest
+	if _, weight, err := g.ShortestPath("a", "b"); err != nil || weight != 2 {
+		t.Fatalf("expected weight 2, got: %v (%v)", weight, err)
+	}
+	if _, weight, err := g.LongestPath("a", "b"); err != nil || weight != 5 {
+		t.Fatalf("expected weight 5, got: %v (%v)", weight, err)
+	}
+}
+
+func TestAcyclicGraphShortestPath_unweighted(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(1, 3))
+
+	path, weight, err := g.ShortestPath(1, 3)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Vertex{1, 3}
+	if !reflect.DeepEqual(path, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, path)
+	}
+	if weight != 1 {
+		t.Fatalf("expected weight 1, got: %v", weight)
+	}
+}
+
+func TestAcyclicGraphShortestPath_unreachable(t *testing.T) {
+	g := testWeightedGraph()
+
+	if _, _, err := g.ShortestPath("d", "a"); err == nil {
+		t.Fatal("should error")
+	}
+	if _, _, err := g.ShortestPath("a", "e"); err == nil {
+		t.Fatal("should error")
+	}
+	if _, _, err := g.ShortestPath("a", "missing"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestAcyclicGraphShortestPath_self(t *testing.T) {
+	g := testWeightedGraph()
+
+	path, weight, err := g.ShortestPath("a", "a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(path, []Vertex{"a"}) || weight != 0 {
+		t.Fatalf("bad: %#v, %v", path, weight)
+	}
+}
+
+type testDurationVertex struct {
+	name     string
+	duration time.Duration
+}
+
+func (v *testDurationVertex) Name() string            { return v.name }
+func (v *testDurationVertex) Duration() time.Duration { return v.duration }
+
+func TestAcyclicGraphCriticalPath(t *testing.T) {
+	compile := &testDurationVertex{"compile", 5 * time.Second}
+	lint := &testDurationVertex{"lint", 2 * time.Second}
+	fetch := &testDurationVertex{"fetch", 1 * time.Second}
+	test := &testDurationVertex{"test", 3 * time.Second}
+	deploy := &testDurationVertex{"deploy", 4 * time.Second}
+
+	var g AcyclicGraph
+	g.Add(compile)
+	g.Add(lint)
+	g.Add(fetch)
+	g.Add(test)
+	g.Add(deploy)
+	g.Connect(BasicEdge(compile, fetch))
+	g.Connect(BasicEdge(lint, fetch))
+	g.Connect(BasicEdge(test, compile))
+	g.Connect(BasicEdge(deploy, test))
+	g.Connect(BasicEdge(deploy, lint))
+
+	path, total, err := g.CriticalPath()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Vertex{fetch, compile, test, deploy}
+	if !reflect.DeepEqual(path, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, path)
+	}
+	if total != 13*time.Second {
+		t.Fatalf("expected 13s, got: %s", total)
+	}
+}
+
+func TestAcyclicGraphCriticalPath_empty(t *testing.T) {
+	var g AcyclicGraph
+
+	path, total, err := g.CriticalPath()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(path) != 0 || total != 0 {
+		t.Fatalf("bad: %#v, %s", path, total)
+	}
+}
+
+func TestAcyclicGraphCriticalPath_cycle(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 1))
+
+	if _, _, err := g.CriticalPath(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestAcyclicGraphAllPaths(t *testing.T) {
+	g := testWeightedGraph()
+
+	paths, err := g.AllPaths("a", "d", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := [][]Vertex{
+		{"a", "b", "c", "d"},
+		{"a", "b", "d"},
+		{"a", "c", "d"},
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, paths)
+	}
+}
+
+func TestAcyclicGraphAllPaths_max(t *testing.T) {
+	g := testWeightedGraph()
+
+	paths, err := g.AllPaths("a", "d", &AllPathsOpts{MaxPaths: 2})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := [][]Vertex{
+		{"a", "b", "c", "d"},
+		{"a", "b", "d"},
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, paths)
+	}
+}
+
+func TestAcyclicGraphAllPaths_none(t *testing.T) {
+	g := testWeightedGraph()
+
+	paths, err := g.AllPaths("d", "a", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(paths) != 0 {
+		t.Fatalf("expected no paths, got: %#v", paths)
+	}
+
+	if _, err := g.AllPaths("a", "missing", nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestAcyclicGraphExplain(t *testing.T) {
+	g := testWeightedGraph()
+
+	path, err := g.Explain("a", "d")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Vertex{"a", "b", "d"}
+	if !reflect.DeepEqual(path, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, path)
+	}
+}
+
+func TestAcyclicGraphExplain_noDependency(t *testing.T) {
+	g := testWeightedGraph()
+
+	_, err := g.Explain("d", "a")
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "no dependency") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
@@ -0,0 +1,99 @@
+package dag
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PlantUMLOpts are the options for generating a PlantUML component diagram.
+type PlantUMLOpts struct {
+	// LeftToRight lays the diagram out from left to right, rather than from
+	// top to bottom.
+	LeftToRight bool
+
+	// Title is written as the title of the diagram, if set.
+	Title string
+}
+
+// PlantUML returns a PlantUML component diagram of the Graph, for
+// documentation pipelines based on PlantUML rather than Graphviz. Vertices are
+// drawn as components, edges as arrows, and subgraphs as packages containing
+// their vertices.
+func (g *Graph) PlantUML(opts *PlantUMLOpts) []byte {
+	return newMarshalGraph("", g, nil).PlantUML(opts)
+}
+
+// Returns the PlantUML representation of this Graph.
+func (g *MarshalGraph) PlantUML(opts *PlantUMLOpts) []byte {
+	if opts == nil {
+		opts = &PlantUMLOpts{}
+	}
+
+	var buf bytes.Buffer
+	w := newIndentWriter(&buf)
+	w.WriteString("@startuml\n")
+	if opts.LeftToRight {
+		w.WriteString("left to right direction\n")
+	}
+	if opts.Title != "" {
+		w.WriteString("title " + opts.Title + "\n")
+	}
+	g.writePlantUML(make(map[*MarshalVertex]string), w)
+	w.WriteString("@enduml\n")
+
+	w.Flush()
+	return buf.Bytes()
+}
+
+// writePlantUML writes the vertices and edges of the graph, recursing into
+// any subgraphs. Aliases are allocated in ids as each vertex is written, as
+// for Mermaid.
+func (g *MarshalGraph) writePlantUML(ids map[*MarshalVertex]string, w *indentWriter) {
+	subgraphs := make(map[string]*MarshalGraph, len(g.Subgraphs))
+	for _, sg := range g.Subgraphs {
+		subgraphs[sg.ID] = sg
+	}
+
+	for _, v := range g.Vertices {
+		id := fmt.Sprintf("n%d", len(ids))
+		ids[v] = id
+
+		sg, ok := subgraphs[v.ID]
+		if !ok {
+			w.WriteString(fmt.Sprintf("component %s as %s\n", plantUMLName(v.Name), id))
+			continue
+		}
+
+		w.WriteString(fmt.Sprintf("package %s as %s {\n", plantUMLName(v.Name), id))
+		w.Indent()
+		sg.writePlantUML(ids, w)
+		w.Unindent()
+		w.WriteString("}\n")
+	}
+
+	for _, e := range g.Edges {
+		source := g.VertexByID(e.Source)
+		target := g.VertexByID(e.Target)
+		if source == nil || target == nil {
+			continue
+		}
+
+		label := e.Attrs["label"]
+		if label == "" {
+			label = e.Label
+		}
+		if label != "" {
+			w.WriteString(fmt.Sprintf("%s --> %s : %s\n", ids[source], ids[target], label))
+		} else {
+			w.WriteString(fmt.Sprintf("%s --> %s\n", ids[source], ids[target]))
+		}
+	}
+}
+
+// plantUMLName quotes a marshaled vertex name for use in PlantUML. PlantUML
+// has no way to escape a double quote, so they are replaced with single
+// quotes.
+func plantUMLName(name string) string {
+	return `"` + strings.ReplaceAll(marshaledName(name), `"`, "'") + `"`
+}
@@ -0,0 +1,72 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphPlantUML_basic(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 3))
+	g.Connect(BasicLabeledEdge(2, 3, "build"))
+
+	actual := strings.TrimSpace(string(g.PlantUML(nil)))
+	expected := strings.TrimSpace(testGraphPlantUMLBasicStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+func TestGraphPlantUML_opts(t *testing.T) {
+	var g Graph
+	g.Add(1)
+
+	actual := string(g.PlantUML(&PlantUMLOpts{LeftToRight: true, Title: "Deps"}))
+	if !strings.HasPrefix(actual, "@startuml\nleft to right direction\ntitle Deps\n") {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+func TestGraphPlantUML_subgraph(t *testing.T) {
+	var sub Graph
+	sub.Add("x")
+	sub.Add(`y "quoted"`)
+	sub.Connect(BasicEdge("x", `y "quoted"`))
+
+	var g Graph
+	s := &testSubgrapher{name: "sub", graph: &sub}
+	g.Add("a")
+	g.Add(s)
+	g.Connect(BasicEdge("a", s))
+
+	actual := strings.TrimSpace(string(g.PlantUML(nil)))
+	expected := strings.TrimSpace(testGraphPlantUMLSubgraphStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+const testGraphPlantUMLBasicStr = `
+@startuml
+component "1" as n0
+component "2" as n1
+component "3" as n2
+n0 --> n2
+n1 --> n2 : build
+@enduml
+`
+
+const testGraphPlantUMLSubgraphStr = `
+@startuml
+component "a" as n0
+package "sub" as n1 {
+	component "x" as n2
+	component "y 'quoted'" as n3
+	n2 --> n3
+}
+n0 --> n1
+@enduml
+`
@@ -0,0 +1,281 @@
+package dag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// MarshalProto encodes the graph, including any subgraphs, in the protobuf
+// format described by graph.proto, for sending between services. The result
+// can be read back with UnmarshalProto, or with code generated from the
+// schema in any language.
+//
+// An error is returned for the same reasons as Marshal.
+func (g *Graph) MarshalProto() ([]byte, error) {
+	mg, err := g.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return appendProtoGraph(nil, mg), nil
+}
+
+// UnmarshalProto decodes a graph in the format produced by MarshalProto.
+// Vertices and edges are reconstructed as for UnmarshalJSON.
+func UnmarshalProto(data []byte) (*Graph, error) {
+	mg, err := parseProtoGraph(data)
+	if err != nil {
+		return nil, err
+	}
+	return mg.graph()
+}
+
+// Protobuf wire types used by graph.proto.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+func appendProtoGraph(b []byte, mg *MarshalGraph) []byte {
+	b = appendProtoString(b, 1, mg.ID)
+	b = appendProtoString(b, 2, mg.Name)
+	b = appendProtoAttrs(b, 3, mg.Attrs)
+	for _, v := range mg.Vertices {
+		var vb []byte
+		vb = appendProtoString(vb, 1, v.ID)
+		vb = appendProtoString(vb, 2, marshaledName(v.Name))
+		vb = appendProtoAttrs(vb, 3, v.Attrs)
+		b = appendProtoField(b, 4, vb)
+	}
+	for _, e := range mg.Edges {
+		var eb []byte
+		eb = appendProtoString(eb, 1, e.Name)
+		eb = appendProtoString(eb, 2, e.Source)
+		eb = appendProtoString(eb, 3, e.Target)
+		eb = appendProtoString(eb, 4, e.Label)
+		eb = appendProtoAttrs(eb, 5, e.Attrs)
+		if e.Weight != nil {
+			eb = appendProtoDouble(eb, 6, *e.Weight)
+		}
+		b = appendProtoField(b, 5, eb)
+	}
+	for _, sg := range mg.Subgraphs {
+		b = appendProtoField(b, 6, appendProtoGraph(nil, sg))
+	}
+	return b
+}
+
+// appendProtoAttrs appends a map<string, string> field, sorted by key so the
+// output is the same each time.
+func appendProtoAttrs(b []byte, field int, attrs map[string]string) []byte {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var entry []byte
+		entry = appendProtoString(entry, 1, k)
+		entry = appendProtoString(entry, 2, attrs[k])
+		b = appendProtoField(b, field, entry)
+	}
+	return b
+}
+
+// appendProtoString appends a string field, leaving it out if it is empty as
+// proto3 does.
+func appendProtoString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendProtoField(b, field, []byte(s))
+}
+
+// appendProtoField appends a length-delimited field.
+func appendProtoField(b []byte, field int, value []byte) []byte {
+	b = appendProtoVarint(b, uint64(field)<<3|protoBytes)
+	b = appendProtoVarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// appendProtoDouble appends a double field.
+func appendProtoDouble(b []byte, field int, f float64) []byte {
+	b = appendProtoVarint(b, uint64(field)<<3|protoFixed64)
+	var v [8]byte
+	binary.LittleEndian.PutUint64(v[:], math.Float64bits(f))
+	return append(b, v[:]...)
+}
+
+func appendProtoVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func parseProtoGraph(data []byte) (*MarshalGraph, error) {
+	mg := &MarshalGraph{
+		Type:  "Graph",
+		Attrs: make(map[string]string),
+	}
+	err := parseProtoFields(data, func(field int, value []byte) error {
+		switch field {
+		case 1:
+			mg.ID = string(value)
+		case 2:
+			mg.Name = string(value)
+		case 3:
+			return parseProtoAttr(value, mg.Attrs)
+		case 4:
+			mv := &MarshalVertex{Attrs: make(map[string]string)}
+			err := parseProtoFields(value, func(field int, value []byte) error {
+				switch field {
+				case 1:
+					mv.ID = string(value)
+				case 2:
+					// names are escaped in a MarshalVertex
+					name := strconv.Quote(string(value))
+					mv.Name = name[1 : len(name)-1]
+				case 3:
+					return parseProtoAttr(value, mv.Attrs)
+				}
+				return nil
+			}, nil)
+			if err != nil {
+				return fmt.Errorf("vertex: %s", err)
+			}
+			mg.Vertices = append(mg.Vertices, mv)
+		case 5:
+			me := &MarshalEdge{Attrs: make(map[string]string)}
+			err := parseProtoFields(value, func(field int, value []byte) error {
+				switch field {
+				case 1:
+					me.Name = string(value)
+				case 2:
+					me.Source = string(value)
+				case 3:
+					me.Target = string(value)
+				case 4:
+					me.Label = string(value)
+				case 5:
+					return parseProtoAttr(value, me.Attrs)
+				}
+				return nil
+			}, func(field int, bits uint64) error {
+				if field == 6 {
+					w := math.Float64frombits(bits)
+					me.Weight = &w
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("edge: %s", err)
+			}
+			mg.Edges = append(mg.Edges, me)
+		case 6:
+			sg, err := parseProtoGraph(value)
+			if err != nil {
+				return fmt.Errorf("subgraph: %s", err)
+			}
+			mg.Subgraphs = append(mg.Subgraphs, sg)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return mg, nil
+}
+
+// parseProtoAttr adds an entry of a map<string, string> field to attrs.
+func parseProtoAttr(data []byte, attrs map[string]string) error {
+	var k, v string
+	err := parseProtoFields(data, func(field int, value []byte) error {
+		switch field {
+		case 1:
+			k = string(value)
+		case 2:
+			v = string(value)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("attribute: %s", err)
+	}
+	attrs[k] = v
+	return nil
+}
+
+var errProtoTruncated = errors.New("truncated protobuf data")
+
+// parseProtoFields calls f with each length-delimited field in data, and
+// fixed64, if it is not nil, with each fixed64 field. Fields of other wire
+// types aren't used by graph.proto, and are skipped so that fields can be
+// added to the schema later.
+func parseProtoFields(data []byte, f func(field int, value []byte) error, fixed64 func(field int, bits uint64) error) error {
+	for len(data) > 0 {
+		tag, n := parseProtoVarint(data)
+		if n == 0 {
+			return errProtoTruncated
+		}
+		data = data[n:]
+		field, wireType := int(tag>>3), tag&7
+
+		switch wireType {
+		case protoVarint:
+			_, n := parseProtoVarint(data)
+			if n == 0 {
+				return errProtoTruncated
+			}
+			data = data[n:]
+		case protoFixed64:
+			if len(data) < 8 {
+				return errProtoTruncated
+			}
+			bits := binary.LittleEndian.Uint64(data)
+			data = data[8:]
+			if fixed64 != nil {
+				if err := fixed64(field, bits); err != nil {
+					return err
+				}
+			}
+		case 5: // fixed32
+			if len(data) < 4 {
+				return errProtoTruncated
+			}
+			data = data[4:]
+		case protoBytes:
+			size, n := parseProtoVarint(data)
+			if n == 0 || uint64(len(data)-n) < size {
+				return errProtoTruncated
+			}
+			value := data[n : n+int(size)]
+			data = data[n+int(size):]
+			if err := f(field, value); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wireType)
+		}
+	}
+	return nil
+}
+
+// parseProtoVarint returns the varint at the start of data and its length,
+// or a length of zero if it is truncated or too long.
+func parseProtoVarint(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		v |= uint64(data[i]&0x7f) << (7 * uint(i))
+		if data[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
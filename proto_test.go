@@ -0,0 +1,124 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGraphProto_roundTrip(t *testing.T) {
+	var sub AcyclicGraph
+	sub.Add("x")
+	sub.Add("y")
+	sub.Connect(BasicEdge("x", "y"))
+
+	var g Graph
+	quoted := `name["with-quotes"]\n`
+	g.Add(1)
+	g.Add(quoted)
+	g.Add(&testSubgrapher{name: "sub", graph: &sub})
+	g.Connect(BasicAttrEdge(1, quoted, map[string]string{"color": "red"}))
+	g.Connect(BasicLabeledEdge(1, quoted, "build"))
+	g.SetVertexAttr(1, "owner", "team-a")
+
+	data, err := g.MarshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := UnmarshalProto(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.String() != g.String() {
+		t.Fatalf("got:\n%s\nwant:\n%s", parsed.String(), g.String())
+	}
+
+	// the parsed graph marshals to the same JSON as the original
+	opts := &MarshalOpts{StableIDs: true}
+	mg, err := g.MarshalWithOpts(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(mg)
+	mg, err = parsed.MarshalWithOpts(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(mg)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// encoding is deterministic
+	again, err := g.MarshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Fatal("encoding changed between calls")
+	}
+}
+
+func TestGraphProto_weights(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicWeightedEdge(1, 2, 2.5))
+	g.Connect(BasicWeightedEdge(1, 3, 0))
+	g.Connect(&basicLabeledEdge{basicEdge: basicEdge{S: 2, T: 3}, L: "slow", W: 10})
+	g.Connect(BasicEdge(2, 3))
+
+	data, err := g.MarshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := UnmarshalProto(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weights := make(map[string]float64)
+	for _, e := range parsed.Edges() {
+		weights[VertexName(e.Source())+"-"+VertexName(e.Target())+"-"+edgeLabel(e)] = edgeWeight(e)
+	}
+	expected := map[string]float64{
+		"1-2-":     2.5,
+		"1-3-":     0,
+		"2-3-slow": 10,
+		"2-3-":     1,
+	}
+	if !reflect.DeepEqual(weights, expected) {
+		t.Fatalf("bad weights: %#v", weights)
+	}
+}
+
+func TestUnmarshalProto_errors(t *testing.T) {
+	var g Graph
+	g.Add(1)
+	g.Add(2)
+	g.Connect(BasicEdge(1, 2))
+	data, err := g.MarshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := UnmarshalProto(data[:len(data)-1]); err == nil {
+		t.Fatal("should error for truncated data")
+	}
+
+	// unknown varint field 7 is skipped
+	extra := append(appendProtoVarint(nil, 7<<3|protoVarint), 42)
+	if _, err := UnmarshalProto(append(data, extra...)); err != nil {
+		t.Fatal(err)
+	}
+
+	// an edge to a missing vertex
+	bad := appendProtoField(nil, 5, appendProtoString(nil, 2, "missing"))
+	if _, err := UnmarshalProto(bad); err == nil {
+		t.Fatal("should error for an unknown vertex")
+	}
+}
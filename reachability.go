@@ -0,0 +1,144 @@
+package dag
+
+// Reachable reports whether to can be reached by following edges down from
+// from; that is, whether from depends on to, directly or indirectly.
+//
+// The first call builds an index of the descendants of every vertex, which
+// is reused by later calls until the graph is next modified. This makes
+// Reachable suitable for answering many queries against a graph which
+// rarely changes. Reachable may be called from several goroutines at once,
+// as long as the graph isn't being changed. If the graph contains a cycle,
+// no index can be built and each call falls back to walking the graph.
+//
+// Complexity: O(1) once indexed, O(V(V+E)/64) to build the index
+func (g *AcyclicGraph) Reachable(from, to Vertex) bool {
+	r := g.reachIndex()
+	if r.cyclic {
+		found := false
+		g.DepthFirstWalk(g.downEdgesNoCopy(from), func(v Vertex, d int) error {
+			if hashcode(v) == hashcode(to) {
+				found = true
+				return errStopWalk
+			}
+			return nil
+		})
+		return found
+	}
+
+	return r.reaches(from, to)
+}
+
+// reachIndex returns the reachability index of the graph, building it if the
+// graph has changed since it was last built. It is safe to call from
+// concurrent readers of the graph.
+func (g *AcyclicGraph) reachIndex() *reachIndex {
+	g.init()
+	g.indexLock.Lock()
+	defer g.indexLock.Unlock()
+	if g.reach == nil {
+		g.reach = newReachIndex(g.adjacencyLocked())
+	}
+	return g.reach
+}
+
+// ReachableWith is like Reachable, but only follows the edges for which
+// edgeFilter returns true, such as to ask whether from depends on to through
+// "runtime" edges alone. Where there are several edges between two vertices,
+// it is enough for one of them to pass. A nil edgeFilter follows every edge.
+//
+// Unlike Reachable, no index is kept, so each call walks the graph.
+//
+// Complexity: O(V+E)
+func (g *AcyclicGraph) ReachableWith(from, to Vertex, edgeFilter func(Edge) bool) bool {
+	if edgeFilter == nil {
+		return g.Reachable(from, to)
+	}
+
+	seen := make(Set)
+	stack := []Vertex{from}
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for _, e := range g.EdgesFrom(v) {
+			t := e.Target()
+			if seen.Include(t) || !edgeFilter(e) {
+				continue
+			}
+			if hashcode(t) == hashcode(to) {
+				return true
+			}
+			seen.Add(t)
+			stack = append(stack, t)
+		}
+	}
+	return false
+}
+
+// reachIndex records the descendants of each vertex of the graph as a
+// bitset, indexed by the numbers of the vertices in adj.
+type reachIndex struct {
+	adj         *adjacency
+	descendants []bitset
+
+	// cyclic is set when the graph could not be indexed because it contains
+	// a cycle.
+	cyclic bool
+}
+
+// newReachIndex builds the reachability index of the graph with adjacency a.
+func newReachIndex(a *adjacency) *reachIndex {
+	order, ok := a.topologicalOrder()
+	if !ok {
+		return &reachIndex{cyclic: true}
+	}
+
+	// The targets of every edge are ordered first, so the descendants of
+	// every target are complete by the time its sources are reached.
+	r := &reachIndex{adj: a, descendants: make([]bitset, a.n)}
+	for _, i := range order {
+		bits := newBitset(a.n)
+		for _, j := range a.targets(i) {
+			if j >= a.n {
+				// edge to a vertex that isn't in the graph
+				continue
+			}
+			bits.set(j)
+			bits.union(r.descendants[j])
+		}
+		r.descendants[i] = bits
+	}
+
+	return r
+}
+
+// reaches reports whether to is among the descendants of from.
+func (r *reachIndex) reaches(from, to Vertex) bool {
+	i, j := r.adj.id(from), r.adj.id(to)
+	if i < 0 || i >= r.adj.n || j < 0 || j >= r.adj.n {
+		return false
+	}
+	return r.descendants[i].has(j)
+}
+
+// bitset is a fixed size set of small integers.
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) set(i int) {
+	b[i/64] |= 1 << uint(i%64)
+}
+
+func (b bitset) has(i int) bool {
+	return b[i/64]&(1<<uint(i%64)) != 0
+}
+
+// union adds every member of other to b.
+func (b bitset) union(other bitset) {
+	for i := range other {
+		b[i] |= other[i]
+	}
+}
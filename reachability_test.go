@@ -0,0 +1,178 @@
+package dag
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAcyclicGraphReachable(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+
+	cases := []struct {
+		From, To Vertex
+		Expected bool
+	}{
+		{1, 2, true},
+		{1, 3, true},
+		{2, 3, true},
+		{3, 1, false},
+		{1, 4, false},
+		{1, 1, false},
+		{1, 5, false},
+	}
+	for _, tc := range cases {
+		if actual := g.Reachable(tc.From, tc.To); actual != tc.Expected {
+			t.Fatalf("Reachable(%v, %v): expected %t, got %t", tc.From, tc.To, tc.Expected, actual)
+		}
+	}
+}
+
+func TestAcyclicGraphReachableWith(t *testing.T) {
+	var g AcyclicGraph
+	for i := 1; i <= 4; i++ {
+		g.Add(i)
+	}
+	g.Connect(BasicLabeledEdge(1, 2, "runtime"))
+	g.Connect(BasicLabeledEdge(1, 2, "build"))
+	g.Connect(BasicLabeledEdge(2, 3, "build"))
+	g.Connect(BasicLabeledEdge(1, 4, "build"))
+	g.Connect(BasicLabeledEdge(4, 3, "runtime"))
+
+	runtime := func(e Edge) bool { return edgeLabel(e) == "runtime" }
+	cases := []struct {
+		From, To Vertex
+		Expected bool
+	}{
+		{1, 2, true},
+		{1, 3, false},
+		{4, 3, true},
+		{2, 3, false},
+		{1, 1, false},
+		{1, 5, false},
+	}
+	for _, tc := range cases {
+		if actual := g.ReachableWith(tc.From, tc.To, runtime); actual != tc.Expected {
+			t.Fatalf("ReachableWith(%v, %v): expected %t, got %t", tc.From, tc.To, tc.Expected, actual)
+		}
+	}
+
+	if !g.ReachableWith(1, 3, nil) {
+		t.Fatal("a nil filter should follow every edge")
+	}
+
+	start := make(Set)
+	start.Add(1)
+	var visited []Vertex
+	err := g.DepthFirstWalkFiltered(start, runtime, func(v Vertex, d int) error {
+		visited = append(visited, v)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visited) != 2 || visited[1] != 2 {
+		t.Fatalf("bad visits: %#v", visited)
+	}
+}
+
+func TestAcyclicGraphReachable_invalidate(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+
+	if g.Reachable(1, 3) {
+		t.Fatal("3 should not be reachable yet")
+	}
+
+	g.Connect(BasicEdge(2, 3))
+	if !g.Reachable(1, 3) {
+		t.Fatal("3 should be reachable after Connect")
+	}
+
+	g.RemoveEdge(BasicEdge(1, 2))
+	if g.Reachable(1, 3) {
+		t.Fatal("3 should not be reachable after RemoveEdge")
+	}
+
+	g.Connect(BasicEdge(1, 2))
+	g.Remove(2)
+	if g.Reachable(1, 3) {
+		t.Fatal("3 should not be reachable after Remove")
+	}
+
+	g.Add(4)
+	g.Connect(BasicEdge(1, 4))
+	if !g.Reachable(1, 4) {
+		t.Fatal("4 should be reachable after Add")
+	}
+}
+
+// Readers may share a graph, and the first of them builds the index.
+func TestAcyclicGraphReachable_concurrent(t *testing.T) {
+	var g AcyclicGraph
+	for i := 0; i < 100; i++ {
+		g.Add(i)
+		if i > 0 {
+			g.Connect(BasicEdge(i, i-1))
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if !g.Reachable(99, i) || g.Reachable(i, 99) {
+				t.Errorf("bad reachability between 99 and %d", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestAcyclicGraphReachable_cycle(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 1))
+	g.Connect(BasicEdge(2, 3))
+
+	if !g.Reachable(1, 3) {
+		t.Fatal("3 should be reachable")
+	}
+	if g.Reachable(3, 1) {
+		t.Fatal("1 should not be reachable")
+	}
+}
+
+func TestBitset(t *testing.T) {
+	b := newBitset(130)
+	b.set(0)
+	b.set(64)
+	b.set(129)
+
+	other := newBitset(130)
+	other.set(5)
+	b.union(other)
+
+	for _, i := range []int{0, 5, 64, 129} {
+		if !b.has(i) {
+			t.Fatalf("expected %d to be set", i)
+		}
+	}
+	for _, i := range []int{1, 63, 65, 128} {
+		if b.has(i) {
+			t.Fatalf("expected %d to be unset", i)
+		}
+	}
+}
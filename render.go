@@ -0,0 +1,119 @@
+package dag
+
+import (
+	"bufio"
+	"io"
+	"sort"
+)
+
+// TextOpts are the options for rendering a Graph as text.
+type TextOpts struct {
+	// ASCII draws the tree with plain ASCII characters rather than Unicode
+	// box-drawing characters, for terminals which can't display them.
+	ASCII bool
+
+	// MaxDepth limits how many levels below the roots are drawn. Zero or a
+	// negative number has no limit.
+	MaxDepth int
+}
+
+// Render writes the graph as an indented tree, for showing its structure in
+// a terminal. Each root is drawn with its dependencies below it, in order of
+// VertexName. A vertex with more than one dependent is expanded the first
+// time it is drawn, and marked with "(*)" after that, which also stops
+// cycles from being drawn forever. Vertices in a cycle with no root are drawn
+// as roots themselves.
+func (g *Graph) Render(w io.Writer, opts *TextOpts) error {
+	if opts == nil {
+		opts = &TextOpts{}
+	}
+
+	r := &textRenderer{
+		g:        g,
+		opts:     opts,
+		w:        bufio.NewWriter(w),
+		expanded: make(Set),
+	}
+
+	// Vertices which can't be reached from a root, because they are in a
+	// cycle, are drawn as roots too.
+	reached := make(Set)
+	vs := g.SortedVertices()
+	for _, v := range vs {
+		if g.upEdgesNoCopy(v).Len() == 0 {
+			g.addDescendants(v, reached)
+			r.render(v, "", "", 0)
+		}
+	}
+	for _, v := range vs {
+		if !reached.Include(v) {
+			g.addDescendants(v, reached)
+			r.render(v, "", "", 0)
+		}
+	}
+
+	return r.w.Flush()
+}
+
+// textRenderer holds the state of a call to Render.
+type textRenderer struct {
+	g    *Graph
+	opts *TextOpts
+	w    *bufio.Writer
+
+	// expanded records the vertices which have had their dependencies
+	// written.
+	expanded Set
+}
+
+// render writes v and, if it hasn't been drawn yet, its dependencies. prefix
+// is written before v itself, and indent before each of its dependencies.
+func (r *textRenderer) render(v Vertex, prefix, indent string, depth int) {
+	r.w.WriteString(prefix)
+	r.w.WriteString(VertexName(v))
+	if r.expanded.Include(v) {
+		r.w.WriteString(" (*)\n")
+		return
+	}
+	r.w.WriteString("\n")
+
+	if r.opts.MaxDepth > 0 && depth >= r.opts.MaxDepth {
+		return
+	}
+	r.expanded.Add(v)
+
+	branch, last, pipe, space := "├── ", "└── ", "│   ", "    "
+	if r.opts.ASCII {
+		branch, last, pipe = "|-- ", "`-- ", "|   "
+	}
+
+	var deps []Vertex
+	for _, dep := range r.g.downEdgesNoCopy(v) {
+		deps = append(deps, dep)
+	}
+	sort.Sort(byVertexName(deps))
+	for i, dep := range deps {
+		if i == len(deps)-1 {
+			r.render(dep, indent+last, indent+space, depth+1)
+		} else {
+			r.render(dep, indent+branch, indent+pipe, depth+1)
+		}
+	}
+}
+
+// addDescendants adds v and all of its descendants to s, stopping at vertices which
+// are already in s.
+func (g *Graph) addDescendants(v Vertex, s Set) {
+	stack := []Vertex{v}
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if s.Include(v) {
+			continue
+		}
+		s.Add(v)
+		for _, dep := range g.downEdgesNoCopy(v) {
+			stack = append(stack, dep)
+		}
+	}
+}
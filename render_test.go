@@ -0,0 +1,76 @@
+package dag
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGraphRender(t *testing.T) {
+	var g Graph
+	for _, v := range []string{"a", "b", "c", "d", "e", "x", "y"} {
+		g.Add(v)
+	}
+	g.Connect(BasicEdge("a", "b"))
+	g.Connect(BasicEdge("a", "c"))
+	g.Connect(BasicEdge("b", "d"))
+	g.Connect(BasicEdge("c", "d"))
+	g.Connect(BasicEdge("d", "e"))
+	g.Connect(BasicEdge("x", "y"))
+	g.Connect(BasicEdge("y", "x"))
+
+	cases := map[string]struct {
+		opts     *TextOpts
+		expected string
+	}{
+		"default":  {nil, testGraphRenderStr},
+		"ascii":    {&TextOpts{ASCII: true}, testGraphRenderASCIIStr},
+		"maxDepth": {&TextOpts{MaxDepth: 1}, testGraphRenderMaxDepthStr},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := g.Render(&buf, tc.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			actual := strings.TrimSpace(buf.String())
+			expected := strings.TrimSpace(tc.expected)
+			if actual != expected {
+				t.Fatalf("bad:\n%s", actual)
+			}
+		})
+	}
+}
+
+const testGraphRenderStr = `
+a
+├── b
+│   └── d
+│       └── e
+└── c
+    └── d (*)
+x
+└── y
+    └── x (*)
+`
+
+const testGraphRenderASCIIStr = `
+a
+|-- b
+|   ` + "`" + `-- d
+|       ` + "`" + `-- e
+` + "`" + `-- c
+    ` + "`" + `-- d (*)
+x
+` + "`" + `-- y
+    ` + "`" + `-- x (*)
+`
+
+const testGraphRenderMaxDepthStr = `
+a
+├── b
+└── c
+x
+└── y
+`
@@ -0,0 +1,107 @@
+package dag
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// Prioritized can be implemented by a Vertex to be visited ahead of other
+// vertices when several are waiting for the same concurrency limit. Vertices
+// with a higher priority go first, and those without Prioritized have a
+// priority of zero. Vertices with the same priority go in the order they
+// became ready. Priorities have no effect on vertices which aren't limited.
+type Prioritized interface {
+	Priority() int
+}
+
+// vertexPriority returns the priority of v, defaulting to zero for vertices
+// which don't implement Prioritized.
+func vertexPriority(v Vertex) int {
+	if pv, ok := v.(Prioritized); ok {
+		return pv.Priority()
+	}
+	return 0
+}
+
+// semaphore limits how many holders it has at once. When it is full, the
+// waiting holders are let in by priority, and then in the order they arrived.
+type semaphore struct {
+	lock    sync.Mutex
+	limit   int
+	active  int
+	waiting semaphoreQueue
+	seq     int
+}
+
+func newSemaphore(limit int) *semaphore {
+	return &semaphore{limit: limit}
+}
+
+// Acquire waits until there is room in the semaphore.
+func (s *semaphore) Acquire(priority int) {
+	s.lock.Lock()
+	if s.active < s.limit && len(s.waiting) == 0 {
+		s.active++
+		s.lock.Unlock()
+		return
+	}
+
+	w := &semaphoreWaiter{priority: priority, seq: s.seq, ready: make(chan struct{})}
+	s.seq++
+	heap.Push(&s.waiting, w)
+	s.lock.Unlock()
+
+	<-w.ready
+}
+
+// Release makes room for the waiter with the highest priority, if any.
+func (s *semaphore) Release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.waiting) > 0 {
+		// hand over the slot directly, so that nobody can take it first
+		w := heap.Pop(&s.waiting).(*semaphoreWaiter)
+		close(w.ready)
+		return
+	}
+	s.active--
+}
+
+// Waiting returns how many holders are waiting for room.
+func (s *semaphore) Waiting() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.waiting)
+}
+
+type semaphoreWaiter struct {
+	priority int
+	seq      int
+	ready    chan struct{}
+}
+
+// semaphoreQueue implements heap.Interface, with the waiter to be let in
+// next first.
+type semaphoreQueue []*semaphoreWaiter
+
+func (q semaphoreQueue) Len() int { return len(q) }
+func (q semaphoreQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q semaphoreQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *semaphoreQueue) Push(x interface{}) {
+	*q = append(*q, x.(*semaphoreWaiter))
+}
+
+func (q *semaphoreQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	*q = old[:n-1]
+	return w
+}
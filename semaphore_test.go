@@ -0,0 +1,104 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSemaphore_priority(t *testing.T) {
+	s := newSemaphore(1)
+	s.Acquire(0)
+
+	var lock sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for _, p := range []int{1, 3, 2, 3} {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			s.Acquire(p)
+			lock.Lock()
+			order = append(order, p)
+			lock.Unlock()
+			s.Release()
+		}(p)
+	}
+
+	if !testWaitFor(func() bool { return s.Waiting() == 4 }) {
+		t.Fatal("timed out waiting for the semaphore")
+	}
+	s.Release()
+	wg.Wait()
+
+	expected := []int{3, 3, 2, 1}
+	if fmt.Sprint(order) != fmt.Sprint(expected) {
+		t.Fatalf("bad order: %v", order)
+	}
+}
+
+func TestWalker_priority(t *testing.T) {
+	var g AcyclicGraph
+	for i := 0; i < 4; i++ {
+		g.Add(&testPriorityVertex{name: fmt.Sprintf("p%d", i), priority: i})
+	}
+
+	var w *Walker
+	var lock sync.Mutex
+	var visits []int
+	cb := func(v Vertex) Diagnostics {
+		lock.Lock()
+		visits = append(visits, v.(*testPriorityVertex).priority)
+		first := len(visits) == 1
+		lock.Unlock()
+
+		// hold the only slot until the others are all waiting for it
+		if first {
+			ok := testWaitFor(func() bool {
+				w.semaphoresLock.Lock()
+				sem := w.semaphores["x"]
+				w.semaphoresLock.Unlock()
+				return sem.Waiting() == 3
+			})
+			if !ok {
+				t.Error("timed out waiting for the semaphore")
+			}
+		}
+		return nil
+	}
+
+	w = &Walker{Callback: cb, ConcurrencyLimits: map[string]int{"x": 1}}
+	w.Update(&g)
+	if err := w.Wait(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	rest := visits[1:]
+	if !sort.SliceIsSorted(rest, func(i, j int) bool { return rest[i] > rest[j] }) {
+		t.Fatalf("waiting vertices weren't visited by priority: %v", visits)
+	}
+}
+
+type testPriorityVertex struct {
+	name     string
+	priority int
+}
+
+func (v *testPriorityVertex) Name() string             { return v.name }
+func (v *testPriorityVertex) ConcurrencyClass() string { return "x" }
+func (v *testPriorityVertex) Priority() int            { return v.priority }
+
+// testWaitFor waits for cond to become true, returning false if it takes
+// too long.
+func testWaitFor(cond func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
@@ -1,17 +1,30 @@
 package dag
 
+import "sort"
+
 // Set is a set data structure.
 type Set map[interface{}]interface{}
 
 // Hashable is the interface used by set to get the hash code of a value.
 // If this isn't given, then the value of the item being added to the set
 // itself is used as the comparison value.
+//
+// Vertices are identified by their hash code throughout the package, so a
+// Vertex can implement Hashable to have distinct values, such as two pointers
+// to equivalent structs, treated as the same vertex by Add, Connect and the
+// walks.
 type Hashable interface {
 	Hashcode() interface{}
 }
 
 // hashcode returns the hashcode used for set elements.
 func hashcode(v interface{}) interface{} {
+	// Strings are by far the most common vertices, and checking for them
+	// first is cheaper than looking for a Hashcode method.
+	if _, ok := v.(string); ok {
+		return v
+	}
+
 	if h, ok := v.(Hashable); ok {
 		return h.Hashcode()
 	}
@@ -35,6 +48,25 @@ func (s Set) Include(v interface{}) bool {
 	return ok
 }
 
+// Contains returns true/false of whether a value is in the set. It is the
+// same as Include.
+func (s Set) Contains(v interface{}) bool {
+	return s.Include(v)
+}
+
+// Union returns a set with the elements of both s and other. Where both have
+// an element with the same hash code, the one in s is kept.
+func (s Set) Union(other Set) Set {
+	result := make(Set, len(s)+len(other))
+	for k, v := range other {
+		result[k] = v
+	}
+	for k, v := range s {
+		result[k] = v
+	}
+	return result
+}
+
 // Intersection computes the set intersection with other.
 func (s Set) Intersection(other Set) Set {
 	result := make(Set)
@@ -103,6 +135,27 @@ func (s Set) List() []interface{} {
 	return r
 }
 
+// SortedList returns the list of set elements sorted by VertexName, which
+// unlike List is in the same order each time.
+func (s Set) SortedList() []interface{} {
+	r := s.List()
+	sort.Slice(r, func(i, j int) bool {
+		return VertexName(r[i]) < VertexName(r[j])
+	})
+	return r
+}
+
+// TypedList returns the list of set elements as a slice of T, for sets which
+// only hold values of type T, such as the vertices of a graph. It panics if
+// an element isn't a T.
+func TypedList[T any](s Set) []T {
+	r := make([]T, 0, len(s))
+	for _, v := range s {
+		r = append(r, v.(T))
+	}
+	return r
+}
+
 // Copy returns a shallow copy of the set.
 func (s Set) Copy() Set {
 	c := make(Set, len(s))
@@ -2,6 +2,8 @@ package dag
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
 	"testing"
 )
 
@@ -129,6 +131,46 @@ func TestSetCopy(t *testing.T) {
 
 }
 
+func TestSetUnion(t *testing.T) {
+	a := make(Set)
+	a.Add(1)
+	a.Add(2)
+	b := make(Set)
+	b.Add(2)
+	b.Add(3)
+
+	u := a.Union(b)
+	if u.Len() != 3 || !u.Contains(1) || !u.Contains(2) || !u.Contains(3) {
+		t.Fatalf("bad: %#v", u.List())
+	}
+	if a.Len() != 2 || b.Len() != 2 {
+		t.Fatal("union modified its operands")
+	}
+
+	var empty Set
+	if empty.Union(nil).Len() != 0 {
+		t.Fatal("union of nil sets should be empty")
+	}
+}
+
+func TestSetSortedList(t *testing.T) {
+	s := make(Set)
+	for _, v := range []string{"c", "a", "b"} {
+		s.Add(v)
+	}
+
+	actual := s.SortedList()
+	expected := []interface{}{"a", "b", "c"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	strs := TypedList[string](s)
+	if len(strs) != 3 {
+		t.Fatalf("bad: %#v", strs)
+	}
+}
+
 func makeSet(n int) Set {
 	ret := make(Set, n)
 	for i := 0; i < n; i++ {
@@ -156,3 +198,19 @@ func BenchmarkSetIntersection_100000_100(b *testing.B) {
 		large.Intersection(small)
 	}
 }
+
+func BenchmarkSetInclude_string(b *testing.B) {
+	s := make(Set)
+	keys := make([]Vertex, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		s.Add(keys[i])
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, k := range keys {
+			s.Include(k)
+		}
+	}
+}
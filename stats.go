@@ -0,0 +1,98 @@
+package dag
+
+// GraphStats summarizes the shape of a graph.
+type GraphStats struct {
+	Vertices int
+	Edges    int
+
+	// Roots and Leaves are the number of vertices with no edges to them, and
+	// no edges from them, respectively.
+	Roots  int
+	Leaves int
+
+	// MaxInDegree and MaxOutDegree are the most edges to and from a single
+	// vertex, and AvgInDegree and AvgOutDegree the mean. The averages are
+	// always equal, but both are given for symmetry.
+	MaxInDegree  int
+	MaxOutDegree int
+	AvgInDegree  float64
+	AvgOutDegree float64
+
+	// Depth is the number of edges in the longest path through the graph,
+	// or -1 if the graph has a cycle.
+	Depth int
+}
+
+// InDegree returns the number of edges to v. This is the number of vertices
+// depending on it, unless some of them have several edges to it with
+// different labels, each of which is counted.
+func (g *Graph) InDegree(v Vertex) int {
+	n := 0
+	for _, source := range g.upEdgesNoCopy(v) {
+		n += g.countEdgesBetween(source, v)
+	}
+	return n
+}
+
+// OutDegree returns the number of edges from v. This is the number of
+// vertices it depends on, unless it has several edges to some of them with
+// different labels, each of which is counted.
+func (g *Graph) OutDegree(v Vertex) int {
+	n := 0
+	for _, target := range g.downEdgesNoCopy(v) {
+		n += g.countEdgesBetween(v, target)
+	}
+	return n
+}
+
+// countEdgesBetween is len(g.EdgesBetween(source, target)), without building
+// the list.
+func (g *Graph) countEdgesBetween(source, target Vertex) int {
+	n := len(g.pairs[edgePair(source, target)])
+	if _, ok := g.edges[hashcode(BasicEdge(source, target))]; ok {
+		n++
+	}
+	return n
+}
+
+// Stats returns statistics about the shape of the graph, such as the most
+// depended on vertex, for spotting bottlenecks.
+//
+// Complexity: O(V log V + E)
+func (g *Graph) Stats() *GraphStats {
+	s := &GraphStats{
+		Vertices: g.vertices.Len(),
+		Edges:    g.edges.Len(),
+	}
+
+	for _, v := range g.vertices {
+		in, out := g.InDegree(v), g.OutDegree(v)
+		if in == 0 {
+			s.Roots++
+		}
+		if out == 0 {
+			s.Leaves++
+		}
+		if in > s.MaxInDegree {
+			s.MaxInDegree = in
+		}
+		if out > s.MaxOutDegree {
+			s.MaxOutDegree = out
+		}
+	}
+
+	if s.Vertices > 0 {
+		s.AvgInDegree = float64(s.Edges) / float64(s.Vertices)
+		s.AvgOutDegree = s.AvgInDegree
+	}
+
+	levels, err := (&AcyclicGraph{Graph: *g}).TopologicalLevels()
+	switch {
+	case err != nil:
+		s.Depth = -1
+	case len(levels) > 0:
+		s.Depth = len(levels) - 1
+	}
+
+	return s
+}
@@ -0,0 +1,53 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphStats(t *testing.T) {
+	var g Graph
+	for i := 1; i <= 5; i++ {
+		g.Add(i)
+	}
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(1, 3))
+	g.Connect(BasicEdge(2, 4))
+	g.Connect(BasicEdge(3, 4))
+
+	expected := &GraphStats{
+		Vertices:     5,
+		Edges:        4,
+		Roots:        2,
+		Leaves:       2,
+		MaxInDegree:  2,
+		MaxOutDegree: 2,
+		AvgInDegree:  0.8,
+		AvgOutDegree: 0.8,
+		Depth:        2,
+	}
+	if actual := g.Stats(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	if g.InDegree(4) != 2 || g.OutDegree(4) != 0 || g.OutDegree(1) != 2 {
+		t.Fatal("bad degrees")
+	}
+
+	// each labeled edge between the same vertices is counted
+	g.Connect(BasicLabeledEdge(1, 2, "build"))
+	if g.OutDegree(1) != 3 || g.InDegree(2) != 2 {
+		t.Fatalf("bad degrees with labeled edges: %d, %d", g.OutDegree(1), g.InDegree(2))
+	}
+	g.RemoveEdge(BasicLabeledEdge(1, 2, "build"))
+
+	g.Connect(BasicEdge(4, 1))
+	if depth := g.Stats().Depth; depth != -1 {
+		t.Fatalf("cyclic graph should have no depth, got %d", depth)
+	}
+
+	var empty Graph
+	if actual := empty.Stats(); !reflect.DeepEqual(actual, &GraphStats{}) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
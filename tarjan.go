@@ -1,5 +1,10 @@
 package dag
 
+import (
+	"sort"
+	"strings"
+)
+
 // StronglyConnected returns the list of strongly connected components
 // within the Graph g. This information is primarily used by this package
 // for cycle detection, but strongly connected components have widespread
@@ -19,6 +24,106 @@ func StronglyConnected(g *Graph) [][]Vertex {
 	return acct.SCC
 }
 
+// Component is a vertex standing in for a strongly connected component of
+// more than one vertex, in a graph built by Condense.
+type Component struct {
+	// Vertices are the members of the component, sorted by VertexName.
+	Vertices []Vertex
+}
+
+// Name returns the names of the members, such as "{a, b}".
+func (c *Component) Name() string {
+	names := make([]string, len(c.Vertices))
+	for i, v := range c.Vertices {
+		names[i] = VertexName(v)
+	}
+	return "{" + strings.Join(names, ", ") + "}"
+}
+
+// Condense returns an acyclic graph with each strongly connected component
+// of g collapsed into a single *Component vertex, along with a mapping from
+// the hashcode of each vertex of the result to the vertices of g it
+// represents. Vertices that aren't part of a cycle are kept as they are, and
+// map to themselves. Edges within a component, including self references,
+// are dropped.
+func (g *Graph) Condense() (*AcyclicGraph, map[Vertex][]Vertex) {
+	result := &AcyclicGraph{}
+	members := make(map[Vertex][]Vertex)
+	condensed := make(map[interface{}]Vertex)
+
+	for _, scc := range StronglyConnected(g) {
+		var v Vertex = scc[0]
+		if len(scc) > 1 {
+			scc = append([]Vertex(nil), scc...)
+			sort.Sort(byVertexName(scc))
+			v = &Component{Vertices: scc}
+		}
+
+		result.Add(v)
+		members[hashcode(v)] = scc
+		for _, m := range scc {
+			condensed[hashcode(m)] = v
+		}
+	}
+
+	for _, e := range g.Edges() {
+		source, sok := condensed[hashcode(e.Source())]
+		target, tok := condensed[hashcode(e.Target())]
+		if !sok || !tok || hashcode(source) == hashcode(target) {
+			continue
+		}
+		result.Connect(copyEdge(e, source, target))
+	}
+
+	return result, members
+}
+
+// ConnectedComponents returns the weakly connected components of the graph:
+// the groups of vertices connected to each other by edges in either
+// direction. A graph expected to be a single dependency graph having more
+// than one component is made of unrelated islands, which can be walked or
+// rendered independently with Filter.
+//
+// The vertices of each component are sorted by VertexName, and the components
+// are sorted by the name of their first vertex.
+//
+// Complexity: O(V+E)
+func (g *Graph) ConnectedComponents() [][]Vertex {
+	var result [][]Vertex
+	seen := make(Set)
+	for _, v := range g.vertices {
+		if seen.Include(v) {
+			continue
+		}
+		seen.Add(v)
+
+		var component []Vertex
+		stack := []Vertex{v}
+		for len(stack) > 0 {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			component = append(component, v)
+
+			for _, next := range []Set{g.downEdgesNoCopy(v), g.upEdgesNoCopy(v)} {
+				for _, n := range next {
+					if !seen.Include(n) {
+						seen.Add(n)
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+
+		sort.Sort(byVertexName(component))
+		result = append(result, component)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return VertexName(result[i][0]) < VertexName(result[j][0])
+	})
+	return result
+}
+
 func stronglyConnected(acct *sccAcct, g *Graph, v Vertex) int {
 	// Initial vertex visit
 	index := acct.visit(v)
@@ -20,6 +20,97 @@ func TestGraphStronglyConnected(t *testing.T) {
 	}
 }
 
+func TestGraphCondense(t *testing.T) {
+	var g Graph
+	for _, v := range []string{"a", "b", "c", "d", "e"} {
+		g.Add(v)
+	}
+	// a and b, and c and d, are cycles, with a and e depending on c
+	g.Connect(BasicEdge("a", "b"))
+	g.Connect(BasicEdge("b", "a"))
+	g.Connect(BasicEdge("c", "d"))
+	g.Connect(BasicEdge("d", "c"))
+	g.Connect(BasicEdge("a", "c"))
+	g.Connect(BasicEdge("e", "c"))
+	g.Connect(BasicEdge("e", "e"))
+
+	dag, members := g.Condense()
+	if _, err := dag.TopologicalSort(); err != nil {
+		t.Fatal(err)
+	}
+
+	actual := strings.TrimSpace(dag.String())
+	expected := strings.TrimSpace(testGraphCondenseStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+
+	if len(members) != 3 {
+		t.Fatalf("bad members: %#v", members)
+	}
+	for v, vs := range members {
+		if c, ok := v.(*Component); ok {
+			if len(vs) != 2 || len(c.Vertices) != 2 {
+				t.Fatalf("bad component %s: %#v", c.Name(), vs)
+			}
+		} else if len(vs) != 1 || vs[0] != v {
+			t.Fatalf("bad members for %s: %#v", VertexName(v), vs)
+		}
+	}
+}
+
+// Vertices which can't be map keys themselves are condensed by hashcode.
+func TestGraphCondense_hashable(t *testing.T) {
+	a, b, c := sliceVertex{"a"}, sliceVertex{"b"}, sliceVertex{"c"}
+	var g Graph
+	g.Add(a)
+	g.Add(b)
+	g.Add(c)
+	g.Connect(BasicEdge(a, b))
+	g.Connect(BasicEdge(b, a))
+	g.Connect(BasicEdge(b, c))
+	g.Connect(BasicEdge(c, c))
+
+	dag, members := g.Condense()
+	if len(dag.Vertices()) != 2 || len(dag.Edges()) != 1 {
+		t.Fatalf("bad condensed graph:\n%s", dag.String())
+	}
+	if vs := members[hashcode(c)]; len(vs) != 1 || hashcode(vs[0]) != hashcode(c) {
+		t.Fatalf("bad members for c: %#v", vs)
+	}
+	if len(members) != 2 {
+		t.Fatalf("bad members: %#v", members)
+	}
+}
+
+// sliceVertex is a Hashable vertex which can't be compared with ==.
+type sliceVertex []string
+
+func (v sliceVertex) Hashcode() interface{} { return strings.Join(v, "/") }
+
+func TestGraphConnectedComponents(t *testing.T) {
+	var g Graph
+	for _, v := range []string{"a", "b", "c", "d", "e", "f"} {
+		g.Add(v)
+	}
+	// a, b and c are connected through their dependency on c, even though
+	// there is no path between a and b
+	g.Connect(BasicEdge("a", "c"))
+	g.Connect(BasicEdge("b", "c"))
+	g.Connect(BasicEdge("e", "d"))
+
+	actual := testSCCStr(g.ConnectedComponents())
+	expected := strings.TrimSpace(testGraphConnectedComponentsStr)
+	if actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+
+	var empty Graph
+	if cs := empty.ConnectedComponents(); len(cs) != 0 {
+		t.Fatalf("bad: %#v", cs)
+	}
+}
+
 func TestGraphStronglyConnected_two(t *testing.T) {
 	var g Graph
 	g.Add(1)
@@ -84,3 +175,17 @@ const testGraphStronglyConnectedThreeStr = `
 3
 4,5,6
 `
+
+const testGraphCondenseStr = `
+e
+  {c, d}
+{a, b}
+  {c, d}
+{c, d}
+`
+
+const testGraphConnectedComponentsStr = `
+a,b,c
+d,e
+f
+`
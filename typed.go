@@ -0,0 +1,142 @@
+package dag
+
+// TypedGraph is a type-safe wrapper around AcyclicGraph for graphs where
+// every vertex has the same type V. Vertices are still identified using
+// hashcode, so V may implement Hashable.
+//
+// The zero value is an empty graph ready to use.
+type TypedGraph[V comparable] struct {
+	g AcyclicGraph
+}
+
+// AcyclicGraph returns the underlying graph, for use with the parts of this
+// package that are not type-aware, such as Dot or Walker. Every vertex added
+// to the returned graph must be of type V.
+func (t *TypedGraph[V]) AcyclicGraph() *AcyclicGraph {
+	return &t.g
+}
+
+// Add adds a vertex to the graph. This is safe to call multiple time with
+// the same vertex.
+func (t *TypedGraph[V]) Add(v V) V {
+	t.g.Add(v)
+	return v
+}
+
+// Remove removes a vertex from the graph, along with any edges with this
+// vertex as a source or target.
+func (t *TypedGraph[V]) Remove(v V) {
+	t.g.Remove(v)
+}
+
+// HasVertex checks if the given vertex is present in the graph.
+func (t *TypedGraph[V]) HasVertex(v V) bool {
+	return t.g.HasVertex(v)
+}
+
+// Connect adds an edge from source to target, meaning that source depends
+// on target.
+func (t *TypedGraph[V]) Connect(source, target V) {
+	t.g.Connect(BasicEdge(source, target))
+}
+
+// RemoveEdge removes the edge from source to target.
+func (t *TypedGraph[V]) RemoveEdge(source, target V) {
+	t.g.RemoveEdge(BasicEdge(source, target))
+}
+
+// HasEdge checks if there is an edge from source to target.
+func (t *TypedGraph[V]) HasEdge(source, target V) bool {
+	return t.g.HasEdge(BasicEdge(source, target))
+}
+
+// Vertices returns the list of all the vertices in the graph.
+func (t *TypedGraph[V]) Vertices() []V {
+	return TypedList[V](t.g.vertices)
+}
+
+// DownEdges returns the targets of the edges from v.
+func (t *TypedGraph[V]) DownEdges(v V) []V {
+	return TypedList[V](t.g.downEdgesNoCopy(v))
+}
+
+// UpEdges returns the sources of the edges to v.
+func (t *TypedGraph[V]) UpEdges(v V) []V {
+	return TypedList[V](t.g.upEdgesNoCopy(v))
+}
+
+// Descendants returns every vertex reachable by walking down from v.
+func (t *TypedGraph[V]) Descendants(v V) ([]V, error) {
+	s, err := t.g.Descendants(v)
+	if err != nil {
+		return nil, err
+	}
+	return TypedList[V](s), nil
+}
+
+// Ancestors returns every vertex reachable by walking up from v.
+func (t *TypedGraph[V]) Ancestors(v V) ([]V, error) {
+	s, err := t.g.Ancestors(v)
+	if err != nil {
+		return nil, err
+	}
+	return TypedList[V](s), nil
+}
+
+// TopologicalSort returns every vertex in dependency order. See
+// AcyclicGraph.TopologicalSort.
+func (t *TypedGraph[V]) TopologicalSort() ([]V, error) {
+	order, err := t.g.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]V, len(order))
+	for i, v := range order {
+		result[i] = v.(V)
+	}
+	return result, nil
+}
+
+// Validate validates the graph. See AcyclicGraph.Validate.
+func (t *TypedGraph[V]) Validate() error {
+	return t.g.Validate()
+}
+
+// Walk walks the graph in parallel, visiting each vertex once all of its
+// dependencies have been visited. See AcyclicGraph.Walk.
+func (t *TypedGraph[V]) Walk(cb func(V) Diagnostics) Diagnostics {
+	return t.g.Walk(func(v Vertex) Diagnostics {
+		return cb(v.(V))
+	})
+}
+
+// DepthFirstWalk does a depth-first walk of the graph starting from the
+// vertices in start. See AcyclicGraph.SortedDepthFirstWalk.
+func (t *TypedGraph[V]) DepthFirstWalk(start []V, f func(V, int) error) error {
+	return t.g.SortedDepthFirstWalk(typedVertices(start), func(v Vertex, d int) error {
+		return f(v.(V), d)
+	})
+}
+
+// ReverseDepthFirstWalk does a depth-first walk _up_ the graph starting from
+// the vertices in start. See AcyclicGraph.SortedReverseDepthFirstWalk.
+func (t *TypedGraph[V]) ReverseDepthFirstWalk(start []V, f func(V, int) error) error {
+	return t.g.SortedReverseDepthFirstWalk(typedVertices(start), func(v Vertex, d int) error {
+		return f(v.(V), d)
+	})
+}
+
+// String outputs some human-friendly output for the graph structure.
+func (t *TypedGraph[V]) String() string {
+	return t.g.String()
+}
+
+// typedVertices converts a slice of V into a slice of Vertex.
+func typedVertices[V comparable](vs []V) []Vertex {
+	result := make([]Vertex, len(vs))
+	for i, v := range vs {
+		result[i] = v
+	}
+	return result
+}
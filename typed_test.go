@@ -0,0 +1,74 @@
+package dag
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestTypedGraph(t *testing.T) {
+	var g TypedGraph[string]
+	g.Add("a")
+	g.Add("b")
+	g.Add("c")
+	g.Connect("a", "b")
+	g.Connect("b", "c")
+
+	if err := g.Validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	order, err := g.TopologicalSort()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{"c", "b", "a"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, order)
+	}
+
+	descendants, err := g.Descendants("a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	sort.Strings(descendants)
+	expected = []string{"b", "c"}
+	if !reflect.DeepEqual(descendants, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, descendants)
+	}
+
+	if !g.HasEdge("a", "b") {
+		t.Fatal("expected edge from a to b")
+	}
+	g.RemoveEdge("a", "b")
+	if g.HasEdge("a", "b") {
+		t.Fatal("expected edge from a to b to be removed")
+	}
+}
+
+func TestTypedGraphWalk(t *testing.T) {
+	var g TypedGraph[int]
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Connect(3, 2)
+	g.Connect(2, 1)
+
+	var visits []int
+	var lock sync.Mutex
+	diags := g.Walk(func(v int) Diagnostics {
+		lock.Lock()
+		defer lock.Unlock()
+		visits = append(visits, v)
+		return nil
+	})
+	if err := diags.Err(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []int{1, 2, 3}
+	if !reflect.DeepEqual(visits, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, visits)
+	}
+}
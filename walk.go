@@ -1,8 +1,11 @@
 package dag
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 )
 
 // Walker is used to walk every vertex of a graph in parallel.
@@ -17,6 +20,11 @@ import (
 // a vertex that has already executed has no effect. Changing edges of
 // a vertex that has already executed has no effect.
 //
+// A vertex can expand into more work by adding vertices and edges to the
+// graph and calling Update from within the Callback. Since the vertex hasn't
+// finished, Wait won't return before the new vertices are walked, and new
+// vertices that depend on it won't start until it has finished.
+//
 // Non-parallelism can be enforced by introducing a lock in your callback
 // function. However, the goroutine overhead of a walk will remain.
 // Walker will create V*2 goroutines (one for each vertex, and dependency
@@ -33,17 +41,82 @@ type Walker struct {
 	// Callback is what is called for each vertex
 	Callback WalkFunc
 
+	// CallbackCtx, if set, is called for each vertex instead of Callback,
+	// with a context that is cancelled when the vertex times out.
+	CallbackCtx WalkCtxFunc
+
 	// Reverse, if true, causes the source of an edge to depend on a target.
 	// When false (default), the target depends on the source.
 	Reverse bool
 
+	// OnError controls how the walk proceeds once a vertex returns errors.
+	// The default is OnErrorSkipDescendants.
+	OnError WalkErrorPolicy
+
+	// ConcurrencyLimits is the maximum number of vertices of each
+	// concurrency class, given by ConcurrencyLimited, that may be visited at
+	// once. Classes without a positive limit are unlimited. Vertices waiting
+	// for their class are visited in order of Prioritized.
+	ConcurrencyLimits map[string]int
+
+	// Parallelism is the maximum number of vertices that may be visited at
+	// once, across all concurrency classes. Zero means no limit.
+	Parallelism int
+
+	// Limiter, if set, limits how many vertices are visited at once instead
+	// of Parallelism. A Limiter can be shared by several walks, even of
+	// different graphs, to cap their combined concurrency.
+	Limiter Limiter
+
+	// Metrics, if set, receives measurements of the walk, such as how many
+	// vertices have been visited and how long each took. See Metrics.
+	Metrics Metrics
+
+	// Completed, if set, is called for each vertex once its dependencies
+	// have been walked, and before it is visited. If it returns true, the
+	// vertex is treated as having been visited successfully without calling
+	// the callback, so that a walk re-run after an interruption only does
+	// the outstanding work. It might check a Set of the finished vertices,
+	// for example.
+	Completed func(Vertex) bool
+
+	// VertexTimeout is how long each vertex may take to be visited, unless
+	// it implements HasTimeout. Once a vertex times out, the walk carries on
+	// as if it had failed with a timeout error, and its callback is
+	// abandoned, with its context cancelled if it is a CallbackCtx. Zero
+	// means no timeout.
+	VertexTimeout time.Duration
+
+	// afterVisit, if set, is called for each vertex visited successfully,
+	// once its callback has returned and it has given up its place under
+	// the concurrency limits, and before the vertices depending on it are
+	// visited. Its diagnostics are added to those of the vertex. It is used
+	// to walk subgraphs for WalkOpts.Subgraphs.
+	afterVisit func(Vertex) Diagnostics
+
+	// started is when Update was first called, for MetricWalkDuration, and
+	// queued is the current MetricQueueDepth. Both are protected by
+	// metricsLock.
+	started     time.Time
+	queued      int
+	metricsLock sync.Mutex
+
+	// semaphores holds a semaphore for each limited concurrency class. It,
+	// and Limiter once the walk has started, are protected by
+	// semaphoresLock.
+	semaphores     map[string]*semaphore
+	semaphoresLock sync.Mutex
+
 	// changeLock must be held to modify any of the fields below. Only Update
 	// should modify these fields. Modifying them outside of Update can cause
 	// serious problems.
+	//
+	// vertexMap, and the maps below, are keyed by the hashcode of each
+	// vertex so that a Hashable vertex is matched between updates.
 	changeLock sync.Mutex
 	vertices   Set
 	edges      Set
-	vertexMap  map[Vertex]*walkerVertex
+	vertexMap  map[interface{}]*walkerVertex
 
 	// wait is done when all vertices have executed. It may become "undone"
 	// if new vertices are added.
@@ -55,9 +128,135 @@ type Walker struct {
 	// excluded from the final set.
 	//
 	// Readers and writers of either map must hold diagsLock.
-	diagsMap       map[Vertex]Diagnostics
-	upstreamFailed map[Vertex]struct{}
+	diagsMap       map[interface{}]Diagnostics
+	upstreamFailed map[interface{}]struct{}
 	diagsLock      sync.Mutex
+
+	// halted is set once a vertex has failed when OnError is OnErrorHalt,
+	// after which no more vertices are visited. It is protected by diagsLock.
+	halted bool
+}
+
+// WalkErrorPolicy determines how a Walker proceeds when visiting a vertex
+// returns errors.
+type WalkErrorPolicy int
+
+const (
+	// OnErrorSkipDescendants skips every vertex that depends on the failed
+	// vertex, while continuing to visit independent vertices.
+	OnErrorSkipDescendants WalkErrorPolicy = iota
+
+	// OnErrorContinue visits every vertex regardless of failures, once its
+	// dependencies have completed.
+	OnErrorContinue
+
+	// OnErrorHalt stops visiting new vertices as soon as any vertex fails.
+	// Vertices which are already being visited are allowed to complete.
+	OnErrorHalt
+)
+
+// WalkOpts are the options for walking a graph with WalkWithOpts.
+type WalkOpts struct {
+	// OnError controls how the walk proceeds once a vertex returns errors.
+	// The default is OnErrorSkipDescendants.
+	OnError WalkErrorPolicy
+
+	// ConcurrencyLimits limits how many vertices of each concurrency class
+	// are visited at once. See Walker.ConcurrencyLimits.
+	ConcurrencyLimits map[string]int
+
+	// VertexTimeout is how long each vertex may take to be visited. See
+	// Walker.VertexTimeout.
+	VertexTimeout time.Duration
+
+	// Parallelism is the maximum number of vertices that may be visited at
+	// once. Zero means no limit.
+	Parallelism int
+
+	// Limiter, if set, limits how many vertices are visited at once instead
+	// of Parallelism. See Walker.Limiter.
+	Limiter Limiter
+
+	// EdgeLabels limits the edges which order the walk to those with one of
+	// these labels, so that for example only "build" dependencies are waited
+	// for. An edge without a label has the empty label. The default of nil
+	// uses every edge.
+	EdgeLabels []string
+
+	// Metrics, if set, receives measurements of the walk. See
+	// Walker.Metrics.
+	Metrics Metrics
+
+	// Completed reports the vertices which are already done, and so are
+	// skipped without calling the callback. See Walker.Completed.
+	Completed func(Vertex) bool
+
+	// EdgeFilter limits the edges which order the walk to those for which it
+	// returns true. It may be combined with EdgeLabels, in which case an edge
+	// must pass both.
+	EdgeFilter func(Edge) bool
+
+	// Subgraphs, if true, walks the subgraph of each vertex implementing
+	// Subgrapher once the vertex itself has been visited successfully, with
+	// the same callback and options. The vertices depending on it are not
+	// visited until its subgraph walk has finished, and are skipped if it
+	// returns errors. The subgraph is walked after the vertex has given up
+	// its place under Parallelism or the Limiter, which are shared with the
+	// subgraph walk, and outside of its VertexTimeout, which applies to each
+	// vertex of the subgraph in turn. ConcurrencyLimits apply to each graph
+	// separately. A vertex skipped because of Completed is taken to be done
+	// along with its subgraph, which isn't walked. As with the callback, the
+	// subgraphs must not be modified during the walk.
+	Subgraphs bool
+}
+
+// Limiter limits how many vertices are visited at once. Acquire is called
+// before each vertex is visited, blocking until there is room, and Release
+// is called once it has been. A Limiter shared between walks must not be
+// used by a walk from within the callback of another, which could wait for
+// itself.
+type Limiter interface {
+	Acquire(v Vertex)
+	Release(v Vertex)
+}
+
+// NewLimiter returns a Limiter allowing n vertices to be visited at once,
+// which lets waiting vertices in by Prioritized. As with Parallelism, an n
+// of zero or less means no limit.
+func NewLimiter(n int) Limiter {
+	if n <= 0 {
+		return unlimitedLimiter{}
+	}
+	return &semaphoreLimiter{sem: newSemaphore(n)}
+}
+
+// unlimitedLimiter is a Limiter which never waits.
+type unlimitedLimiter struct{}
+
+func (unlimitedLimiter) Acquire(Vertex) {}
+func (unlimitedLimiter) Release(Vertex) {}
+
+// semaphoreLimiter is a Limiter using a semaphore.
+type semaphoreLimiter struct {
+	sem *semaphore
+}
+
+func (l *semaphoreLimiter) Acquire(v Vertex) { l.sem.Acquire(vertexPriority(v)) }
+func (l *semaphoreLimiter) Release(Vertex)   { l.sem.Release() }
+
+// HasTimeout can be implemented by a Vertex to set how long it may take to
+// be visited by a Walker, overriding Walker.VertexTimeout. A timeout of zero
+// means no timeout.
+type HasTimeout interface {
+	WalkTimeout() time.Duration
+}
+
+// ConcurrencyLimited can be implemented by a Vertex to put it in a
+// concurrency class, so that only a limited number of vertices of the class
+// are visited at once, even when the graph would allow more. This is useful
+// for vertices which need a scarce resource, such as network connections.
+type ConcurrencyLimited interface {
+	ConcurrencyClass() string
 }
 
 func (w *Walker) init() {
@@ -98,7 +297,7 @@ type walkerVertex struct {
 	// Below is not safe to read/write in parallel. This behavior is
 	// enforced by changes only happening in Update. Nothing else should
 	// ever modify these.
-	deps         map[Vertex]chan struct{}
+	deps         map[interface{}]chan struct{}
 	depsCancelCh chan struct{}
 }
 
@@ -113,6 +312,12 @@ func (w *Walker) Wait() Diagnostics {
 	// Wait for completion
 	w.wait.Wait()
 
+	w.metricsLock.Lock()
+	if !w.started.IsZero() {
+		w.metrics().Observe(MetricWalkDuration, time.Since(w.started).Seconds())
+	}
+	w.metricsLock.Unlock()
+
 	var diags Diagnostics
 	w.diagsLock.Lock()
 	for v, vDiags := range w.diagsMap {
@@ -140,6 +345,13 @@ func (w *Walker) Wait() Diagnostics {
 // time during a walk.
 func (w *Walker) Update(g *AcyclicGraph) {
 	w.init()
+
+	w.metricsLock.Lock()
+	if w.started.IsZero() {
+		w.started = time.Now()
+	}
+	w.metricsLock.Unlock()
+
 	v := make(Set)
 	e := make(Set)
 	if g != nil {
@@ -154,7 +366,7 @@ func (w *Walker) Update(g *AcyclicGraph) {
 
 	// Initialize fields
 	if w.vertexMap == nil {
-		w.vertexMap = make(map[Vertex]*walkerVertex)
+		w.vertexMap = make(map[interface{}]*walkerVertex)
 	}
 
 	// Calculate all our sets
@@ -177,11 +389,11 @@ func (w *Walker) Update(g *AcyclicGraph) {
 		info := &walkerVertex{
 			DoneCh:   make(chan struct{}),
 			CancelCh: make(chan struct{}),
-			deps:     make(map[Vertex]chan struct{}),
+			deps:     make(map[interface{}]chan struct{}),
 		}
 
 		// Add it to the map and kick off the walk
-		w.vertexMap[v] = info
+		w.vertexMap[hashcode(v)] = info
 	}
 
 	// Remove the old vertices
@@ -189,7 +401,7 @@ func (w *Walker) Update(g *AcyclicGraph) {
 		v := raw.(Vertex)
 
 		// Get the vertex info so we can cancel it
-		info, ok := w.vertexMap[v]
+		info, ok := w.vertexMap[hashcode(v)]
 		if !ok {
 			// This vertex for some reason was never in our map. This
 			// shouldn't be possible.
@@ -200,7 +412,7 @@ func (w *Walker) Update(g *AcyclicGraph) {
 		close(info.CancelCh)
 
 		// Delete it out of the map
-		delete(w.vertexMap, v)
+		delete(w.vertexMap, hashcode(v))
 		w.vertices.Delete(raw)
 	}
 
@@ -211,21 +423,21 @@ func (w *Walker) Update(g *AcyclicGraph) {
 		waiter, dep := w.edgeParts(edge)
 
 		// Get the info for the waiter
-		waiterInfo, ok := w.vertexMap[waiter]
+		waiterInfo, ok := w.vertexMap[hashcode(waiter)]
 		if !ok {
 			// Vertex doesn't exist... shouldn't be possible but ignore.
 			continue
 		}
 
 		// Get the info for the dep
-		depInfo, ok := w.vertexMap[dep]
+		depInfo, ok := w.vertexMap[hashcode(dep)]
 		if !ok {
 			// Vertex doesn't exist... shouldn't be possible but ignore.
 			continue
 		}
 
 		// Add the dependency to our waiter
-		waiterInfo.deps[dep] = depInfo.DoneCh
+		waiterInfo.deps[hashcode(dep)] = depInfo.DoneCh
 
 		// Record that the deps changed for this waiter
 		changedDeps.Add(waiter)
@@ -238,14 +450,17 @@ func (w *Walker) Update(g *AcyclicGraph) {
 		waiter, dep := w.edgeParts(edge)
 
 		// Get the info for the waiter
-		waiterInfo, ok := w.vertexMap[waiter]
+		waiterInfo, ok := w.vertexMap[hashcode(waiter)]
 		if !ok {
 			// Vertex doesn't exist... shouldn't be possible but ignore.
 			continue
 		}
 
-		// Delete the dependency from the waiter
-		delete(waiterInfo.deps, dep)
+		// Delete the dependency from the waiter, unless another edge with a
+		// different label still connects them
+		if g == nil || len(g.EdgesBetween(edge.Source(), edge.Target())) == 0 {
+			delete(waiterInfo.deps, hashcode(dep))
+		}
 
 		// Record that the deps changed for this waiter
 		changedDeps.Add(waiter)
@@ -256,7 +471,7 @@ func (w *Walker) Update(g *AcyclicGraph) {
 	// a new waiter and notify the vertex of the changes.
 	for _, raw := range changedDeps {
 		v := raw.(Vertex)
-		info, ok := w.vertexMap[v]
+		info, ok := w.vertexMap[hashcode(v)]
 		if !ok {
 			// Vertex doesn't exist... shouldn't be possible but ignore.
 			continue
@@ -269,7 +484,7 @@ func (w *Walker) Update(g *AcyclicGraph) {
 		cancelCh := make(chan struct{})
 
 		// Build a new deps copy
-		deps := make(map[Vertex]<-chan struct{})
+		deps := make(map[interface{}]<-chan struct{})
 		for k, v := range info.deps {
 			deps[k] = v
 		}
@@ -297,7 +512,7 @@ func (w *Walker) Update(g *AcyclicGraph) {
 	// the edge waiters and changes are set up above.
 	for _, raw := range newVerts {
 		v := raw.(Vertex)
-		go w.walkVertex(v, w.vertexMap[v])
+		go w.walkVertex(v, w.vertexMap[hashcode(v)])
 	}
 }
 
@@ -370,12 +585,28 @@ func (w *Walker) walkVertex(v Vertex, info *walkerVertex) {
 	default:
 	}
 
+	// Don't start anything new if the walk has been halted
+	w.diagsLock.Lock()
+	halted := w.halted
+	w.diagsLock.Unlock()
+
 	// Run our callback or note that our upstream failed
 	var diags Diagnostics
 	var upstreamFailed bool
-	if depsSuccess {
-		diags = w.Callback(v)
-	} else {
+	switch {
+	case halted:
+		// As with upstream failures, this is excluded from the result
+		// since it was caused by the vertex that halted the walk.
+		diags = diags.Append(errors.New("walk halted"))
+		upstreamFailed = true
+	case depsSuccess && w.Completed != nil && w.Completed(v):
+		// already done, so nothing to record
+	case depsSuccess:
+		diags = w.measure(v)
+		if w.afterVisit != nil && !diags.HasErrors() {
+			diags = diags.Append(w.afterVisit(v))
+		}
+	default:
 		// This won't be displayed to the user because we'll set upstreamFailed,
 		// but we need to ensure there's at least one error in here so that
 		// the failures will cascade downstream.
@@ -387,21 +618,149 @@ func (w *Walker) walkVertex(v Vertex, info *walkerVertex) {
 	// hold diagsLock while visiting a vertex.)
 	w.diagsLock.Lock()
 	if w.diagsMap == nil {
-		w.diagsMap = make(map[Vertex]Diagnostics)
+		w.diagsMap = make(map[interface{}]Diagnostics)
 	}
-	w.diagsMap[v] = diags
+	w.diagsMap[hashcode(v)] = diags
 	if w.upstreamFailed == nil {
-		w.upstreamFailed = make(map[Vertex]struct{})
+		w.upstreamFailed = make(map[interface{}]struct{})
 	}
 	if upstreamFailed {
-		w.upstreamFailed[v] = struct{}{}
+		w.upstreamFailed[hashcode(v)] = struct{}{}
+	} else if w.OnError == OnErrorHalt && diags.HasErrors() {
+		w.halted = true
 	}
 	w.diagsLock.Unlock()
 }
 
+// measure visits v once it is within the concurrency limits, reporting to
+// Metrics.
+func (w *Walker) measure(v Vertex) Diagnostics {
+	m := w.metrics()
+	queue := func(delta int) {
+		w.metricsLock.Lock()
+		w.queued += delta
+		m.SetGauge(MetricQueueDepth, float64(w.queued))
+		w.metricsLock.Unlock()
+	}
+
+	queue(1)
+	release := w.acquire(v)
+	queue(-1)
+
+	start := time.Now()
+	diags := w.visit(v, release).InVertex(v)
+	m.Observe(MetricVertexDuration, time.Since(start).Seconds())
+	m.AddCounter(MetricVerticesVisited, 1)
+	if diags.HasErrors() {
+		m.AddCounter(MetricVertexFailures, 1)
+	}
+
+	return diags
+}
+
+// metrics returns Metrics, or NopMetrics if it isn't set.
+func (w *Walker) metrics() Metrics {
+	if w.Metrics == nil {
+		return NopMetrics{}
+	}
+	return w.Metrics
+}
+
+// visit calls the callback for v, enforcing its timeout. release is called
+// once the callback has returned, even if that is after the timeout.
+func (w *Walker) visit(v Vertex, release func()) Diagnostics {
+	timeout := w.VertexTimeout
+	if tv, ok := v.(HasTimeout); ok {
+		timeout = tv.WalkTimeout()
+	}
+
+	call := func(ctx context.Context) Diagnostics {
+		defer release()
+		if w.CallbackCtx != nil {
+			return w.CallbackCtx(ctx, v)
+		}
+		return w.Callback(v)
+	}
+
+	if timeout <= 0 {
+		return call(context.Background())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The callback can't be interrupted, so it is left to finish in the
+	// background if it times out.
+	resultCh := make(chan Diagnostics, 1)
+	go func() {
+		resultCh <- call(ctx)
+	}()
+
+	select {
+	case diags := <-resultCh:
+		return diags
+	case <-ctx.Done():
+		var diags Diagnostics
+		return diags.Append(fmt.Errorf("%s: timed out after %s", VertexName(v), timeout))
+	}
+}
+
+// acquire waits until v may be visited under the concurrency limit of its
+// class and the overall limit of the walk, if any, returning a function to
+// be called once it has finished.
+func (w *Walker) acquire(v Vertex) func() {
+	releaseClass := w.acquireClass(v)
+
+	w.semaphoresLock.Lock()
+	if w.Limiter == nil && w.Parallelism > 0 {
+		w.Limiter = NewLimiter(w.Parallelism)
+	}
+	limiter := w.Limiter
+	w.semaphoresLock.Unlock()
+
+	if limiter == nil {
+		return releaseClass
+	}
+
+	limiter.Acquire(v)
+	return func() {
+		limiter.Release(v)
+		releaseClass()
+	}
+}
+
+// acquireClass waits until v may be visited under the concurrency limit of
+// its class, if any, returning a function to be called once it has finished.
+func (w *Walker) acquireClass(v Vertex) func() {
+	cv, ok := v.(ConcurrencyLimited)
+	if !ok {
+		return func() {}
+	}
+
+	class := cv.ConcurrencyClass()
+	limit := w.ConcurrencyLimits[class]
+	if limit <= 0 {
+		return func() {}
+	}
+
+	w.semaphoresLock.Lock()
+	if w.semaphores == nil {
+		w.semaphores = make(map[string]*semaphore)
+	}
+	sem, ok := w.semaphores[class]
+	if !ok {
+		sem = newSemaphore(limit)
+		w.semaphores[class] = sem
+	}
+	w.semaphoresLock.Unlock()
+
+	sem.Acquire(vertexPriority(v))
+	return sem.Release
+}
+
 func (w *Walker) waitDeps(
 	v Vertex,
-	deps map[Vertex]<-chan struct{},
+	deps map[interface{}]<-chan struct{},
 	doneCh chan<- bool,
 	cancelCh <-chan struct{}) {
 
@@ -423,7 +782,13 @@ func (w *Walker) waitDeps(
 		}
 	}
 
-	// Dependencies satisfied! We need to check if any errored
+	// Dependencies satisfied! Unless failures are being ignored, we need to
+	// check if any errored
+	if w.OnError == OnErrorContinue {
+		doneCh <- true
+		return
+	}
+
 	w.diagsLock.Lock()
 	defer w.diagsLock.Unlock()
 	for dep := range deps {
@@ -1,8 +1,10 @@
 package dag
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -93,6 +95,293 @@ func TestWalker_error(t *testing.T) {
 	}
 }
 
+func TestWalker_errorContinue(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 4))
+
+	// Record function
+	var order []interface{}
+	recordF := walkCbRecord(&order)
+
+	cb := func(v Vertex) Diagnostics {
+		if v == 2 {
+			var diags Diagnostics
+			diags = diags.Append(fmt.Errorf("error"))
+			return diags
+		}
+
+		return recordF(v)
+	}
+
+	w := &Walker{Callback: cb, OnError: OnErrorContinue}
+	w.Update(&g)
+
+	// Wait
+	diags := w.Wait()
+	if len(diags) != 1 {
+		t.Fatalf("expected a single error, got: %s", diags.Err())
+	}
+
+	// Check
+	expected := []interface{}{1, 3, 4}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("wrong order\ngot:  %#v\nwant: %#v", order, expected)
+	}
+}
+
+func TestWalker_errorHalt(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(3, 4))
+
+	// Record function
+	var order []interface{}
+	recordF := walkCbRecord(&order)
+
+	// 3 is independent of 1 and starts first, but doesn't finish until after
+	// 1 has failed, so 4 should never start.
+	startedCh := make(chan struct{})
+	failedCh := make(chan struct{})
+	cb := func(v Vertex) Diagnostics {
+		switch v {
+		case 1:
+			<-startedCh
+			defer close(failedCh)
+			var diags Diagnostics
+			diags = diags.Append(fmt.Errorf("error"))
+			return diags
+		case 3:
+			close(startedCh)
+			<-failedCh
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		return recordF(v)
+	}
+
+	w := &Walker{Callback: cb, OnError: OnErrorHalt}
+	w.Update(&g)
+
+	// Wait
+	diags := w.Wait()
+	if len(diags) != 1 {
+		t.Fatalf("expected a single error, got: %s", diags.Err())
+	}
+
+	// Check
+	expected := []interface{}{3}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("wrong order\ngot:  %#v\nwant: %#v", order, expected)
+	}
+}
+
+func TestWalker_concurrencyLimits(t *testing.T) {
+	var g AcyclicGraph
+	for i := 0; i < 8; i++ {
+		g.Add(&testClassVertex{name: fmt.Sprintf("net%d", i), class: "network"})
+		g.Add(&testClassVertex{name: fmt.Sprintf("cpu%d", i), class: "cpu"})
+	}
+
+	var lock sync.Mutex
+	running := make(map[string]int)
+	peak := make(map[string]int)
+	cb := func(v Vertex) Diagnostics {
+		class := v.(*testClassVertex).class
+		lock.Lock()
+		running[class]++
+		if running[class] > peak[class] {
+			peak[class] = running[class]
+		}
+		lock.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		lock.Lock()
+		running[class]--
+		lock.Unlock()
+		return nil
+	}
+
+	w := &Walker{Callback: cb, ConcurrencyLimits: map[string]int{"network": 2}}
+	w.Update(&g)
+	if err := w.Wait(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if peak["network"] > 2 {
+		t.Fatalf("%d network vertices were walked at once, limit is 2", peak["network"])
+	}
+	if peak["cpu"] <= 2 {
+		t.Fatalf("cpu vertices should be unlimited, peak was %d", peak["cpu"])
+	}
+}
+
+func TestWalker_parallelism(t *testing.T) {
+	var lock sync.Mutex
+	var running, peak int
+	cb := func(v Vertex) Diagnostics {
+		lock.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		lock.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+		return nil
+	}
+
+	testGraph := func() *AcyclicGraph {
+		var g AcyclicGraph
+		for i := 0; i < 8; i++ {
+			g.Add(i)
+		}
+		return &g
+	}
+
+	w := &Walker{Callback: cb, Parallelism: 2}
+	w.Update(testGraph())
+	if err := w.Wait(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if peak != 2 {
+		t.Fatalf("expected 2 vertices to be walked at once, got %d", peak)
+	}
+
+	// a shared limiter caps the vertices walked by both walks together
+	peak = 0
+	limiter := NewLimiter(3)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if diags := testGraph().WalkWithOpts(cb, &WalkOpts{Limiter: limiter}); diags.HasErrors() {
+				t.Error(diags.Err())
+			}
+		}()
+	}
+	wg.Wait()
+	if peak != 3 {
+		t.Fatalf("expected 3 vertices to be walked at once, got %d", peak)
+	}
+
+	// a limiter of no size doesn't limit the walk at all
+	peak = 0
+	if diags := testGraph().WalkWithOpts(cb, &WalkOpts{Limiter: NewLimiter(0)}); diags.HasErrors() {
+		t.Fatal(diags.Err())
+	}
+	if peak < 2 {
+		t.Fatalf("expected vertices to be walked at once, got %d", peak)
+	}
+}
+
+func TestWalker_completed(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 4))
+
+	done := make(Set)
+	done.Add(1)
+	done.Add(3)
+
+	var order []interface{}
+	w := &Walker{Callback: walkCbRecord(&order), Completed: func(v Vertex) bool { return done.Include(v) }}
+	w.Update(&g)
+	if err := w.Wait(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// the completed vertices still order the others
+	expected := []interface{}{2, 4}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("wrong order\ngot:  %#v\nwant: %#v", order, expected)
+	}
+}
+
+func TestWalker_vertexTimeout(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(&testTimeoutVertex{timeout: 10 * time.Millisecond})
+	g.Connect(BasicEdge(1, 2))
+
+	release := make(chan struct{})
+	defer close(release)
+
+	cancelled := make(chan struct{})
+	cb := func(ctx context.Context, v Vertex) Diagnostics {
+		switch v.(type) {
+		case int:
+			if v == 1 {
+				// hangs until the context is cancelled
+				<-ctx.Done()
+				close(cancelled)
+			}
+		case *testTimeoutVertex:
+			<-release
+		}
+		return nil
+	}
+
+	w := &Walker{CallbackCtx: cb, VertexTimeout: 20 * time.Millisecond}
+	w.Update(&g)
+	diags := w.Wait()
+
+	errs := diags.Err()
+	if errs == nil {
+		t.Fatal("expected timeouts")
+	}
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 timeouts, got: %s", errs)
+	}
+	for _, want := range []string{"1: timed out after 20ms", "timeout: timed out after 10ms"} {
+		if !strings.Contains(errs.Error(), want) {
+			t.Fatalf("missing %q in: %s", want, errs)
+		}
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("context should be cancelled")
+	}
+}
+
+type testTimeoutVertex struct {
+	timeout time.Duration
+}
+
+func (v *testTimeoutVertex) Name() string               { return "timeout" }
+func (v *testTimeoutVertex) WalkTimeout() time.Duration { return v.timeout }
+
+type testClassVertex struct {
+	name, class string
+}
+
+func (v *testClassVertex) Name() string             { return v.name }
+func (v *testClassVertex) ConcurrencyClass() string { return v.class }
+
 func TestWalker_newVertex(t *testing.T) {
 	var g AcyclicGraph
 	g.Add(1)
@@ -140,6 +429,77 @@ func TestWalker_newVertex(t *testing.T) {
 	}
 }
 
+func TestWalker_hashcode(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(&hashVertex{code: 1})
+	g.Add(&hashVertex{code: 2})
+	g.Connect(BasicEdge(&hashVertex{code: 1}, &hashVertex{code: 2}))
+
+	var order []interface{}
+	recordF := walkCbRecord(&order)
+	w := &Walker{Callback: func(v Vertex) Diagnostics {
+		return recordF(v.(*hashVertex).code)
+	}}
+	w.Update(&g)
+	if err := w.Wait(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Update with a graph of distinct pointers to the same vertices, which
+	// shouldn't be walked again, plus a new vertex depending on one of them.
+	var g2 AcyclicGraph
+	g2.Add(&hashVertex{code: 1})
+	g2.Add(&hashVertex{code: 2})
+	g2.Add(&hashVertex{code: 3})
+	g2.Connect(BasicEdge(&hashVertex{code: 1}, &hashVertex{code: 2}))
+	g2.Connect(BasicEdge(&hashVertex{code: 2}, &hashVertex{code: 3}))
+	w.Update(&g2)
+	if err := w.Wait(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []interface{}{1, 2, 3}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("wrong order\ngot:  %#v\nwant: %#v", order, expected)
+	}
+}
+
+func TestWalker_expandDuringWalk(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+
+	var order []interface{}
+	recordF := walkCbRecord(&order)
+
+	var w *Walker
+	cb := func(v Vertex) Diagnostics {
+		// 1 expands into 2 and 3, which depend on it, and 2 into 4
+		switch v {
+		case 1:
+			g.Add(2)
+			g.Add(3)
+			g.Connect(BasicEdge(1, 2))
+			g.Connect(BasicEdge(2, 3))
+			w.Update(&g)
+		case 2:
+			g.Add(4)
+			g.Connect(BasicEdge(2, 4))
+			w.Update(&g)
+		}
+		return recordF(v)
+	}
+
+	w = &Walker{Callback: cb}
+	w.Update(&g)
+	if err := w.Wait(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(order) != 4 || order[0] != 1 || order[1] != 2 {
+		t.Fatalf("wrong order: %#v", order)
+	}
+}
+
 func TestWalker_removeVertex(t *testing.T) {
 	var g AcyclicGraph
 	g.Add(1)
@@ -0,0 +1,573 @@
+package dag
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// ParseYAML reads a graph from a declarative YAML definition, for pipelines
+// written by hand. Each entry under nodes is a vertex, named by its key, and
+// may list the nodes it depends on, set string attributes, and contain a
+// subgraph with nodes of its own:
+//
+//	nodes:
+//	  build:
+//	    depends_on: [fetch, configure]
+//	  fetch:
+//	  configure:
+//	    attrs:
+//	      owner: platform
+//	  deploy:
+//	    depends_on:
+//	      - build
+//	    subgraph:
+//	      nodes:
+//	        push:
+//	        notify:
+//	          depends_on: [push]
+//
+// Instead of a list, depends_on may be a mapping from each dependency to the
+// weight of the edge to it, such as the time it takes, which connects a
+// WeightedEdge:
+//
+//	depends_on:
+//	  fetch: 2.5
+//	  configure: ~
+//
+// Vertices are reconstructed as *ParsedVertex, or as *ParsedSubgraph when
+// they contain a subgraph. Every unknown dependency and every cycle is
+// reported, in a single error.
+//
+// Only the subset of YAML needed for this format is supported: block
+// mappings and sequences, flow sequences of scalars, quoted and plain
+// scalars, and comments.
+func ParseYAML(r io.Reader) (*AcyclicGraph, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := parseYAML(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	g, diags := yamlGraph(doc)
+	if err := diags.Err(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// yamlGraph builds a graph from a parsed YAML document.
+func yamlGraph(doc *yamlValue) (*AcyclicGraph, Diagnostics) {
+	var diags Diagnostics
+	if doc == nil {
+		return &AcyclicGraph{}, nil
+	}
+	if doc.keys == nil {
+		return nil, diags.Append(doc.errorf("expected a mapping with nodes"))
+	}
+	for _, k := range doc.keys {
+		if k != "nodes" {
+			diags = diags.Append(doc.values[k].errorf("unknown key %q", k))
+		}
+	}
+
+	nodes := doc.values["nodes"]
+	if nodes == nil {
+		return &AcyclicGraph{}, diags
+	}
+	if nodes.keys == nil {
+		return nil, diags.Append(nodes.errorf("nodes must be a mapping of node names"))
+	}
+
+	g := &AcyclicGraph{}
+	byName := make(map[string]Vertex, len(nodes.keys))
+	for _, name := range nodes.keys {
+		node := nodes.values[name]
+		pv := &ParsedVertex{ID: name, Name: name}
+		var v Vertex = pv
+		if node == nil {
+			byName[name] = v
+			g.Add(v)
+			continue
+		}
+		if node.keys == nil {
+			diags = diags.Append(node.errorf("node %q must be a mapping", name))
+			continue
+		}
+
+		for _, k := range node.keys {
+			value := node.values[k]
+			switch k {
+			case "depends_on":
+			case "attrs":
+				attrs, err := value.stringMap()
+				if err != nil {
+					diags = diags.Append(err)
+					continue
+				}
+				pv.Attrs = attrs
+			case "subgraph":
+				sg, sgDiags := yamlGraph(value)
+				for _, d := range sgDiags {
+					diags = diags.Append(fmt.Errorf("subgraph %q: %s", name, d.Description().Summary))
+				}
+				if sg != nil {
+					v = &ParsedSubgraph{ParsedVertex: pv, Graph: &sg.Graph}
+				}
+			default:
+				diags = diags.Append(value.errorf("unknown key %q for node %q", k, name))
+			}
+		}
+
+		byName[name] = v
+		g.Add(v)
+	}
+
+	for _, name := range nodes.keys {
+		node := nodes.values[name]
+		if node == nil || node.values["depends_on"] == nil {
+			continue
+		}
+
+		deps, err := node.values["depends_on"].dependencies()
+		if err != nil {
+			diags = diags.Append(err)
+			continue
+		}
+		for _, dep := range deps {
+			target, ok := byName[dep.name]
+			if !ok {
+				diags = diags.Append(node.values["depends_on"].errorf(
+					"node %q depends on unknown node %q", name, dep.name))
+				continue
+			}
+			g.Connect(rebuildEdge(byName[name], target, "", dep.weight, dep.weighted, nil))
+		}
+	}
+
+	return g, diags.Append(g.cycleDiagnostics())
+}
+
+// yamlValue is a parsed YAML value, which is one of a scalar, a sequence or
+// a mapping. A null value is represented by a nil *yamlValue.
+type yamlValue struct {
+	line int
+
+	scalar   string
+	isScalar bool
+
+	list []*yamlValue
+
+	// keys holds the keys of a mapping in order, and is non-nil for every
+	// mapping, even an empty one.
+	keys   []string
+	values map[string]*yamlValue
+}
+
+func (v *yamlValue) errorf(format string, args ...interface{}) error {
+	if v == nil {
+		return fmt.Errorf(format, args...)
+	}
+	return fmt.Errorf("line %d: %s", v.line, fmt.Sprintf(format, args...))
+}
+
+// stringList returns a sequence of scalars, or a single scalar as a list of
+// one.
+func (v *yamlValue) stringList() ([]string, error) {
+	if v.isScalar {
+		return []string{v.scalar}, nil
+	}
+	if v.keys != nil {
+		return nil, v.errorf("expected a list")
+	}
+
+	result := make([]string, 0, len(v.list))
+	for _, item := range v.list {
+		if item == nil || !item.isScalar {
+			return nil, v.errorf("expected a list of names")
+		}
+		result = append(result, item.scalar)
+	}
+	return result, nil
+}
+
+// yamlDependency is a dependency listed by depends_on, with its weight if
+// one was given.
+type yamlDependency struct {
+	name     string
+	weight   float64
+	weighted bool
+}
+
+// dependencies returns the dependencies listed by a depends_on value, which
+// is either a list of names, or a mapping of names to the weights of their
+// edges, where a null weight leaves the edge unweighted.
+func (v *yamlValue) dependencies() ([]yamlDependency, error) {
+	if v.keys == nil {
+		names, err := v.stringList()
+		if err != nil {
+			return nil, err
+		}
+		deps := make([]yamlDependency, len(names))
+		for i, name := range names {
+			deps[i] = yamlDependency{name: name}
+		}
+		return deps, nil
+	}
+
+	deps := make([]yamlDependency, 0, len(v.keys))
+	for _, k := range v.keys {
+		dep := yamlDependency{name: k}
+		if value := v.values[k]; value != nil {
+			if !value.isScalar {
+				return nil, value.errorf("expected a weight for %q", k)
+			}
+			w, err := strconv.ParseFloat(value.scalar, 64)
+			if err != nil {
+				return nil, value.errorf("invalid weight for %q: %q", k, value.scalar)
+			}
+			dep.weight, dep.weighted = w, true
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+// stringMap returns a mapping of scalars.
+func (v *yamlValue) stringMap() (map[string]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if v.keys == nil {
+		return nil, v.errorf("expected a mapping")
+	}
+
+	result := make(map[string]string, len(v.keys))
+	for _, k := range v.keys {
+		value := v.values[k]
+		switch {
+		case value == nil:
+			result[k] = ""
+		case value.isScalar:
+			result[k] = value.scalar
+		default:
+			return nil, value.errorf("expected a string for %q", k)
+		}
+	}
+	return result, nil
+}
+
+// yamlLine is a line of YAML source without its indentation and comments.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML parses the subset of YAML described by ParseYAML.
+func parseYAML(src string) (*yamlValue, error) {
+	p := &yamlParser{}
+	for i, text := range strings.Split(src, "\n") {
+		text = strings.TrimRight(stripYAMLComment(text), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs can't be used for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{
+			num:    i + 1,
+			indent: len(text) - len(trimmed),
+			text:   trimmed,
+		})
+	}
+
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+
+	v, err := p.parseBlock(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+	}
+	return v, nil
+}
+
+// stripYAMLComment removes a comment from the end of a line, ignoring any #
+// within quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseBlock parses the sequence or mapping whose entries start at indent.
+func (p *yamlParser) parseBlock(indent int) (*yamlValue, error) {
+	first := p.lines[p.pos]
+	if isYAMLSeqItem(first.text) {
+		return p.parseSeq(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseSeq(indent int) (*yamlValue, error) {
+	seq := &yamlValue{line: p.lines[p.pos].num, list: []*yamlValue{}}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent || (l.indent == indent && !isYAMLSeqItem(l.text)) {
+			// the end of a sequence at the same indentation as its key
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		p.pos++
+
+		rest := strings.TrimSpace(strings.TrimPrefix(l.text, "-"))
+		if rest == "" {
+			item, err := p.parseNested(indent, false)
+			if err != nil {
+				return nil, err
+			}
+			seq.list = append(seq.list, item)
+			continue
+		}
+		if _, _, ok := splitYAMLKey(rest); ok {
+			return nil, fmt.Errorf("line %d: mappings in lists are not supported", l.num)
+		}
+
+		item, err := parseYAMLInline(rest, l.num)
+		if err != nil {
+			return nil, err
+		}
+		seq.list = append(seq.list, item)
+	}
+	return seq, nil
+}
+
+func (p *yamlParser) parseMap(indent int) (*yamlValue, error) {
+	m := &yamlValue{
+		line:   p.lines[p.pos].num,
+		keys:   []string{},
+		values: make(map[string]*yamlValue),
+	}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		p.pos++
+
+		key, rest, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", l.num)
+		}
+		if _, dup := m.values[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.num, key)
+		}
+
+		var value *yamlValue
+		var err error
+		if rest == "" {
+			// a sequence may be at the same indentation as its key
+			value, err = p.parseNested(indent, true)
+		} else {
+			value, err = parseYAMLInline(rest, l.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		m.keys = append(m.keys, key)
+		m.values[key] = value
+	}
+	return m, nil
+}
+
+// parseNested parses the block following a key or list item at indent, or
+// returns nil if there is none. If seqAtIndent is true, a sequence at the
+// same indentation also belongs to the key.
+func (p *yamlParser) parseNested(indent int, seqAtIndent bool) (*yamlValue, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+
+	next := p.lines[p.pos]
+	switch {
+	case next.indent > indent:
+		return p.parseBlock(next.indent)
+	case next.indent == indent && seqAtIndent && isYAMLSeqItem(next.text):
+		return p.parseSeq(indent)
+	default:
+		return nil, nil
+	}
+}
+
+// splitYAMLKey splits a "key: value" line into its unquoted key and the rest
+// of the line, which is empty for a key followed by a nested block.
+func splitYAMLKey(text string) (string, string, bool) {
+	var key string
+	var rest string
+	if text[0] == '"' || text[0] == '\'' {
+		end := yamlQuoteEnd(text)
+		if end < 0 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		unquoted, err := unquoteYAML(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		key, rest = unquoted, text[end+2:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false
+		}
+	} else {
+		i := strings.Index(text, ": ")
+		switch {
+		case i >= 0:
+			key, rest = text[:i], text[i+2:]
+		case strings.HasSuffix(text, ":"):
+			key = text[:len(text)-1]
+		default:
+			return "", "", false
+		}
+	}
+
+	return strings.TrimSpace(key), strings.TrimSpace(rest), true
+}
+
+// yamlQuoteEnd returns the index of the quote closing the quoted string at
+// the start of text, or -1 if it isn't closed.
+func yamlQuoteEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case quote == '\'' && text[i] == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unquoteYAML returns the value of a double or single quoted scalar.
+func unquoteYAML(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return strconv.Unquote(s)
+}
+
+// parseYAMLInline parses a value on the same line as its key or list item.
+func parseYAMLInline(text string, line int) (*yamlValue, error) {
+	switch {
+	case text == "~" || text == "null":
+		return nil, nil
+	case text == "{}":
+		return &yamlValue{line: line, keys: []string{}, values: map[string]*yamlValue{}}, nil
+	case text[0] == '[':
+		if text[len(text)-1] != ']' {
+			return nil, fmt.Errorf("line %d: unterminated list", line)
+		}
+		seq := &yamlValue{line: line, list: []*yamlValue{}}
+		items, err := splitYAMLFlow(text[1:len(text)-1], line)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			v, err := parseYAMLScalar(item, line)
+			if err != nil {
+				return nil, err
+			}
+			seq.list = append(seq.list, v)
+		}
+		return seq, nil
+	case text[0] == '{':
+		return nil, fmt.Errorf("line %d: flow mappings are not supported", line)
+	default:
+		return parseYAMLScalar(text, line)
+	}
+}
+
+// splitYAMLFlow splits the items of a flow sequence on commas outside
+// quotes.
+func splitYAMLFlow(text string, line int) ([]string, error) {
+	var items []string
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '"', '\'':
+			end := yamlQuoteEnd(text[i:])
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			i += end
+		case '[', ']', '{', '}':
+			return nil, fmt.Errorf("line %d: nested collections are not supported", line)
+		case ',':
+			items = append(items, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(text[start:]); last != "" || len(items) > 0 {
+		items = append(items, last)
+	}
+	return items, nil
+}
+
+func parseYAMLScalar(text string, line int) (*yamlValue, error) {
+	if text == "" {
+		return nil, fmt.Errorf("line %d: expected a value", line)
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		if yamlQuoteEnd(text) != len(text)-1 {
+			return nil, fmt.Errorf("line %d: unterminated string", line)
+		}
+		s, err := unquoteYAML(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		return &yamlValue{line: line, scalar: s, isScalar: true}, nil
+	}
+	return &yamlValue{line: line, scalar: text, isScalar: true}, nil
+}
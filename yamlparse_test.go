@@ -0,0 +1,185 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	g, err := ParseYAML(strings.NewReader(testYAMLGraph))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := strings.TrimSpace(g.String())
+	expected := strings.TrimSpace(testParseYAMLStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+
+	var deploy *ParsedSubgraph
+	for _, v := range g.Vertices() {
+		switch v := v.(type) {
+		case *ParsedSubgraph:
+			deploy = v
+		case *ParsedVertex:
+			if v.Name == "configure" && v.Attrs["owner"] != "plat # form" {
+				t.Fatalf("bad attrs: %#v", v.Attrs)
+			}
+		}
+	}
+	if deploy == nil || deploy.Name != "deploy" {
+		t.Fatal("deploy should be a subgraph")
+	}
+
+	actual = strings.TrimSpace(deploy.Graph.String())
+	expected = strings.TrimSpace(testParseYAMLSubgraphStr)
+	if actual != expected {
+		t.Fatalf("bad subgraph:\n\n%s", actual)
+	}
+}
+
+func TestParseYAML_empty(t *testing.T) {
+	for _, src := range []string{"", "# nothing\n", "nodes: {}\n"} {
+		g, err := ParseYAML(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("%q: %s", src, err)
+		}
+		if len(g.Vertices()) != 0 {
+			t.Fatalf("%q: expected no vertices", src)
+		}
+	}
+}
+
+func TestParseYAML_unknownNodes(t *testing.T) {
+	_, err := ParseYAML(strings.NewReader(`
+nodes:
+  a:
+    depends_on: [b, missing]
+  b:
+    depends_on: other
+  c:
+    subgraph:
+      nodes:
+        d:
+          depends_on: [gone]
+`))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	for _, want := range []string{
+		`node "a" depends on unknown node "missing"`,
+		`node "b" depends on unknown node "other"`,
+		`subgraph "c": line 11: node "d" depends on unknown node "gone"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q:\n%s", want, err)
+		}
+	}
+}
+
+func TestParseYAML_weights(t *testing.T) {
+	g, err := ParseYAML(strings.NewReader(`
+nodes:
+  build:
+    depends_on:
+      fetch: 2.5
+      configure: ~
+  fetch:
+  configure:
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range g.Edges() {
+		_, weighted := e.(WeightedEdge)
+		switch VertexName(e.Target()) {
+		case "fetch":
+			if !weighted || edgeWeight(e) != 2.5 {
+				t.Fatalf("bad edge to fetch: %#v", e)
+			}
+		case "configure":
+			if weighted {
+				t.Fatalf("edge to configure should not be weighted: %#v", e)
+			}
+		}
+	}
+
+	_, err = ParseYAML(strings.NewReader("nodes:\n  a:\n    depends_on:\n      b: slow\n  b:\n"))
+	if err == nil || !strings.Contains(err.Error(), `invalid weight for "b"`) {
+		t.Fatalf("expected an invalid weight error, got %v", err)
+	}
+}
+
+func TestParseYAML_cycle(t *testing.T) {
+	_, err := ParseYAML(strings.NewReader(`
+nodes:
+  a:
+    depends_on: [b]
+  b:
+    depends_on: [a]
+`))
+	if err == nil || !strings.Contains(err.Error(), "Cycle: ") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+}
+
+func TestParseYAML_errors(t *testing.T) {
+	cases := map[string]string{
+		"tab":          "nodes:\n\ta:\n",
+		"indentation":  "nodes:\n  a:\n      b:\n    c:\n",
+		"no colon":     "nodes:\n  a\n",
+		"unknown key":  "nodes:\n  a:\n    needs: [b]\n",
+		"top level":    "vertices:\n  a:\n",
+		"unterminated": "nodes:\n  a:\n    depends_on: [b\n",
+		"quote":        "nodes:\n  \"a:\n",
+		"duplicate":    "nodes:\n  a:\n  a:\n",
+		"list":         "- a\n- b\n",
+	}
+
+	for name, src := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseYAML(strings.NewReader(src)); err == nil {
+				t.Fatalf("expected an error for:\n%s", src)
+			}
+		})
+	}
+}
+
+const testYAMLGraph = `
+# a build pipeline
+nodes:
+  build:
+    depends_on: [fetch, "configure"]
+  fetch:
+  configure:
+    attrs:
+      owner: "plat # form"   # quoted
+  deploy:
+    depends_on:
+    - build
+    subgraph:
+      nodes:
+        push: ~
+        notify:
+          depends_on:
+            - push
+`
+
+const testParseYAMLStr = `
+build
+  configure
+  fetch
+configure
+deploy
+  build
+fetch
+`
+
+const testParseYAMLSubgraphStr = `
+notify
+  push
+push
+`